@@ -34,6 +34,10 @@ type AddAdmin struct {
 	Role       string `json:"role"`
 }
 
+type AdminRoleInput struct {
+	Role string `json:"role"`
+}
+
 type AdminLogin struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
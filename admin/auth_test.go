@@ -1,6 +1,7 @@
 package admin
 
 import (
+	"strings"
 	"testing"
 
 	"golang.org/x/crypto/bcrypt"
@@ -126,7 +127,7 @@ func TestRegAdminInput(t *testing.T) {
 	}
 	longEmail := SampleAdmin{
 		name:     "should return too long email",
-		data:     AddAdmin{"John Name", "uasdjdsksksksksksk@gmaillll.coooom", "0712345689", "Pass@1234", "this is image", "role"},
+		data:     AddAdmin{"John Name", strings.Repeat("uasdjdsksksk", 20) + "@gmaillll.coooom", "0712345689", "Pass@1234", "this is image", "role"},
 		expected: false,
 	}
 
@@ -292,7 +293,7 @@ func TestLoginData(t *testing.T) {
 		{
 			name: "test passed",
 			data: validData,
-			want: false,
+			want: true,
 		},
 	}
 
@@ -7,16 +7,105 @@ import (
 	"strings"
 	"time"
 
+	"eleliafrika.com/backend/auth"
 	"eleliafrika.com/backend/images"
 	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/pagination"
 	"eleliafrika.com/backend/product"
 	"eleliafrika.com/backend/users"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
-func Register(context *gin.Context) {
-	var input AddAdmin
+// create an invitation for a new admin, the token in the response is sent
+// to the invitee who uses it to set up their account
+func InviteAdmin(context *gin.Context) {
+	var input InviteInput
+	if err := context.ShouldBindJSON(&input); err != nil {
+		response := models.Reply{
+			Error:   errors.New("could not bind data").Error(),
+			Message: "could not bind json data from user",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	input.Email = strings.ToLower(strings.TrimSpace(input.Email))
+	input.Role = strings.ToLower(strings.TrimSpace(input.Role))
+
+	if !auth.IsValidRole(input.Role) {
+		response := models.Reply{
+			Error:   errors.New("unknown admin role").Error(),
+			Message: "role should be one of superadmin, moderator or finance",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	} else if len(input.Email) < 8 || !strings.Contains(input.Email, "@") || !strings.Contains(input.Email, ".") {
+		response := models.Reply{
+			Error:   errors.New("invalid email").Error(),
+			Message: "invalid email format!email should contain @, . and should be longer than 8 characters",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	currentAdmin, err := CurrentUser(context)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "error authenticating admin",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	adminExists, err := FindAdminByEmail(input.Email)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "error validating email",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	} else if adminExists.Email != "" {
+		response := models.Reply{
+			Error:   errors.New("admin email has already been used").Error(),
+			Message: "email already used",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	invitation, token, err := CreateInvitation(currentAdmin.AdminID, input.Email, input.Role)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "could not create the invitation",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	response := models.Reply{
+		Data: gin.H{
+			"invitation": invitation,
+			"token":      token,
+		},
+		Message: "invitation created",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+// set up the account of an invited admin and sign them in
+func AcceptInvite(context *gin.Context) {
+	var input AcceptInviteInput
 
 	if err := context.ShouldBindJSON(&input); err != nil {
 		response := models.Reply{
@@ -27,7 +116,27 @@ func Register(context *gin.Context) {
 		context.JSON(http.StatusBadRequest, response)
 		return
 	}
-	success, err := ValidateRegisterInput(&input)
+
+	invitation, err := FindInvitationByToken(input.Token)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "invalid invitation",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	details := AddAdmin{
+		AdminName:  input.AdminName,
+		Email:      invitation.Email,
+		Cell:       input.Cell,
+		Password:   input.Password,
+		AdminImage: input.AdminImage,
+		Role:       invitation.Role,
+	}
+	success, err := ValidateRegisterInput(&details)
 	if err != nil {
 		response := models.Reply{
 			Error:   err.Error(),
@@ -52,7 +161,7 @@ func Register(context *gin.Context) {
 	currentTime := time.Now()
 	formattedTime := currentTime.Format("2006-01-02 15:04:05")
 
-	imageUrl, err := images.UploadHandler(input.AdminName, input.AdminImage, context)
+	imageUrl, err := images.UploadHandler(details.AdminName, details.AdminImage, context)
 	if err != nil {
 		response := models.Reply{
 			Message: "main image not saved",
@@ -65,11 +174,12 @@ func Register(context *gin.Context) {
 
 	admin := SystemAdmin{
 		AdminID:      randomuuid.String(),
-		AdminName:    input.AdminName,
-		Email:        input.Email,
+		AdminName:    details.AdminName,
+		Email:        details.Email,
 		AdminImage:   imageUrl,
-		Cell:         input.Cell,
-		Password:     input.Password,
+		Cell:         details.Cell,
+		Password:     details.Password,
+		Role:         invitation.Role,
 		DateAdded:    formattedTime,
 		LastLoggedIn: formattedTime,
 	}
@@ -93,7 +203,7 @@ func Register(context *gin.Context) {
 		context.JSON(http.StatusBadRequest, response)
 		return
 	} else {
-		_, err := admin.Save()
+		err := AcceptInvitation(invitation, &admin)
 		if err != nil {
 			response := models.Reply{
 				Error:   err.Error(),
@@ -103,8 +213,8 @@ func Register(context *gin.Context) {
 			context.JSON(http.StatusBadRequest, response)
 			return
 		}
-		// generate token directly on succesfuly register
-		token, err := GenerateJWT(admin)
+		// sign the admin in directly on succesfuly register
+		tokens, err := auth.IssueSession(admin.AdminID, auth.SubjectAdmin, admin.Role, context)
 		if err != nil {
 			response := models.Reply{
 				Error:   err.Error(),
@@ -114,22 +224,12 @@ func Register(context *gin.Context) {
 			context.JSON(http.StatusBadRequest, response)
 			return
 		}
-		_, err = UpdateAdminUtil(admin.AdminID, SystemAdmin{
-			Token: token,
-		})
-		if err != nil {
-			response := models.Reply{
-				Message: "could not update user",
-				Success: false,
-				Error:   err.Error(),
-			}
-			context.JSON(http.StatusOK, response)
-			return
-		}
 
 		userDetails := gin.H{
-			"token":      token,
-			"use_detail": admin,
+			"token":        tokens.AccessToken,
+			"refreshtoken": tokens.RefreshToken,
+			"expiresat":    tokens.ExpiresAt,
+			"use_detail":   admin,
 		}
 
 		response := models.Reply{
@@ -142,6 +242,85 @@ func Register(context *gin.Context) {
 	}
 }
 
+func GetInvitations(context *gin.Context) {
+	invitations, err := FetchPendingInvitations()
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "error fetching invitations",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Data:    invitations,
+		Message: "succesfully fetched invitations",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+func RevokeAdminInvitation(context *gin.Context) {
+	id := strings.ReplaceAll(context.Query("id"), "'", "")
+
+	currentAdmin, err := CurrentUser(context)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "error authenticating admin",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	if err := RevokeInvitation(currentAdmin.AdminID, id); err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "could not revoke the invitation",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "invitation revoked",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+func GetAuditLog(context *gin.Context) {
+	request, err := pagination.ParseRequest(context, "date", true)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "invalid pagination parameters",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	_, page, err := FetchAuditPage(request)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "error fetching the audit log",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Data:    page,
+		Message: "succesfully fetched the audit log",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
 func Login(context *gin.Context) {
 	var input AdminLogin
 
@@ -201,8 +380,8 @@ func Login(context *gin.Context) {
 			return
 		}
 
-		// generate jwt if error does not exists
-		token, err := GenerateJWT(admin)
+		// start a session for this device if error does not exists
+		tokens, err := auth.IssueSession(admin.AdminID, auth.SubjectAdmin, admin.Role, context)
 
 		if err != nil {
 			response := models.Reply{
@@ -213,22 +392,11 @@ func Login(context *gin.Context) {
 			context.JSON(http.StatusBadRequest, response)
 			return
 		} else {
-			_, err = UpdateAdminUtil(admin.AdminID, SystemAdmin{
-				Token: token,
-			})
-			if err != nil {
-				response := models.Reply{
-					Error:   err.Error(),
-					Message: "error while generating token",
-					Success: false,
-				}
-				context.JSON(http.StatusBadRequest, response)
-				return
-			}
-
 			userDetails := gin.H{
-				"token":      token,
-				"use_detail": admin,
+				"token":        tokens.AccessToken,
+				"refreshtoken": tokens.RefreshToken,
+				"expiresat":    tokens.ExpiresAt,
+				"use_detail":   admin,
 			}
 
 			response := models.Reply{
@@ -399,16 +567,10 @@ func RevokeUser(context *gin.Context) {
 			}
 			context.JSON(http.StatusBadRequest, response)
 			return
-		} else if !userExists.IsApproved {
-			response := models.Reply{
-				Data:    users.User{},
-				Message: "user is already revoked",
-				Success: true,
-			}
-			context.JSON(http.StatusOK, response)
-			return
 		} else {
-			_, err := users.UpdateUserSpecificField(id, "is_approved", false)
+			// a revoked seller's ads come down with the account. A revoked
+			// user goes through again so ads left up are still suspended
+			suspended, err := product.RevokeSeller(id, currentAdmin.AdminID)
 			if err != nil {
 				response := models.Reply{
 					Error:   err.Error(),
@@ -418,8 +580,13 @@ func RevokeUser(context *gin.Context) {
 				context.JSON(http.StatusBadRequest, response)
 				return
 			}
+			message := "succesfuly revoked the user"
+			if !userExists.IsApproved {
+				message = "user is already revoked"
+			}
 			response := models.Reply{
-				Message: "succesfuly revoked the user",
+				Data:    gin.H{"suspendedads": suspended},
+				Message: message,
 				Success: true,
 			}
 			context.JSON(http.StatusOK, response)
@@ -487,7 +654,7 @@ func ApproveProduct(context *gin.Context) {
 			return
 		} else {
 
-			success, err := ApproveAd(id)
+			success, err := ApproveAd(id, currentAdmin.AdminID)
 			if err != nil {
 				response := models.Reply{
 					Error:   err.Error(),
@@ -587,12 +754,22 @@ func UpdateAdmin(context *gin.Context) {
 		context.JSON(http.StatusBadRequest, response)
 		return
 	} else {
+		if adminUpdateData.Password != "" {
+			if err := auth.ValidatePassword(adminUpdateData.Password); err != nil {
+				response := models.Reply{
+					Message: err.Error(),
+					Error:   err.Error(),
+					Success: false,
+				}
+				context.JSON(http.StatusBadRequest, response)
+				return
+			}
+		}
 		adminId := thisAdmin.AdminID
 		newAdmin := SystemAdmin{
 			AdminName: adminUpdateData.AdminName,
 			Email:     adminUpdateData.Email,
 			Password:  adminUpdateData.Password,
-			Role:      adminUpdateData.Role,
 			Cell:      adminUpdateData.Cell,
 		}
 		adminUpdated, err := UpdateAdminUtil(adminId, newAdmin)
@@ -616,6 +793,16 @@ func UpdateAdmin(context *gin.Context) {
 	}
 }
 func LogOutAdmin(context *gin.Context) {
+	claims, err := auth.ValidateToken(context)
+	if err != nil {
+		response := models.Reply{
+			Message: "error fetching current admin",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
 	admin, err := CurrentUser(context)
 	if err != nil {
 
@@ -636,18 +823,8 @@ func LogOutAdmin(context *gin.Context) {
 			}
 			context.JSON(http.StatusBadRequest, response)
 			return
-		} else if admin.Token == "none" {
-			response := models.Reply{
-				Message: "error fetching token",
-				Error:   errors.New("error token does not exist").Error(),
-				Success: false,
-			}
-			context.JSON(http.StatusBadRequest, response)
-			return
 		} else {
-			_, err := UpdateAdminUtil(admin.AdminID, SystemAdmin{
-				Token: "none",
-			})
+			err := auth.RevokeSession(claims.SessionID, admin.AdminID)
 			if err != nil {
 				response := models.Reply{
 					Message: "error login out admin",
@@ -667,3 +844,257 @@ func LogOutAdmin(context *gin.Context) {
 		}
 	}
 }
+
+// change the role of another admin. Their sessions are revoked so that the
+// new role applies from their next sign in
+func SetAdminRole(context *gin.Context) {
+	var input AdminRoleInput
+	if err := context.ShouldBindJSON(&input); err != nil {
+		response := models.Reply{
+			Message: "could not bind json data from user",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	input.Role = strings.ToLower(strings.TrimSpace(input.Role))
+	id := strings.ReplaceAll(context.Query("id"), "'", "")
+
+	currentAdmin, err := CurrentUser(context)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "error authenticating admin",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	} else if currentAdmin.AdminID == id {
+		response := models.Reply{
+			Error:   errors.New("cannot change own role").Error(),
+			Message: "admins cannot change their own role",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	} else if !auth.IsValidRole(input.Role) {
+		response := models.Reply{
+			Error:   errors.New("unknown admin role").Error(),
+			Message: "role should be one of superadmin, moderator or finance",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	adminExists, err := FindAdminById(id)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "error fetching admin",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	} else if adminExists.AdminName == "" {
+		response := models.Reply{
+			Error:   errors.New("admin not found").Error(),
+			Message: "admin does not exist",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	err = ChangeAdminRole(currentAdmin.AdminID, adminExists, input.Role)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "could not update the admin role",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	response := models.Reply{
+		Message: "admin role updated",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+// products waiting for review, oldest first
+func GetModerationQueue(context *gin.Context) {
+	request, err := pagination.ParseRequest(context, "date", false)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "invalid pagination parameters",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	_, page, err := product.FetchModerationQueuePage(request)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "error fetching the moderation queue",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Data:    page,
+		Message: "succesfully fetched the moderation queue",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+// approve, reject with a reason or send a product back for edits
+func ModerateProduct(context *gin.Context) {
+	claims, err := auth.ValidateToken(context)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "error getting admin",
+			Success: false,
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return
+	}
+	var input product.ModerationInput
+	if err := context.ShouldBindJSON(&input); err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "could not bind data",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	moderated, err := product.ModerateProduct(context.Query("id"), claims.Subject, input)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "could not moderate the product",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Data:    moderated,
+		Message: "product is now " + strings.ReplaceAll(moderated.ModerationStatus, "_", " "),
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+func GetModerationHistory(context *gin.Context) {
+	history, err := product.FetchModerationHistory(context.Query("id"))
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "error fetching the moderation history",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Data:    history,
+		Message: "succesfully fetched the moderation history",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+func SuspendProduct(context *gin.Context) {
+	claims, err := auth.ValidateToken(context)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "error getting admin",
+			Success: false,
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return
+	}
+	var input product.SuspendInput
+	if err := context.ShouldBindJSON(&input); err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "could not bind data",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	suspended, err := product.SuspendProduct(context.Query("id"), claims.Subject, input)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "could not suspend the product",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Data:    suspended,
+		Message: "product suspended",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+func UnsuspendProduct(context *gin.Context) {
+	claims, err := auth.ValidateToken(context)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "error getting admin",
+			Success: false,
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return
+	}
+	// the note is optional so an empty body is fine
+	var input product.UnsuspendInput
+	if context.Request.ContentLength > 0 {
+		if err := context.ShouldBindJSON(&input); err != nil {
+			response := models.Reply{
+				Error:   err.Error(),
+				Message: "could not bind data",
+				Success: false,
+			}
+			context.JSON(http.StatusBadRequest, response)
+			return
+		}
+	}
+
+	unsuspended, err := product.UnsuspendProduct(context.Query("id"), claims.Subject, input)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "could not unsuspend the product",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Data:    unsuspended,
+		Message: "product unsuspended",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
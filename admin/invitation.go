@@ -0,0 +1,281 @@
+package admin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"eleliafrika.com/backend/auth"
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	inviteAudience         = "admin-invite"
+	defaultInvitationTTL   = 72 * time.Hour
+	AuditAdminBootstrapped = "admin.bootstrapped"
+	AuditInviteCreated     = "invite.created"
+	AuditInviteRevoked     = "invite.revoked"
+	AuditInviteAccepted    = "invite.accepted"
+	AuditRoleChanged       = "admin.role_changed"
+)
+
+// invitation for a new admin. Only a hash of the signed token is kept so a
+// token stops working once the invitation is accepted or revoked
+type AdminInvitation struct {
+	gorm.Model
+	InvitationID    string     `gorm:"column:invitation_id;not null;unique" json:"invitationid"`
+	Email           string     `gorm:"column:email;size:255;not null;index" json:"email"`
+	Role            string     `gorm:"column:role;not null" json:"role"`
+	InvitedBy       string     `gorm:"column:invited_by;not null" json:"invitedby"`
+	TokenHash       string     `gorm:"column:token_hash;not null" json:"-"`
+	ExpiresAt       time.Time  `gorm:"column:expires_at;not null" json:"expiresat"`
+	AcceptedAt      *time.Time `gorm:"column:accepted_at" json:"acceptedat"`
+	AcceptedAdminID string     `gorm:"column:accepted_admin_id" json:"acceptedadminid"`
+	RevokedAt       *time.Time `gorm:"column:revoked_at" json:"revokedat"`
+}
+
+// record of a change made to the admin accounts and who made it
+type AdminAudit struct {
+	gorm.Model
+	AuditID       string `gorm:"column:audit_id;not null;unique" json:"auditid"`
+	ActorID       string `gorm:"column:actor_id;index" json:"actorid"`
+	Action        string `gorm:"column:action;not null" json:"action"`
+	TargetEmail   string `gorm:"column:target_email" json:"targetemail"`
+	TargetAdminID string `gorm:"column:target_admin_id" json:"targetadminid"`
+	Role          string `gorm:"column:role" json:"role"`
+}
+
+type InviteInput struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type AcceptInviteInput struct {
+	Token      string `json:"token"`
+	AdminName  string `json:"adminname"`
+	Cell       string `json:"cell"`
+	Password   string `json:"password"`
+	AdminImage string `json:"adminimage"`
+}
+
+func MigrateAdmins() error {
+	return database.Database.AutoMigrate(&AdminInvitation{}, &AdminAudit{})
+}
+
+func RecordAudit(tx *gorm.DB, audit AdminAudit) error {
+	audit.AuditID = uuid.New().String()
+	return tx.Create(&audit).Error
+}
+
+var auditSorts = map[string]pagination.Sort[AdminAudit]{
+	"date": {
+		Column: "created_at",
+		Value:  func(audit AdminAudit) interface{} { return audit.CreatedAt },
+	},
+}
+
+func FetchAuditPage(request pagination.Request) ([]AdminAudit, pagination.Page, error) {
+	query := database.Database.Model(&AdminAudit{})
+	return pagination.Fetch(query, request, auditSorts, func(audit AdminAudit) uint { return audit.ID })
+}
+
+func invitationTTL() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("ADMIN_INVITE_TTL"))
+	if err != nil || seconds <= 0 {
+		return defaultInvitationTTL
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func hashInviteToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// create an invitation and the signed token to send to the invitee. Any
+// earlier pending invitation for the same email is revoked
+func CreateInvitation(invitedBy string, email string, role string) (AdminInvitation, string, error) {
+	invitation := AdminInvitation{
+		InvitationID: uuid.New().String(),
+		Email:        email,
+		Role:         role,
+		InvitedBy:    invitedBy,
+		ExpiresAt:    time.Now().Add(invitationTTL()),
+	}
+	token, err := auth.GenerateActionToken(invitation.InvitationID, inviteAudience, invitation.ExpiresAt)
+	if err != nil {
+		return AdminInvitation{}, "", err
+	}
+	invitation.TokenHash = hashInviteToken(token)
+
+	err = database.Database.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&AdminInvitation{}).
+			Where("email=? AND accepted_at IS NULL AND revoked_at IS NULL", email).
+			Update("revoked_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&invitation).Error; err != nil {
+			return err
+		}
+		return RecordAudit(tx, AdminAudit{ActorID: invitedBy, Action: AuditInviteCreated, TargetEmail: email, Role: role})
+	})
+	if err != nil {
+		return AdminInvitation{}, "", err
+	}
+	return invitation, token, nil
+}
+
+// find the pending invitation a token was issued for
+func FindInvitationByToken(token string) (AdminInvitation, error) {
+	id, err := auth.ParseActionToken(strings.TrimSpace(token), inviteAudience)
+	if err != nil {
+		return AdminInvitation{}, err
+	}
+
+	var invitation AdminInvitation
+	err = database.Database.Where("invitation_id=? AND token_hash=?", id, hashInviteToken(strings.TrimSpace(token))).Find(&invitation).Error
+	if err != nil {
+		return AdminInvitation{}, err
+	} else if invitation.InvitationID == "" {
+		return AdminInvitation{}, errors.New("invitation not found")
+	} else if invitation.AcceptedAt != nil {
+		return AdminInvitation{}, errors.New("invitation has already been used")
+	} else if invitation.RevokedAt != nil {
+		return AdminInvitation{}, errors.New("invitation has been revoked")
+	} else if time.Now().After(invitation.ExpiresAt) {
+		return AdminInvitation{}, errors.New("invitation has expired")
+	}
+	return invitation, nil
+}
+
+// create the invited admin and close the invitation in one transaction so
+// that an invitation can only ever be used once
+func AcceptInvitation(invitation AdminInvitation, admin *SystemAdmin) error {
+	return database.Database.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&AdminInvitation{}).
+			Where("invitation_id=? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.InvitationID).
+			Updates(map[string]interface{}{"accepted_at": time.Now(), "accepted_admin_id": admin.AdminID})
+		if result.Error != nil {
+			return result.Error
+		} else if result.RowsAffected == 0 {
+			return errors.New("invitation has already been used")
+		}
+		if err := tx.Create(admin).Error; err != nil {
+			return err
+		}
+		return RecordAudit(tx, AdminAudit{
+			ActorID:       invitation.InvitedBy,
+			Action:        AuditInviteAccepted,
+			TargetEmail:   admin.Email,
+			TargetAdminID: admin.AdminID,
+			Role:          admin.Role,
+		})
+	})
+}
+
+// give an admin a new role, sign them out so their tokens pick it up and
+// record who changed it
+func ChangeAdminRole(actorID string, target SystemAdmin, role string) error {
+	return database.Database.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&SystemAdmin{}).Where("admin_id=?", target.AdminID).UpdateColumn("role", role)
+		if result.Error != nil {
+			return result.Error
+		} else if result.RowsAffected == 0 {
+			return errors.New("could not update the admin role")
+		}
+		if err := auth.RevokeAllSessions(tx, target.AdminID, auth.SubjectAdmin); err != nil {
+			return err
+		}
+		return RecordAudit(tx, AdminAudit{
+			ActorID:       actorID,
+			Action:        AuditRoleChanged,
+			TargetEmail:   target.Email,
+			TargetAdminID: target.AdminID,
+			Role:          role,
+		})
+	})
+}
+
+func RevokeInvitation(actorID string, invitationID string) error {
+	return database.Database.Transaction(func(tx *gorm.DB) error {
+		var invitation AdminInvitation
+		result := tx.Model(&invitation).
+			Where("invitation_id=? AND accepted_at IS NULL AND revoked_at IS NULL", invitationID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		} else if result.RowsAffected == 0 {
+			return errors.New("no pending invitation with that id")
+		}
+		if err := tx.Where("invitation_id=?", invitationID).Find(&invitation).Error; err != nil {
+			return err
+		}
+		return RecordAudit(tx, AdminAudit{ActorID: actorID, Action: AuditInviteRevoked, TargetEmail: invitation.Email, Role: invitation.Role})
+	})
+}
+
+// invitations that have not been accepted or revoked yet, newest first
+func FetchPendingInvitations() ([]AdminInvitation, error) {
+	var invitations []AdminInvitation
+	err := database.Database.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", time.Now()).
+		Order("created_at DESC").Find(&invitations).Error
+	if err != nil {
+		return []AdminInvitation{}, err
+	}
+	return invitations, nil
+}
+
+// create the first super admin from the environment when there are no
+// admins yet, later admins have to be invited
+func BootstrapSuperAdmin() error {
+	email := strings.ToLower(strings.TrimSpace(os.Getenv("ADMIN_BOOTSTRAP_EMAIL")))
+	password := os.Getenv("ADMIN_BOOTSTRAP_PASSWORD")
+	if email == "" || password == "" {
+		return nil
+	} else if err := auth.ValidatePassword(password); err != nil {
+		return fmt.Errorf("ADMIN_BOOTSTRAP_PASSWORD: %v", err)
+	}
+
+	var count int64
+	if err := database.Database.Model(&SystemAdmin{}).Count(&count).Error; err != nil {
+		return err
+	} else if count > 0 {
+		return nil
+	}
+
+	name := os.Getenv("ADMIN_BOOTSTRAP_NAME")
+	if name == "" {
+		name = "Super Admin"
+	}
+	admin := SystemAdmin{
+		AdminID:      uuid.New().String(),
+		AdminName:    name,
+		Email:        email,
+		Cell:         os.Getenv("ADMIN_BOOTSTRAP_CELL"),
+		Password:     password,
+		Role:         auth.RoleSuperAdmin,
+		DateAdded:    time.Now().Format("2006-01-02 15:04:05"),
+		LastLoggedIn: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&admin).Error; err != nil {
+			return err
+		}
+		return RecordAudit(tx, AdminAudit{Action: AuditAdminBootstrapped, TargetEmail: email, TargetAdminID: admin.AdminID, Role: admin.Role})
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("created the first super admin %s", email)
+	return nil
+}
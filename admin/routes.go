@@ -1,7 +1,7 @@
 package admin
 
 import (
-	"eleliafrika.com/backend/users"
+	"eleliafrika.com/backend/auth"
 	"github.com/gin-gonic/gin"
 )
 
@@ -9,14 +9,27 @@ func AdminRoutes(router *gin.Engine) {
 	authRoutes := router.Group("/admin")
 	{
 
-		authRoutes.POST("/register", Register)
+		authRoutes.POST("/invite", auth.RequireAdmin(auth.PermissionManageAdmins), InviteAdmin)
+		authRoutes.GET("/invitations", auth.RequireAdmin(auth.PermissionManageAdmins), GetInvitations)
+		authRoutes.POST("/invitations/revoke", auth.RequireAdmin(auth.PermissionManageAdmins), RevokeAdminInvitation)
+		authRoutes.POST("/acceptinvite", AcceptInvite)
+		authRoutes.GET("/audit", auth.RequireAdmin(auth.PermissionManageAdmins), GetAuditLog)
 		authRoutes.POST("/login", Login)
-		authRoutes.POST("/approveuser", users.JWTAuthMiddleWare(), ApproveUser)
-		authRoutes.GET("/getadmindetails", users.JWTAuthMiddleWare(), GetLoggedInAdmin)
-		authRoutes.POST("/logout", users.JWTAuthMiddleWare(), LogOutAdmin)
-		authRoutes.POST("/updateadmin", users.JWTAuthMiddleWare(), UpdateAdmin)
-		authRoutes.POST("/revokeuser", users.JWTAuthMiddleWare(), RevokeUser)
-		authRoutes.GET("/fetchusers", users.JWTAuthMiddleWare(), FetchSellers)
-		authRoutes.POST("/approveproduct", users.JWTAuthMiddleWare(), ApproveProduct)
+		authRoutes.POST("/approveuser", auth.RequireAdmin(auth.PermissionManageUsers), ApproveUser)
+		authRoutes.GET("/getadmindetails", auth.RequireAdmin(), GetLoggedInAdmin)
+		authRoutes.POST("/logout", auth.RequireAdmin(), LogOutAdmin)
+		authRoutes.POST("/refresh", auth.Refresh(auth.SubjectAdmin))
+		authRoutes.GET("/sessions", auth.RequireAdmin(), auth.ListSessions(auth.SubjectAdmin))
+		authRoutes.POST("/sessions/revoke", auth.RequireAdmin(), auth.RevokeDevice)
+		authRoutes.POST("/updateadmin", auth.RequireAdmin(), UpdateAdmin)
+		authRoutes.POST("/setrole", auth.RequireAdmin(auth.PermissionManageAdmins), SetAdminRole)
+		authRoutes.POST("/revokeuser", auth.RequireAdmin(auth.PermissionManageUsers), RevokeUser)
+		authRoutes.GET("/fetchusers", auth.RequireAdmin(auth.PermissionManageUsers), FetchSellers)
+		authRoutes.POST("/approveproduct", auth.RequireAdmin(auth.PermissionModerateAds), ApproveProduct)
+		authRoutes.GET("/moderation/queue", auth.RequireAdmin(auth.PermissionModerateAds), GetModerationQueue)
+		authRoutes.POST("/moderation/moderate", auth.RequireAdmin(auth.PermissionModerateAds), ModerateProduct)
+		authRoutes.GET("/moderation/history", auth.RequireAdmin(auth.PermissionModerateAds), GetModerationHistory)
+		authRoutes.POST("/suspendproduct", auth.RequireAdmin(auth.PermissionModerateAds), SuspendProduct)
+		authRoutes.POST("/unsuspendproduct", auth.RequireAdmin(auth.PermissionModerateAds), UnsuspendProduct)
 	}
 }
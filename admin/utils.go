@@ -2,22 +2,20 @@ package admin
 
 import (
 	"errors"
-	"os"
 	"regexp"
-	"strconv"
 	"strings"
-	"time"
 	"unicode"
 
+	"eleliafrika.com/backend/auth"
 	"eleliafrika.com/backend/database"
 	"eleliafrika.com/backend/product"
 	"eleliafrika.com/backend/users"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var privateKey = []byte(os.Getenv("JWT_PRIVATE_KEY"))
+// the longest address a mail server will deliver to
+const maxEmailLength = 254
 
 func ValidateRegisterInput(admin *AddAdmin) (bool, error) {
 	details := []string{admin.AdminName, admin.Password, admin.Email, admin.Cell, admin.Role}
@@ -40,6 +38,8 @@ func ValidateRegisterInput(admin *AddAdmin) (bool, error) {
 			admin.Email = strings.ToLower(admin.Email)
 			if len(value) < 8 {
 				return false, errors.New("email should be longer than 8 characters")
+			} else if len(value) > maxEmailLength {
+				return false, errors.New("email is too long")
 			} else if !strings.Contains(admin.Email, "@") {
 				return false, errors.New("email should contain @: " + value)
 			} else if !strings.Contains(admin.Email, ".") {
@@ -60,8 +60,8 @@ func ValidateRegisterInput(admin *AddAdmin) (bool, error) {
 				}
 			}
 		} else if value == admin.Password {
-			if len(value) < 3 {
-				return false, errors.New("password is too short")
+			if err := auth.ValidatePassword(value); err != nil {
+				return false, err
 			}
 		} else if value == admin.Role {
 			admin.Role = strings.ToLower(admin.Role)
@@ -107,11 +107,10 @@ func FetchAllUsersUtil() ([]users.User, error) {
 
 	return AllUsers, nil
 }
-func ApproveAd(id string) (bool, error) {
-	var updatedProduct product.Product
-	result := database.Database.Model(&updatedProduct).Where("product_id=?", id).Update("is_approved", true)
-	if result.RowsAffected == 0 {
-		return false, errors.New("could not approve the current product")
+func ApproveAd(id string, adminId string) (bool, error) {
+	_, err := product.ModerateProduct(id, adminId, product.ModerationInput{Action: product.ActionApprove})
+	if err != nil {
+		return false, err
 	}
 	return true, nil
 }
@@ -126,15 +125,6 @@ func FindAdminByEmail(email string) (SystemAdmin, error) {
 	}
 	return admin, nil
 }
-func GenerateJWT(admin SystemAdmin) (string, error) {
-	tokenTTL, _ := strconv.Atoi(os.Getenv("TOKEN_TTL"))
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"email": admin.Email,
-		"iat":   time.Now().Unix(),
-		"eat":   time.Now().Add(time.Second * time.Duration(tokenTTL)).Unix(),
-	})
-	return token.SignedString(privateKey)
-}
 func (admin *SystemAdmin) ValidatePassword(password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(password))
 }
@@ -154,20 +144,27 @@ func ValidateHashPassword(hashedPassword string, userPassword string) (bool, err
 	}
 	return true, nil
 }
+func FindAdminById(id string) (SystemAdmin, error) {
+	var admin SystemAdmin
+	err := database.Database.Where("admin_id=?", id).Find(&admin).Error
+	if err != nil {
+		return SystemAdmin{}, err
+	}
+	return admin, nil
+}
 func CurrentUser(context *gin.Context) (SystemAdmin, error) {
-	err := users.ValidateJWT(context)
+	claims, err := auth.ValidateToken(context)
 	if err != nil {
 		return SystemAdmin{}, err
+	} else if claims.SubjectType != auth.SubjectAdmin {
+		return SystemAdmin{}, errors.New("token does not belong to an admin")
 	}
-	token, _ := users.GetToken(context)
-	claims, _ := token.Claims.(jwt.MapClaims)
-	useremail := string(claims["email"].(string))
 
-	user, err := FindAdminByEmail(useremail)
+	admin, err := FindAdminById(claims.Subject)
 	if err != nil {
 		return SystemAdmin{}, err
 	}
-	return user, nil
+	return admin, nil
 }
 func UpdateAdminUtil(adminId string, update SystemAdmin) (SystemAdmin, error) {
 	var updatedAdmin SystemAdmin
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"eleliafrika.com/backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+// exchange a refresh token for a new token pair
+func Refresh(subjectType string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var input RefreshInput
+		if err := context.ShouldBindJSON(&input); err != nil {
+			response := models.Reply{
+				Message: "error binding data",
+				Error:   err.Error(),
+				Success: false,
+			}
+			context.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		tokens, err := RefreshSession(strings.TrimSpace(input.RefreshToken), subjectType)
+		if err != nil {
+			response := models.Reply{
+				Message: "could not refresh the session",
+				Error:   err.Error(),
+				Success: false,
+			}
+			context.JSON(http.StatusUnauthorized, response)
+			return
+		}
+
+		response := models.Reply{
+			Message: "session refreshed",
+			Data:    tokens,
+			Success: true,
+		}
+		context.JSON(http.StatusOK, response)
+	}
+}
+
+// list the devices the current user or admin is signed in on
+func ListSessions(subjectType string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		claims, err := ValidateToken(context)
+		if err != nil {
+			response := models.Reply{
+				Message: "error authenticating",
+				Error:   err.Error(),
+				Success: false,
+			}
+			context.JSON(http.StatusUnauthorized, response)
+			return
+		}
+
+		sessions, err := FetchSessions(claims.Subject, subjectType)
+		if err != nil {
+			response := models.Reply{
+				Message: "error fetching sessions",
+				Error:   err.Error(),
+				Success: false,
+			}
+			context.JSON(http.StatusBadRequest, response)
+			return
+		}
+		for i := range sessions {
+			sessions[i].Current = sessions[i].SessionID == claims.SessionID
+		}
+
+		response := models.Reply{
+			Message: "succesfully fetched sessions",
+			Data:    sessions,
+			Success: true,
+		}
+		context.JSON(http.StatusOK, response)
+	}
+}
+
+// sign the current user or admin out of one of their devices
+func RevokeDevice(context *gin.Context) {
+	claims, err := ValidateToken(context)
+	if err != nil {
+		response := models.Reply{
+			Message: "error authenticating",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return
+	}
+
+	id := strings.ReplaceAll(context.Query("id"), "'", "")
+	if id == "" {
+		response := models.Reply{
+			Message: "supply the session id",
+			Error:   errors.New("session id is required").Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	if err := RevokeSession(id, claims.Subject); err != nil {
+		response := models.Reply{
+			Message: "could not revoke the session",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	response := models.Reply{
+		Message: "session revoked",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"errors"
+	"unicode"
+)
+
+const (
+	minPasswordLength = 8
+	// bcrypt ignores anything after 72 bytes
+	maxPasswordBytes = 72
+)
+
+// the password policy for accounts that can sign in
+func ValidatePassword(password string) error {
+	if len([]rune(password)) < minPasswordLength {
+		return errors.New("password should be at least 8 characters long")
+	} else if len(password) > maxPasswordBytes {
+		return errors.New("password is too long")
+	}
+	var upper, lower, number, special bool
+	for _, char := range password {
+		switch {
+		case unicode.IsSpace(char):
+			return errors.New("password cannot contain spaces")
+		case unicode.IsUpper(char):
+			upper = true
+		case unicode.IsLower(char):
+			lower = true
+		case unicode.IsNumber(char):
+			number = true
+		default:
+			special = true
+		}
+	}
+	if !upper || !lower {
+		return errors.New("password should contain both capital and small letters")
+	} else if !number {
+		return errors.New("password should contain a number")
+	} else if !special {
+		return errors.New("password should contain a special character")
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePassword(t *testing.T) {
+	type testCase struct {
+		name     string
+		password string
+		wantErr  bool
+	}
+
+	cases := []testCase{
+		{"valid", "Pass@1234", false},
+		{"too short", "A@1x", true},
+		{"too long", "Pass@1234" + strings.Repeat("a", 70), true},
+		{"no capitals", "pass@1234", true},
+		{"no small letters", "PASS@1234", true},
+		{"no number", "Pass@Pass", true},
+		{"no special character", "Pass1234", true},
+		{"spaces", "Pass @1234", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := ValidatePassword(c.password); (err != nil) != c.wantErr {
+				t.Errorf("expected error=%v got %v", c.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package auth
+
+// something an admin is allowed to do, routes declare the permission they
+// need and roles grant a set of them
+type Permission string
+
+const (
+	PermissionManageAdmins   Permission = "admins:manage"
+	PermissionManageUsers    Permission = "users:manage"
+	PermissionModerateAds    Permission = "ads:moderate"
+	PermissionManageCatalog  Permission = "catalog:manage"
+	PermissionManagePackages Permission = "packages:manage"
+	PermissionViewFinance    Permission = "finance:view"
+)
+
+const (
+	RoleSuperAdmin = "superadmin"
+	RoleModerator  = "moderator"
+	RoleFinance    = "finance"
+)
+
+// permissions granted by each admin role, the super admin can do everything
+var rolePermissions = map[string][]Permission{
+	RoleSuperAdmin: {},
+	RoleModerator: {
+		PermissionManageUsers,
+		PermissionModerateAds,
+		PermissionManageCatalog,
+	},
+	RoleFinance: {
+		PermissionManagePackages,
+		PermissionViewFinance,
+	},
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+func RoleHas(role string, permission Permission) bool {
+	if role == RoleSuperAdmin {
+		return true
+	}
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// check the permission for the caller of a request that has already been
+// through one of the middlewares
+func (claims *Claims) Can(permission Permission) bool {
+	return claims.SubjectType == SubjectAdmin && RoleHas(claims.Role, permission)
+}
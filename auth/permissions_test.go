@@ -0,0 +1,41 @@
+package auth
+
+import "testing"
+
+func TestRoleHas(t *testing.T) {
+	type testCase struct {
+		name       string
+		role       string
+		permission Permission
+		want       bool
+	}
+
+	cases := []testCase{
+		{"super admin can manage admins", RoleSuperAdmin, PermissionManageAdmins, true},
+		{"super admin can view finance", RoleSuperAdmin, PermissionViewFinance, true},
+		{"moderator can moderate ads", RoleModerator, PermissionModerateAds, true},
+		{"moderator cannot manage packages", RoleModerator, PermissionManagePackages, false},
+		{"finance can manage packages", RoleFinance, PermissionManagePackages, true},
+		{"finance cannot manage users", RoleFinance, PermissionManageUsers, false},
+		{"unknown role has no permissions", "basic", PermissionModerateAds, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := RoleHas(c.role, c.permission); got != c.want {
+				t.Errorf("expected %v got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestClaimsCan(t *testing.T) {
+	user := &Claims{SubjectType: SubjectUser, Role: RoleSuperAdmin}
+	if user.Can(PermissionManageUsers) {
+		t.Error("user tokens should never carry admin permissions")
+	}
+	admin := &Claims{SubjectType: SubjectAdmin, Role: RoleModerator}
+	if !admin.Can(PermissionManageUsers) {
+		t.Error("moderators should be able to manage users")
+	}
+}
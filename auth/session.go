@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"eleliafrika.com/backend/database"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	SubjectUser  = "user"
+	SubjectAdmin = "admin"
+)
+
+// a signed in device. Only a hash of the refresh token is stored, the
+// previous hash is kept so that reuse of a rotated token can be detected
+type Session struct {
+	gorm.Model
+	SessionID         string     `gorm:"column:session_id;not null;unique" json:"sessionid"`
+	SubjectID         string     `gorm:"column:subject_id;not null;index" json:"-"`
+	SubjectType       string     `gorm:"column:subject_type;not null" json:"-"`
+	Role              string     `gorm:"column:role" json:"-"`
+	Device            string     `gorm:"column:device;size:255" json:"device"`
+	IPAddress         string     `gorm:"column:ip_address;size:64" json:"ipaddress"`
+	RefreshTokenHash  string     `gorm:"column:refresh_token_hash;not null;unique" json:"-"`
+	PreviousTokenHash string     `gorm:"column:previous_token_hash;index" json:"-"`
+	ExpiresAt         time.Time  `gorm:"column:expires_at;not null" json:"expiresat"`
+	LastUsedAt        time.Time  `gorm:"column:last_used_at;not null" json:"lastusedat"`
+	RevokedAt         *time.Time `gorm:"column:revoked_at" json:"-"`
+	Current           bool       `gorm:"-" json:"current"`
+}
+
+type TokenPair struct {
+	AccessToken  string    `json:"token"`
+	RefreshToken string    `json:"refreshtoken"`
+	ExpiresAt    time.Time `json:"expiresat"`
+}
+
+type RefreshInput struct {
+	RefreshToken string `json:"refreshtoken"`
+}
+
+func MigrateSessions() error {
+	return database.Database.AutoMigrate(&Session{})
+}
+
+func newRefreshToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// name of the device a request comes from, clients can name themselves
+// with the x-device-name header otherwise the user agent is used
+func deviceName(context *gin.Context) string {
+	device := context.Request.Header.Get("x-device-name")
+	if device == "" {
+		device = context.Request.UserAgent()
+	}
+	if len(device) > 255 {
+		device = device[:255]
+	}
+	return device
+}
+
+// start a new session for a user or admin that has just signed in, the role
+// is copied into every access token issued for the session
+func IssueSession(subjectID string, subjectType string, role string, context *gin.Context) (TokenPair, error) {
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	now := time.Now()
+	session := Session{
+		SessionID:        uuid.New().String(),
+		SubjectID:        subjectID,
+		SubjectType:      subjectType,
+		Role:             role,
+		Device:           deviceName(context),
+		IPAddress:        context.ClientIP(),
+		RefreshTokenHash: hashRefreshToken(refreshToken),
+		ExpiresAt:        now.Add(RefreshTokenTTL()),
+		LastUsedAt:       now,
+	}
+	if err := database.Database.Create(&session).Error; err != nil {
+		return TokenPair{}, err
+	}
+
+	accessToken, expiresAt, err := GenerateAccessToken(session, now)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{AccessToken: accessToken, RefreshToken: refreshToken, ExpiresAt: expiresAt}, nil
+}
+
+// swap a refresh token for a new access and refresh token. A refresh token
+// can only be used once, presenting an already rotated token revokes the
+// whole session since it may have been stolen
+func RefreshSession(refreshToken string, subjectType string) (TokenPair, error) {
+	if refreshToken == "" {
+		return TokenPair{}, errors.New("refresh token is required")
+	}
+	hash := hashRefreshToken(refreshToken)
+
+	var session Session
+	err := database.Database.Where("refresh_token_hash=? AND subject_type=?", hash, subjectType).Find(&session).Error
+	if err != nil {
+		return TokenPair{}, err
+	} else if session.SessionID == "" {
+		var reused Session
+		err := database.Database.Where("previous_token_hash=? AND subject_type=?", hash, subjectType).Find(&reused).Error
+		if err == nil && reused.SessionID != "" {
+			revokeSessions(database.Database.Where("session_id=?", reused.SessionID))
+			return TokenPair{}, errors.New("refresh token was already used, please sign in again")
+		}
+		return TokenPair{}, errors.New("invalid refresh token")
+	}
+
+	now := time.Now()
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		return TokenPair{}, errors.New("session has expired or was revoked")
+	}
+
+	newToken, err := newRefreshToken()
+	if err != nil {
+		return TokenPair{}, err
+	}
+	// the old hash is part of the condition so that two refreshes racing
+	// with the same token cannot both succeed
+	result := database.Database.Model(&Session{}).
+		Where("session_id=? AND refresh_token_hash=?", session.SessionID, hash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  hashRefreshToken(newToken),
+			"previous_token_hash": hash,
+			"last_used_at":        now,
+		})
+	if result.Error != nil {
+		return TokenPair{}, result.Error
+	} else if result.RowsAffected == 0 {
+		return TokenPair{}, errors.New("refresh token was already used, please sign in again")
+	}
+
+	accessToken, expiresAt, err := GenerateAccessToken(session, now)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{AccessToken: accessToken, RefreshToken: newToken, ExpiresAt: expiresAt}, nil
+}
+
+func IsSessionActive(sessionID string, subjectID string) (bool, error) {
+	var count int64
+	err := database.Database.Model(&Session{}).
+		Where("session_id=? AND subject_id=? AND revoked_at IS NULL AND expires_at > ?", sessionID, subjectID, time.Now()).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// active sessions of a user or admin, most recently used first
+func FetchSessions(subjectID string, subjectType string) ([]Session, error) {
+	var sessions []Session
+	err := database.Database.
+		Where("subject_id=? AND subject_type=? AND revoked_at IS NULL AND expires_at > ?", subjectID, subjectType, time.Now()).
+		Order("last_used_at DESC").Find(&sessions).Error
+	if err != nil {
+		return []Session{}, err
+	}
+	return sessions, nil
+}
+
+func revokeSessions(query *gorm.DB) (int64, error) {
+	result := query.Model(&Session{}).Where("revoked_at IS NULL").Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// revoke one session of a user or admin
+func RevokeSession(sessionID string, subjectID string) error {
+	revoked, err := revokeSessions(database.Database.Where("session_id=? AND subject_id=?", sessionID, subjectID))
+	if err != nil {
+		return err
+	} else if revoked == 0 {
+		return errors.New("session not found")
+	}
+	return nil
+}
+
+// sign a user or admin out of every device, inside the transaction of the
+// change that calls for it
+func RevokeAllSessions(tx *gorm.DB, subjectID string, subjectType string) error {
+	_, err := revokeSessions(tx.Where("subject_id=? AND subject_type=?", subjectID, subjectType))
+	return err
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// access tokens are short lived, clients use the refresh token to get
+	// a new one when it expires
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+	claimsKey = "auth_claims"
+)
+
+// claims carried by every access token. The subject is the id of the user
+// or admin, SubjectType says which of the two it is and SessionID ties the
+// token to a row in the sessions table. Role is only set for admins
+type Claims struct {
+	SessionID   string `json:"sid"`
+	SubjectType string `json:"typ"`
+	Role        string `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// the key is read on use since the env file is loaded after package init
+func signingKey() []byte {
+	return []byte(os.Getenv("JWT_PRIVATE_KEY"))
+}
+
+// read a duration in seconds from the environment
+func ttlFromEnv(name string, fallback time.Duration) time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || seconds <= 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func AccessTokenTTL() time.Duration {
+	return ttlFromEnv("TOKEN_TTL", defaultAccessTokenTTL)
+}
+
+func RefreshTokenTTL() time.Duration {
+	return ttlFromEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL)
+}
+
+func GenerateAccessToken(session Session, now time.Time) (string, time.Time, error) {
+	expiresAt := now.Add(AccessTokenTTL())
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		SessionID:   session.SessionID,
+		SubjectType: session.SubjectType,
+		Role:        session.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   session.SubjectID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+	signed, err := token.SignedString(signingKey())
+	return signed, expiresAt, err
+}
+
+// check the signature and expiry of a raw access token
+func ParseAccessToken(raw string) (*Claims, error) {
+	if raw == "" {
+		return nil, errors.New("no token provided")
+	}
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return signingKey(), nil
+	})
+	if err != nil {
+		return nil, errors.New("invalid token provided")
+	} else if claims.ExpiresAt == nil || claims.Subject == "" || claims.SessionID == "" || claims.SubjectType == "" {
+		return nil, errors.New("invalid token provided")
+	}
+	return claims, nil
+}
+
+// validate the token sent in the request and make sure its session has not
+// been revoked. The claims are kept on the context for later calls
+func ValidateToken(context *gin.Context) (*Claims, error) {
+	if value, ok := context.Get(claimsKey); ok {
+		return value.(*Claims), nil
+	}
+
+	claims, err := ParseAccessToken(context.Request.Header.Get("x-access-token"))
+	if err != nil {
+		return nil, err
+	}
+	active, err := IsSessionActive(claims.SessionID, claims.Subject)
+	if err != nil {
+		return nil, err
+	} else if !active {
+		return nil, errors.New("session has expired or was revoked")
+	}
+
+	context.Set(claimsKey, claims)
+	return claims, nil
+}
+
+// validate the token and check the claims of the caller against the route
+func authorize(allowed func(claims *Claims) bool) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		claims, err := ValidateToken(context)
+		if err != nil {
+			context.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			context.Abort()
+			return
+		} else if !allowed(claims) {
+			context.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+			context.Abort()
+			return
+		}
+		context.Next()
+	}
+}
+
+// any signed in user or admin
+func JWTAuthMiddleWare() gin.HandlerFunc {
+	return authorize(func(claims *Claims) bool { return true })
+}
+
+// signed in sellers and buyers only
+func RequireUser() gin.HandlerFunc {
+	return authorize(func(claims *Claims) bool { return claims.SubjectType == SubjectUser })
+}
+
+// signed in admins whose role grants every one of the permissions, with no
+// permissions any admin is let through
+func RequireAdmin(permissions ...Permission) gin.HandlerFunc {
+	return authorize(func(claims *Claims) bool {
+		if claims.SubjectType != SubjectAdmin {
+			return false
+		}
+		for _, permission := range permissions {
+			if !RoleHas(claims.Role, permission) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// short lived signed tokens for one off actions such as accepting an admin
+// invitation. The audience keeps them from being used anywhere else
+func GenerateActionToken(subject string, audience string, expiresAt time.Time) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   subject,
+		Audience:  jwt.ClaimStrings{audience},
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	})
+	return token.SignedString(signingKey())
+}
+
+// check an action token and return its subject
+func ParseActionToken(raw string, audience string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return signingKey(), nil
+	})
+	if err != nil || claims.ExpiresAt == nil || !claims.VerifyAudience(audience, true) {
+		return "", errors.New("invalid or expired token")
+	}
+	return claims.Subject, nil
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestParseAccessToken(t *testing.T) {
+	t.Setenv("JWT_PRIVATE_KEY", "test-key")
+	t.Setenv("TOKEN_TTL", "60")
+
+	session := Session{SessionID: "session-1", SubjectID: "user-1", SubjectType: SubjectUser}
+	valid, _, err := GenerateAccessToken(session, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _, err := GenerateAccessToken(session, time.Now().Add(-2*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	withoutExpiry, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		SessionID:        "session-1",
+		SubjectType:      SubjectUser,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1"},
+	}).SignedString([]byte("test-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	withoutType, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		SessionID: "session-1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user-1",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}).SignedString([]byte("test-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		SessionID:   "session-1",
+		SubjectType: SubjectUser,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user-1",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}).SignedString([]byte("other-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type testCase struct {
+		name    string
+		token   string
+		wantErr bool
+	}
+	cases := []testCase{
+		{"valid token", valid, false},
+		{"expired token", expired, true},
+		{"token without expiry", withoutExpiry, true},
+		{"token without subject type", withoutType, true},
+		{"token signed with another key", otherKey, true},
+		{"empty token", "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			claims, err := ParseAccessToken(c.token)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v got %v", c.wantErr, err)
+			}
+			if err == nil && (claims.Subject != "user-1" || claims.SessionID != "session-1" || claims.SubjectType != SubjectUser) {
+				t.Errorf("unexpected claims %+v", claims)
+			}
+		})
+	}
+}
+
+func TestTTLFromEnv(t *testing.T) {
+	t.Setenv("TOKEN_TTL", "")
+	if got := AccessTokenTTL(); got != defaultAccessTokenTTL {
+		t.Errorf("expected the default ttl got %v", got)
+	}
+	t.Setenv("TOKEN_TTL", "-5")
+	if got := AccessTokenTTL(); got != defaultAccessTokenTTL {
+		t.Errorf("expected the default ttl got %v", got)
+	}
+	t.Setenv("TOKEN_TTL", "300")
+	if got := AccessTokenTTL(); got != 5*time.Minute {
+		t.Errorf("expected 5m got %v", got)
+	}
+}
+
+func TestParseActionToken(t *testing.T) {
+	t.Setenv("JWT_PRIVATE_KEY", "test-key")
+
+	token, err := GenerateActionToken("invite-1", "admin-invite", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := GenerateActionToken("invite-1", "admin-invite", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if subject, err := ParseActionToken(token, "admin-invite"); err != nil || subject != "invite-1" {
+		t.Errorf("expected invite-1 got %q %v", subject, err)
+	}
+	if _, err := ParseActionToken(token, "password-reset"); err == nil {
+		t.Error("token should not be accepted for another audience")
+	}
+	if _, err := ParseActionToken(expired, "admin-invite"); err == nil {
+		t.Error("expired token should not be accepted")
+	}
+	if _, err := ParseAccessToken(token); err == nil {
+		t.Error("action tokens should not work as access tokens")
+	}
+}
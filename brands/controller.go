@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/pagination"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -87,7 +88,18 @@ func AddBrand(context *gin.Context) {
 }
 
 func GetAllBrands(context *gin.Context) {
-	brands, err := FetchAllBrands()
+	request, err := pagination.ParseRequest(context, "name", false)
+	if err != nil {
+		response := models.Reply{
+			Message: "invalid pagination parameters",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	_, page, err := FetchBrandsPage(request)
 	if err != nil {
 		response := models.Reply{
 			Message: "Error fetching brands",
@@ -99,7 +111,7 @@ func GetAllBrands(context *gin.Context) {
 		response := models.Reply{
 			Message: "fetched all brands",
 			Success: true,
-			Data:    brands,
+			Data:    page,
 		}
 		context.JSON(http.StatusOK, response)
 	}
@@ -1,15 +1,15 @@
 package brands
 
 import (
-	"eleliafrika.com/backend/users"
+	"eleliafrika.com/backend/auth"
 	"github.com/gin-gonic/gin"
 )
 
 func BrandRoutes(router *gin.Engine) {
 	brandroutes := router.Group("/brands")
 	{
-		brandroutes.POST("/addbrand", users.JWTAuthMiddleWare(), AddBrand)
+		brandroutes.POST("/addbrand", auth.RequireAdmin(auth.PermissionManageCatalog), AddBrand)
 		brandroutes.GET("/getbrands", GetAllBrands)
-		brandroutes.POST("/delete/:name", DeleteBrand)
+		brandroutes.POST("/delete/:name", auth.RequireAdmin(auth.PermissionManageCatalog), DeleteBrand)
 	}
 }
@@ -6,8 +6,24 @@ import (
 
 	"eleliafrika.com/backend/database"
 	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/pagination"
 )
 
+var brandSorts = map[string]pagination.Sort[models.Brand]{
+	"name": {
+		Column: "brand_name",
+		Value:  func(brand models.Brand) interface{} { return brand.BrandName },
+	},
+	"date": {
+		Column: "created_at",
+		Value:  func(brand models.Brand) interface{} { return brand.CreatedAt },
+	},
+	"products": {
+		Column: "total_products",
+		Value:  func(brand models.Brand) interface{} { return brand.TotalProducts },
+	},
+}
+
 func FetchAllBrands() ([]models.Brand, error) {
 	var brands []models.Brand
 	err := database.Database.Where("is_deleted=?", false).Find(&brands).Error
@@ -18,6 +34,11 @@ func FetchAllBrands() ([]models.Brand, error) {
 	return brands, nil
 }
 
+func FetchBrandsPage(request pagination.Request) ([]models.Brand, pagination.Page, error) {
+	query := database.Database.Model(&models.Brand{}).Where("is_deleted=?", false)
+	return pagination.Fetch(query, request, brandSorts, func(brand models.Brand) uint { return brand.ID })
+}
+
 func FetchSingleBrand(brandname string) (models.Brand, error) {
 	var brand models.Brand
 	err := database.Database.Where("is_deleted=?", false).Where("brand_name=?", brandname).Find(&brand).Error
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/pagination"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -98,7 +99,18 @@ func CreateCategory(context *gin.Context) {
 }
 
 func GetCategories(context *gin.Context) {
-	categories, err := FetchAllCategories()
+	request, err := pagination.ParseRequest(context, "name", false)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "invalid pagination parameters",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	_, page, err := FetchCategoriesPage(request)
 	if err != nil {
 		response := models.Reply{
 			Error:   err.Error(),
@@ -110,7 +122,7 @@ func GetCategories(context *gin.Context) {
 		response := models.Reply{
 			Message: "fetched categories succesful",
 			Success: true,
-			Data:    categories,
+			Data:    page,
 		}
 		context.JSON(http.StatusOK, response)
 	}
@@ -1,16 +1,16 @@
 package category
 
 import (
-	"eleliafrika.com/backend/users"
+	"eleliafrika.com/backend/auth"
 	"github.com/gin-gonic/gin"
 )
 
 func CategoryRoutes(router *gin.Engine) {
 	categoryRoutes := router.Group("/categories")
 	{
-		categoryRoutes.POST("/addcategory", users.JWTAuthMiddleWare(), CreateCategory)
+		categoryRoutes.POST("/addcategory", auth.RequireAdmin(auth.PermissionManageCatalog), CreateCategory)
 		categoryRoutes.GET("/getcategories", GetCategories)
-		categoryRoutes.POST("/delete/:name", users.JWTAuthMiddleWare(), DeleteCategory)
+		categoryRoutes.POST("/delete/:name", auth.RequireAdmin(auth.PermissionManageCatalog), DeleteCategory)
 		// categoryRoutes.POST("/delete/:name", users.JWTAuthMiddleWare(), DeleteCategory)
 	}
 }
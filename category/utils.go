@@ -6,8 +6,24 @@ import (
 
 	"eleliafrika.com/backend/database"
 	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/pagination"
 )
 
+var categorySorts = map[string]pagination.Sort[models.Category]{
+	"name": {
+		Column: "category_name",
+		Value:  func(category models.Category) interface{} { return category.CategoryName },
+	},
+	"date": {
+		Column: "created_at",
+		Value:  func(category models.Category) interface{} { return category.CreatedAt },
+	},
+	"products": {
+		Column: "total_products",
+		Value:  func(category models.Category) interface{} { return category.TotalProducts },
+	},
+}
+
 func FetchAllCategories() ([]models.Category, error) {
 	var categories []models.Category
 	err := database.Database.Where("is_deleted", false).Find(&categories).Error
@@ -17,6 +33,11 @@ func FetchAllCategories() ([]models.Category, error) {
 	return categories, nil
 }
 
+func FetchCategoriesPage(request pagination.Request) ([]models.Category, pagination.Page, error) {
+	query := database.Database.Model(&models.Category{}).Where("is_deleted", false)
+	return pagination.Fetch(query, request, categorySorts, func(category models.Category) uint { return category.ID })
+}
+
 func FetchSingleCategory(categoryname string) (models.Category, error) {
 	var category models.Category
 	err := database.Database.Where("category_name=?", categoryname).Find(&category).Error
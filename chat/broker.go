@@ -0,0 +1,94 @@
+package chat
+
+import "sync"
+
+const (
+	EventMessage = "message"
+	EventTyping  = "typing"
+	EventRead    = "read"
+)
+
+// something that happened in a conversation, delivered to every recipient
+// that is connected
+type Event struct {
+	Type           string   `json:"type"`
+	ConversationID string   `json:"conversation_id"`
+	SenderID       string   `json:"sender_id"`
+	Chat           *Chat    `json:"chat,omitempty"`
+	Recipients     []string `json:"-"`
+}
+
+// stream of events for one connected user, Close has to be called when the
+// connection goes away
+type Subscription struct {
+	Events <-chan Event
+	Close  func()
+}
+
+// fans events out to connected users. The in-process broker only reaches
+// connections on this instance, a pub/sub backed broker can be swapped in
+// with SetBroker when running more than one
+type Broker interface {
+	Publish(event Event) error
+	Subscribe(userID string) Subscription
+}
+
+var broker Broker = NewMemoryBroker()
+
+func SetBroker(b Broker) {
+	broker = b
+}
+
+// events are dropped for subscribers whose buffer is full rather than
+// holding up everyone else, clients catch up through GetMessages
+const subscriberBuffer = 32
+
+type memoryBroker struct {
+	mutex       sync.RWMutex
+	subscribers map[string]map[chan Event]struct{}
+}
+
+func NewMemoryBroker() Broker {
+	return &memoryBroker{subscribers: map[string]map[chan Event]struct{}{}}
+}
+
+func (b *memoryBroker) Publish(event Event) error {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	for _, userID := range event.Recipients {
+		for events := range b.subscribers[userID] {
+			select {
+			case events <- event:
+			default:
+			}
+		}
+	}
+	return nil
+}
+
+func (b *memoryBroker) Subscribe(userID string) Subscription {
+	events := make(chan Event, subscriberBuffer)
+
+	b.mutex.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = map[chan Event]struct{}{}
+	}
+	b.subscribers[userID][events] = struct{}{}
+	b.mutex.Unlock()
+
+	var once sync.Once
+	return Subscription{
+		Events: events,
+		Close: func() {
+			once.Do(func() {
+				b.mutex.Lock()
+				delete(b.subscribers[userID], events)
+				if len(b.subscribers[userID]) == 0 {
+					delete(b.subscribers, userID)
+				}
+				b.mutex.Unlock()
+				close(events)
+			})
+		},
+	}
+}
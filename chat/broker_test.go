@@ -0,0 +1,71 @@
+package chat
+
+import (
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, subscription Subscription) (Event, bool) {
+	t.Helper()
+	select {
+	case event, ok := <-subscription.Events:
+		return event, ok
+	case <-time.After(100 * time.Millisecond):
+		return Event{}, false
+	}
+}
+
+func TestMemoryBroker(t *testing.T) {
+	broker := NewMemoryBroker()
+	seller := broker.Subscribe("seller")
+	sellerPhone := broker.Subscribe("seller")
+	buyer := broker.Subscribe("buyer")
+	defer buyer.Close()
+
+	broker.Publish(Event{Type: EventTyping, ConversationID: "c1", SenderID: "buyer", Recipients: []string{"seller"}})
+
+	for _, subscription := range []Subscription{seller, sellerPhone} {
+		event, ok := receive(t, subscription)
+		if !ok || event.Type != EventTyping || event.ConversationID != "c1" {
+			t.Errorf("expected the typing event on every connection of the seller got %+v", event)
+		}
+	}
+	if event, ok := receive(t, buyer); ok {
+		t.Errorf("buyer should not receive their own typing event got %+v", event)
+	}
+
+	sellerPhone.Close()
+	sellerPhone.Close()
+	if _, ok := <-sellerPhone.Events; ok {
+		t.Error("events should be closed after the subscription is closed")
+	}
+
+	broker.Publish(Event{Type: EventRead, ConversationID: "c1", SenderID: "buyer", Recipients: []string{"seller"}})
+	if event, ok := receive(t, seller); !ok || event.Type != EventRead {
+		t.Errorf("expected the read event got %+v", event)
+	}
+	seller.Close()
+}
+
+func TestMemoryBrokerDropsForSlowSubscribers(t *testing.T) {
+	broker := NewMemoryBroker()
+	slow := broker.Subscribe("seller")
+	defer slow.Close()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < subscriberBuffer*2; i++ {
+			broker.Publish(Event{Type: EventTyping, Recipients: []string{"seller"}})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publishing should not block on a full subscriber")
+	}
+	if len(slow.Events) != subscriberBuffer {
+		t.Errorf("expected %d buffered events got %d", subscriberBuffer, len(slow.Events))
+	}
+}
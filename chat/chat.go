@@ -2,6 +2,8 @@ package chat
 
 import (
 	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/users"
 	"gorm.io/gorm"
 )
 
@@ -16,12 +18,33 @@ type Chat struct {
 	IsViewed       bool   `gorm:"column:is_viewed;default:false" json:"is_viewed"`
 }
 type ChatInput struct {
-	ReceiverId string `json:"receiver_id"`
-	Message    string `json:"message_body"`
+	Message string `json:"message_body"`
 }
 
+// save the message and update the summary and the receiver's unread counts
+// in the same transaction so they never disagree
 func (chat *Chat) Save() (*Chat, error) {
-	err := database.Database.Create(&chat).Error
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&chat).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&models.Conversation{}).
+			Where("conversation_id=?", chat.ConversationId).
+			Updates(map[string]interface{}{
+				"last_message":    chat.Message,
+				"messages_count":  gorm.Expr("messages_count + 1"),
+				"is_viewed":       false,
+				"customer_unread": gorm.Expr("CASE WHEN customer_id = ? THEN customer_unread + 1 ELSE customer_unread END", chat.ReceiverId),
+				"seller_unread":   gorm.Expr("CASE WHEN seller_id = ? THEN seller_unread + 1 ELSE seller_unread END", chat.ReceiverId),
+			}).Error
+		if err != nil {
+			return err
+		}
+		// update column skips the user hooks which would hash the password
+		return tx.Model(&users.User{}).
+			Where("user_id=?", chat.ReceiverId).
+			UpdateColumn("chats", gorm.Expr("chats + 1")).Error
+	})
 	if err != nil {
 		return &Chat{}, err
 	}
@@ -4,12 +4,11 @@ import (
 	"errors"
 	"net/http"
 	"strings"
-	"time"
 
+	"eleliafrika.com/backend/database"
 	"eleliafrika.com/backend/models"
 	"eleliafrika.com/backend/users"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 func SendMessage(context *gin.Context) {
@@ -27,10 +26,6 @@ func SendMessage(context *gin.Context) {
 		return
 	}
 
-	chatuuid := uuid.New()
-	currentTime := time.Now()
-	formattedTime := currentTime.Format("2000-01-02 15:04:00")
-
 	// get the current user
 	user, err := users.CurrentUser(context)
 
@@ -55,17 +50,7 @@ func SendMessage(context *gin.Context) {
 		return
 	}
 
-	chat := Chat{
-		ChatID:         chatuuid.String(),
-		ConversationId: strings.ReplaceAll(conversationid, "'", ""),
-		SenderID:       user.UserID,
-		ReceiverId:     chatInput.ReceiverId,
-		Message:        chatInput.Message,
-		TimeSent:       formattedTime,
-		IsViewed:       false,
-	}
-
-	_, err = chat.Save()
+	chat, err := SendChat(user.UserID, strings.ReplaceAll(conversationid, "'", ""), chatInput.Message)
 
 	if err != nil {
 		response := models.Reply{
@@ -79,6 +64,7 @@ func SendMessage(context *gin.Context) {
 
 	response := models.Reply{
 		Message: "text sent successfully",
+		Data:    chat,
 		Success: true,
 	}
 	context.JSON(http.StatusOK, response)
@@ -109,7 +95,19 @@ func GetMessages(context *gin.Context) {
 		return
 	}
 
-	chats, err := GetChats(strings.ReplaceAll(conversationid, "'", ""))
+	conversationid = strings.ReplaceAll(conversationid, "'", "")
+	_, err = models.FindUserConversation(database.Database, conversationid, user.UserID)
+	if err != nil {
+		response := models.Reply{
+			Message: "error fetching chats",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	chats, err := GetChats(conversationid)
 	if err != nil {
 		response := models.Reply{
 			Message: "error fetching chats",
@@ -137,3 +135,27 @@ func DeleteRequest(context *gin.Context) {
 	}
 	context.JSON(http.StatusOK, response)
 }
+
+// token to open /chats/ws or /chats/events with
+func CreateStreamToken(context *gin.Context) {
+	user, ok := users.CurrentUserOrReply(context)
+	if !ok {
+		return
+	}
+	token, err := IssueStreamToken(user.UserID)
+	if err != nil {
+		response := models.Reply{
+			Message: "could not create a stream token",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusInternalServerError, response)
+		return
+	}
+	response := models.Reply{
+		Message: "stream token created",
+		Success: true,
+		Data:    token,
+	}
+	context.JSON(http.StatusOK, response)
+}
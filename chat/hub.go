@@ -0,0 +1,283 @@
+package chat
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"eleliafrika.com/backend/auth"
+	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/users"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait  = 10 * time.Second
+	pongWait   = 60 * time.Second
+	pingPeriod = (pongWait * 9) / 10
+	maxFrame   = 8192
+
+	streamAudience = "chat-stream"
+	streamTokenTTL = time.Minute
+)
+
+// short lived token a stream can be opened with from the query, so the
+// access token never ends up in a url or the request logs
+type StreamToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresat"`
+}
+
+// event sent by a client over the socket
+type ClientEvent struct {
+	Type           string `json:"type"`
+	ConversationID string `json:"conversation_id"`
+	Message        string `json:"message_body"`
+}
+
+// gorilla connections support one concurrent writer, events and error
+// replies are written from different goroutines so writes are serialised
+type socket struct {
+	conn  *websocket.Conn
+	mutex sync.Mutex
+}
+
+func (s *socket) writeJSON(value interface{}) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return s.conn.WriteJSON(value)
+}
+
+func (s *socket) writeControl(messageType int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return s.conn.WriteMessage(messageType, nil)
+}
+
+// origins are not restricted for http either, see the cors config
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+func IssueStreamToken(userID string) (StreamToken, error) {
+	expiresAt := time.Now().Add(streamTokenTTL)
+	token, err := auth.GenerateActionToken(userID, streamAudience, expiresAt)
+	if err != nil {
+		return StreamToken{}, err
+	}
+	return StreamToken{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// browsers cannot set headers on websocket and event source requests so a
+// stream token from /chats/streamtoken may be passed as the token query
+// parameter instead of the access token header
+func streamUser(context *gin.Context) (users.User, error) {
+	var user users.User
+	var err error
+	if context.Request.Header.Get("x-access-token") != "" {
+		user, err = users.CurrentUser(context)
+	} else {
+		var userID string
+		userID, err = auth.ParseActionToken(context.Query("token"), streamAudience)
+		if err == nil {
+			user, err = users.FindUserById(userID)
+		}
+	}
+	if err != nil {
+		return users.User{}, err
+	} else if user.UserID == "" {
+		return users.User{}, errors.New("user does not exist")
+	}
+	return user, nil
+}
+
+// upgrade to a websocket that receives the user's chat events and accepts
+// messages, typing indicators and read receipts from the client
+func Connect(context *gin.Context) {
+	user, err := streamUser(context)
+	if err != nil {
+		response := models.Reply{
+			Message: "error authorizing user",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(context.Writer, context.Request, nil)
+	if err != nil {
+		return
+	}
+	client := &socket{conn: conn}
+	subscription := broker.Subscribe(user.UserID)
+
+	go writeEvents(client, subscription)
+	readEvents(client, user.UserID)
+	subscription.Close()
+}
+
+func readEvents(client *socket, userID string) {
+	conn := client.conn
+	defer conn.Close()
+	conn.SetReadLimit(maxFrame)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		var event ClientEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			return
+		}
+		if err := handleClientEvent(userID, event); err != nil {
+			// errors only go back to the sender, the socket stays open
+			client.writeJSON(gin.H{"type": "error", "conversation_id": event.ConversationID, "error": err.Error()})
+		}
+	}
+}
+
+func handleClientEvent(userID string, event ClientEvent) error {
+	conversationID := strings.ReplaceAll(event.ConversationID, "'", "")
+	switch event.Type {
+	case EventMessage:
+		_, err := SendChat(userID, conversationID, event.Message)
+		return err
+	case EventTyping:
+		return PublishTyping(conversationID, userID)
+	case EventRead:
+		return MarkConversationRead(conversationID, userID)
+	default:
+		return errors.New("unknown event type " + event.Type)
+	}
+}
+
+func writeEvents(client *socket, subscription Subscription) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		client.conn.Close()
+	}()
+
+	for {
+		select {
+		case event, ok := <-subscription.Events:
+			if !ok {
+				client.writeControl(websocket.CloseMessage)
+				return
+			}
+			if err := client.writeJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := client.writeControl(websocket.PingMessage); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// server sent events fallback for clients that cannot open a websocket.
+// They send messages, typing and read receipts over plain http instead
+func Events(context *gin.Context) {
+	user, err := streamUser(context)
+	if err != nil {
+		response := models.Reply{
+			Message: "error authorizing user",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return
+	}
+
+	subscription := broker.Subscribe(user.UserID)
+	defer subscription.Close()
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	context.Header("Cache-Control", "no-cache")
+	context.Header("X-Accel-Buffering", "no")
+	context.Stream(func(w io.Writer) bool {
+		select {
+		case <-context.Request.Context().Done():
+			return false
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return false
+			}
+			context.SSEvent(event.Type, event)
+			return true
+		case <-ticker.C:
+			context.SSEvent("ping", "")
+			return true
+		}
+	})
+}
+
+func SendTyping(context *gin.Context) {
+	user, err := users.CurrentUser(context)
+	if err != nil {
+		response := models.Reply{
+			Message: "error authorizing user",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return
+	}
+
+	err = PublishTyping(strings.ReplaceAll(context.Query("id"), "'", ""), user.UserID)
+	if err != nil {
+		response := models.Reply{
+			Message: "could not send typing indicator",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "typing indicator sent",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+func MarkAsRead(context *gin.Context) {
+	user, err := users.CurrentUser(context)
+	if err != nil {
+		response := models.Reply{
+			Message: "error authorizing user",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return
+	}
+
+	err = MarkConversationRead(strings.ReplaceAll(context.Query("id"), "'", ""), user.UserID)
+	if err != nil {
+		response := models.Reply{
+			Message: "could not mark the conversation as read",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "conversation marked as read",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,38 @@
+package chat
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"eleliafrika.com/backend/auth"
+	"github.com/gin-gonic/gin"
+)
+
+func TestStreamToken(t *testing.T) {
+	t.Setenv("JWT_PRIVATE_KEY", "test-key")
+	gin.SetMode(gin.TestMode)
+
+	token, err := IssueStreamToken("user-1")
+	if err != nil {
+		t.Fatal(err)
+	} else if token.ExpiresAt.After(time.Now().Add(streamTokenTTL)) {
+		t.Errorf("expected the token to expire within %v got %v", streamTokenTTL, token.ExpiresAt)
+	}
+	if userID, err := auth.ParseActionToken(token.Token, streamAudience); err != nil || userID != "user-1" {
+		t.Errorf("expected user-1 got %q %v", userID, err)
+	}
+
+	// tokens for anything else cannot open a stream
+	other, err := auth.GenerateActionToken("user-1", "image-upload", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, raw := range []string{other, "not-a-token", ""} {
+		context, _ := gin.CreateTestContext(httptest.NewRecorder())
+		context.Request = httptest.NewRequest("GET", "/chats/ws?token="+raw, nil)
+		if _, err := streamUser(context); err == nil {
+			t.Errorf("expected %q to be refused", raw)
+		}
+	}
+}
@@ -6,9 +6,17 @@ import (
 )
 
 func ChatRoutes(router *gin.Engine) {
+	// the streams check the token themselves since a stream token can come
+	// in the query
+	router.GET("/chats/ws", Connect)
+	router.GET("/chats/events", Events)
+
 	requestRoutes := router.Group("/chats", users.JWTAuthMiddleWare())
 	{
+		requestRoutes.POST("/streamtoken", CreateStreamToken)
 		requestRoutes.POST("/sendmessage", SendMessage)
+		requestRoutes.POST("/typing", SendTyping)
+		requestRoutes.POST("/markread", MarkAsRead)
 		requestRoutes.GET("/getconversations", GetMessages)
 		requestRoutes.GET("/getsinglechat", GetMessages)
 		requestRoutes.POST("/deletechat", DeleteRequest)
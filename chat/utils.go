@@ -1,14 +1,135 @@
 package chat
 
 import (
+	"errors"
+	"strings"
+	"time"
+
 	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/users"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func GetChats(conversation_id string) ([]Chat, error) {
 	var chats []Chat
-	err := database.Database.Where("conversation_id=?", conversation_id).Find(&chats).Error
+	err := database.Database.Where("conversation_id=?", conversation_id).Order("id").Find(&chats).Error
 	if err != nil {
 		return []Chat{}, err
 	}
 	return chats, nil
 }
+
+// save a message from a participant of a conversation and push it to both
+// participants
+func SendChat(senderID string, conversationID string, message string) (Chat, error) {
+	if strings.TrimSpace(message) == "" {
+		return Chat{}, errors.New("message cannot be empty")
+	}
+	conversation, err := models.FindUserConversation(database.Database, conversationID, senderID)
+	if err != nil {
+		return Chat{}, err
+	}
+
+	chat := Chat{
+		ChatID:         uuid.New().String(),
+		ConversationId: conversationID,
+		SenderID:       senderID,
+		ReceiverId:     conversation.OtherParticipant(senderID),
+		Message:        message,
+		TimeSent:       time.Now().Format("2006-01-02 15:04:05"),
+		IsViewed:       false,
+	}
+	if _, err := chat.Save(); err != nil {
+		return Chat{}, err
+	}
+	PublishChat(chat)
+	return chat, nil
+}
+
+func PublishChat(chat Chat) {
+	broker.Publish(Event{
+		Type:           EventMessage,
+		ConversationID: chat.ConversationId,
+		SenderID:       chat.SenderID,
+		Chat:           &chat,
+		Recipients:     []string{chat.SenderID, chat.ReceiverId},
+	})
+}
+
+// let the other participant know the user is typing
+func PublishTyping(conversationID string, userID string) error {
+	conversation, err := models.FindUserConversation(database.Database, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	return broker.Publish(Event{
+		Type:           EventTyping,
+		ConversationID: conversationID,
+		SenderID:       userID,
+		Recipients:     []string{conversation.OtherParticipant(userID)},
+	})
+}
+
+// mark the messages the user received in a conversation as viewed, clear
+// their unread counts and send a read receipt to the other participant
+func MarkConversationRead(conversationID string, userID string) error {
+	conversation, err := models.FindUserConversation(database.Database, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	err = database.Database.Transaction(func(tx *gorm.DB) error {
+		// lock the conversation so a message sent meanwhile is not lost from
+		// the counts
+		var locked models.Conversation
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("conversation_id=?", conversationID).Find(&locked).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&Chat{}).
+			Where("conversation_id=? AND seller_id=? AND is_viewed=?", conversationID, userID, false).
+			Update("is_viewed", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		unread := locked.UnreadFor(userID)
+		if result.RowsAffected == 0 && unread == 0 {
+			return nil
+		}
+
+		column := "seller_unread"
+		if locked.CustomerId == userID {
+			column = "customer_unread"
+		}
+		err = tx.Model(&models.Conversation{}).
+			Where("conversation_id=?", conversationID).
+			Update(column, 0).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&users.User{}).
+			Where("user_id=?", userID).
+			UpdateColumn("chats", gorm.Expr("GREATEST(chats - ?, 0)", unread)).Error
+		if err != nil {
+			return err
+		}
+		// the conversation is viewed once its latest message has been read
+		return tx.Model(&models.Conversation{}).
+			Where("conversation_id=? AND NOT EXISTS (?)", conversationID,
+				tx.Model(&Chat{}).Select("1").Where("conversation_id=? AND is_viewed=?", conversationID, false)).
+			Update("is_viewed", true).Error
+	})
+	if err != nil {
+		return err
+	}
+	return broker.Publish(Event{
+		Type:           EventRead,
+		ConversationID: conversationID,
+		SenderID:       userID,
+		Recipients:     []string{conversation.OtherParticipant(userID)},
+	})
+}
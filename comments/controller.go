@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/pagination"
 	"eleliafrika.com/backend/users"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -82,7 +83,18 @@ func MakeComment(context *gin.Context) {
 }
 func GetComments(context *gin.Context) {
 	productid := context.Param("id")
-	comments, err := GetProductComments(productid)
+	request, err := pagination.ParseRequest(context, "date", true)
+	if err != nil {
+		response := models.Reply{
+			Message: "invalid pagination parameters",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	_, page, err := GetProductCommentsPage(productid, request)
 	if err != nil {
 		context.JSON(http.StatusBadRequest, gin.H{
 			"success":        false,
@@ -93,7 +105,7 @@ func GetComments(context *gin.Context) {
 		context.JSON(http.StatusOK, gin.H{
 			"success":  true,
 			"message":  "Succesfully fetched comments",
-			"comments": comments,
+			"comments": page,
 		})
 	}
 
@@ -6,6 +6,7 @@ import (
 
 	"eleliafrika.com/backend/database"
 	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/pagination"
 )
 
 type Commentinput struct {
@@ -24,6 +25,18 @@ func GetProductComments(productid string) ([]models.Comment, error) {
 	return comments, nil
 }
 
+var commentSorts = map[string]pagination.Sort[models.Comment]{
+	"date": {
+		Column: "created_at",
+		Value:  func(comment models.Comment) interface{} { return comment.CreatedAt },
+	},
+}
+
+func GetProductCommentsPage(productid string, request pagination.Request) ([]models.Comment, pagination.Page, error) {
+	query := database.Database.Model(&models.Comment{}).Where("isdeleted=?", false).Where("Product_ID=?", productid)
+	return pagination.Fetch(query, request, commentSorts, func(comment models.Comment) uint { return comment.ID })
+}
+
 func DeleteCommentUtil(query string, update models.Comment) (models.Comment, error) {
 	var deletedComment models.Comment
 	result := database.Database.Model(&deletedComment).Where(query).Updates(update)
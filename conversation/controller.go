@@ -4,13 +4,13 @@ import (
 	"errors"
 	"net/http"
 	"strings"
-	"time"
 
 	"eleliafrika.com/backend/chat"
+	"eleliafrika.com/backend/database"
 	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/product"
 	"eleliafrika.com/backend/users"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 func StartNewConversation(context *gin.Context) {
@@ -26,10 +26,7 @@ func StartNewConversation(context *gin.Context) {
 		context.JSON(http.StatusBadRequest, response)
 		return
 	}
-	conversationuuid := uuid.New()
-	chatuuid := uuid.New()
-	currentTime := time.Now()
-	formattedTime := currentTime.Format("2006-01-02 15:04:05")
+	conversationInput.ProductId = strings.ReplaceAll(conversationInput.ProductId, "'", "")
 
 	user, err := users.CurrentUser(context)
 	if err != nil {
@@ -48,57 +45,75 @@ func StartNewConversation(context *gin.Context) {
 		}
 		context.JSON(http.StatusUnauthorized, response)
 		return
-	} else {
-		conversation := Conversation{
-			ConversationId: conversationuuid.String(),
-			CustomerId:     user.UserID,
-			SellerId:       conversationInput.SellerId,
-			LastMessage:    conversationInput.Message,
-			MessagesCount:  1,
-			IsViewed:       false,
-			DateAdded:      formattedTime,
+	}
+
+	ad, err := product.FindSingleAd(conversationInput.ProductId)
+	if err != nil {
+		response := models.Reply{
+			Message: "error fetching the ad",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	} else if ad.ProductID == "" {
+		response := models.Reply{
+			Message: "ad not found",
+			Error:   errors.New("ad not found").Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusNotFound, response)
+		return
+	} else if ad.UserID == user.UserID {
+		response := models.Reply{
+			Message: "cannot start a conversation on your own ad",
+			Error:   errors.New("user owns the ad").Error(),
+			Success: false,
 		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
 
-		_, err = conversation.Save()
-		if err != nil {
-			response := models.Reply{
-				Message: "could not create conversation",
-				Error:   err.Error(),
-				Success: false,
-			}
-			context.JSON(http.StatusBadRequest, response)
-			return
-		} else {
-			chat := chat.Chat{
-				ChatID:         chatuuid.String(),
-				ConversationId: conversationuuid.String(),
-				SenderID:       user.UserID,
-				ReceiverId:     conversation.SellerId,
-				Message:        conversationInput.Message,
-				TimeSent:       formattedTime,
-				IsViewed:       false,
-			}
+	// a buyer messaging about the same ad again continues the conversation
+	conversation, err := FindOrCreateConversation(user.UserID, ad.UserID, ad.ProductID)
+	if err != nil {
+		response := models.Reply{
+			Message: "could not create conversation",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
 
-			_, err := chat.Save()
-			if err != nil {
-				respose := models.Reply{
-					Message: "could not start conversation",
-					Error:   err.Error(),
-					Success: false,
-				}
-				context.JSON(http.StatusBadRequest, respose)
-				return
-			} else {
+	_, err = chat.SendChat(user.UserID, conversation.ConversationId, conversationInput.Message)
+	if err != nil {
+		response := models.Reply{
+			Message: "could not start conversation",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
 
-				response := models.Reply{
-					Message: "conversation created",
-					Success: true,
-				}
-				context.JSON(http.StatusCreated, response)
-				return
-			}
+	conversation, err = models.FindConversation(database.Database, conversation.ConversationId)
+	if err != nil {
+		response := models.Reply{
+			Message: "could not fetch the conversation",
+			Error:   err.Error(),
+			Success: false,
 		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	response := models.Reply{
+		Message: "conversation created",
+		Data:    conversation,
+		Success: true,
 	}
+	context.JSON(http.StatusCreated, response)
 }
 func GetClientConversations(context *gin.Context) {
 	user, err := users.CurrentUser(context)
@@ -1,22 +1,69 @@
 package conversation
 
-import "eleliafrika.com/backend/database"
+import (
+	"time"
 
-func FindClientConversation(client_id string) ([]Conversation, error) {
-	var conversations []Conversation
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
 
-	err := database.Database.Where("customer_id=?", client_id).Find(&conversations).Error
+func FindClientConversation(client_id string) ([]models.Conversation, error) {
+	var conversations []models.Conversation
+
+	err := database.Database.Where("customer_id=? AND is_deleted=?", client_id, false).Find(&conversations).Error
 	if err != nil {
-		return []Conversation{}, err
+		return []models.Conversation{}, err
+	}
+	for i := range conversations {
+		conversations[i].Unread = conversations[i].CustomerUnread
 	}
 	return conversations, nil
 }
-func FindSellerConversation(seller_id string) ([]Conversation, error) {
-	var conversations []Conversation
+func FindSellerConversation(seller_id string) ([]models.Conversation, error) {
+	var conversations []models.Conversation
 
-	err := database.Database.Where("seller_id=?", seller_id).Find(&conversations).Error
+	err := database.Database.Where("seller_id=? AND is_deleted=?", seller_id, false).Find(&conversations).Error
 	if err != nil {
-		return []Conversation{}, err
+		return []models.Conversation{}, err
+	}
+	for i := range conversations {
+		conversations[i].Unread = conversations[i].SellerUnread
 	}
 	return conversations, nil
 }
+
+// get the conversation between a buyer and seller about a product, creating
+// it if there is none. The unique index settles two buyers racing to create
+// the same conversation
+func FindOrCreateConversation(customerId string, sellerId string, productId string) (models.Conversation, error) {
+	conversation := models.Conversation{
+		ConversationId: uuid.New().String(),
+		CustomerId:     customerId,
+		SellerId:       sellerId,
+		ProductId:      productId,
+		DateAdded:      time.Now().Format("2006-01-02 15:04:05"),
+	}
+	err := database.Database.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "customer_id"}, {Name: "seller_id"}, {Name: "product_id"}},
+		// postgres matches the partial index only against a literal predicate
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "product_id <> ''"}}},
+		DoNothing:   true,
+	}).Create(&conversation).Error
+	if err != nil {
+		return models.Conversation{}, err
+	}
+
+	var existing models.Conversation
+	err = database.Database.Where("customer_id=? AND seller_id=? AND product_id=?", customerId, sellerId, productId).
+		Find(&existing).Error
+	if err != nil {
+		return models.Conversation{}, err
+	} else if existing.IsDeleted {
+		err = database.Database.Model(&models.Conversation{}).
+			Where("conversation_id=?", existing.ConversationId).Update("is_deleted", false).Error
+		existing.IsDeleted = false
+	}
+	return existing, err
+}
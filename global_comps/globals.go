@@ -3,17 +3,24 @@ package globalcomps
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"eleliafrika.com/backend/admin"
+	"eleliafrika.com/backend/auth"
 	"eleliafrika.com/backend/brands"
 	"eleliafrika.com/backend/category"
 	"eleliafrika.com/backend/chat"
 	"eleliafrika.com/backend/comments"
 	"eleliafrika.com/backend/conversation"
 	"eleliafrika.com/backend/database"
+	globalutils "eleliafrika.com/backend/global_utils"
 	"eleliafrika.com/backend/images"
+	"eleliafrika.com/backend/invoices"
 	"eleliafrika.com/backend/mainad"
+	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/notifications"
 	"eleliafrika.com/backend/packages"
+	"eleliafrika.com/backend/payments"
 	"eleliafrika.com/backend/product"
 	subcategory "eleliafrika.com/backend/subcategories"
 	"eleliafrika.com/backend/users"
@@ -24,11 +31,80 @@ import (
 
 func LoadDatabase() {
 	database.Connect()
+	if err := auth.MigrateSessions(); err != nil {
+		log.Fatalf("Failed to migrate the sessions table: %v", err)
+	}
+	if err := admin.MigrateAdmins(); err != nil {
+		log.Fatalf("Failed to migrate the admin tables: %v", err)
+	}
+	if err := admin.BootstrapSuperAdmin(); err != nil {
+		log.Fatalf("Failed to create the first super admin: %v", err)
+	}
+	if err := models.MigrateConversations(); err != nil {
+		log.Fatalf("Failed to migrate the conversations table: %v", err)
+	}
+	if err := product.MigrateSearchIndex(); err != nil {
+		log.Fatalf("Failed to create the product search index: %v", err)
+	}
+	if err := product.MigratePrices(); err != nil {
+		log.Fatalf("Failed to migrate the product prices: %v", err)
+	}
+	if err := packages.MigrateSubscriptions(); err != nil {
+		log.Fatalf("Failed to migrate the subscriptions table: %v", err)
+	}
+	if err := packages.MigrateEntitlements(); err != nil {
+		log.Fatalf("Failed to migrate the package entitlements: %v", err)
+	}
+	if err := payments.MigrateTransactions(); err != nil {
+		log.Fatalf("Failed to migrate the payment transactions table: %v", err)
+	}
+	if err := invoices.MigrateInvoices(); err != nil {
+		log.Fatalf("Failed to migrate the invoice tables: %v", err)
+	}
+	if err := models.MigrateMainAds(); err != nil {
+		log.Fatalf("Failed to migrate the main ads table: %v", err)
+	}
+	if err := mainad.MigrateStats(); err != nil {
+		log.Fatalf("Failed to migrate the main ad stats tables: %v", err)
+	}
+	if err := mainad.MigrateBookings(); err != nil {
+		log.Fatalf("Failed to migrate the main ad bookings table: %v", err)
+	}
+	if err := models.MigrateProductImages(); err != nil {
+		log.Fatalf("Failed to migrate the product images table: %v", err)
+	}
+	if err := product.MigrateImages(); err != nil {
+		log.Fatalf("Failed to migrate the product image renditions: %v", err)
+	}
+	if err := product.MigrateModeration(); err != nil {
+		log.Fatalf("Failed to migrate the product moderation tables: %v", err)
+	}
+	if err := product.MigrateSuspensions(); err != nil {
+		log.Fatalf("Failed to migrate the product suspension columns: %v", err)
+	}
+	if err := product.MigratePromotions(); err != nil {
+		log.Fatalf("Failed to migrate the product promotion tables: %v", err)
+	}
+	if err := notifications.MigrateNotifications(); err != nil {
+		log.Fatalf("Failed to migrate the notifications table: %v", err)
+	}
 	// database.Database.AutoMigrate(&models.ProductImage{}, &admin.SystemAdmin{}, &users.User{}, &models.Brand{}, &models.Category{}, &models.SubCategory{}, &models.Comment{}, &product.Product{})
 	// database.Database.AutoMigrate(&packages.PackageModel{})
 
 }
 
+// background jobs, started once the database is ready
+func StartJobs() {
+	globalutils.RunEvery("collect orphaned uploads", time.Hour, images.CollectOrphans)
+	globalutils.RunEvery("expire subscriptions", 10*time.Minute, packages.ExpireSubscriptions)
+	globalutils.RunEvery("expire ads", 10*time.Minute, product.ExpireAds)
+	globalutils.RunEvery("end product suspensions", 10*time.Minute, product.UnsuspendExpired)
+	globalutils.RunEvery("trim featured ads", 10*time.Minute, product.TrimFeaturedAds)
+	globalutils.RunEvery("schedule main ads", time.Minute, mainad.ScheduleMainAds)
+	globalutils.RunEvery("prune main ad events", time.Hour, mainad.PruneEvents)
+	globalutils.RunEvery("expire main ad bookings", 10*time.Minute, mainad.ExpireBookings)
+}
+
 func LoadEnv() {
 	err := godotenv.Load(".env")
 	if err != nil {
@@ -46,7 +122,7 @@ func ServeApplication() {
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
 	config.AllowMethods = []string{"POST", "GET", "PUT"}
-	config.AllowHeaders = []string{"Content-Type", "x-access-token"}
+	config.AllowHeaders = []string{"Content-Type", "x-access-token", "x-device-name", "x-upload-token"}
 	router.Use(cors.New(config))
 
 	users.UserRoutes(router)
@@ -61,6 +137,9 @@ func ServeApplication() {
 	conversation.ConversationRoutes(router)
 	chat.ChatRoutes(router)
 	packages.PackagesRoutes(router)
+	payments.PaymentsRoutes(router)
+	invoices.InvoicesRoutes(router)
+	notifications.NotificationsRoutes(router)
 
 	certFile := "./fullchain.pem"
 	keyFile := "./privkey.pem"
@@ -3,6 +3,7 @@ package globalutils
 import (
 	"context"
 	"log"
+	"time"
 
 	firebase "firebase.google.com/go"
 	"google.golang.org/api/option"
@@ -22,3 +23,18 @@ func InitFirebaseApp() (*firebase.App, error) {
 
 	return app, nil
 }
+
+// run a background job now and then every interval for as long as the
+// server is up, failures are logged and retried on the next run
+func RunEvery(name string, interval time.Duration, job func() error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := job(); err != nil {
+				log.Printf("job %s failed: %v", name, err)
+			}
+			<-ticker.C
+		}
+	}()
+}
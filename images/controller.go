@@ -1,8 +1,12 @@
 package images
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
+	"eleliafrika.com/backend/auth"
+	"eleliafrika.com/backend/database"
 	"eleliafrika.com/backend/models"
 	"github.com/gin-gonic/gin"
 )
@@ -28,6 +32,140 @@ func Getimages(context *gin.Context) {
 
 }
 
-func UploadOtherImages(imagesString []string, productName string) {
+// serve a file kept by the local storage backend, other backends serve
+// their own files
+func ServeFile(context *gin.Context) {
+	uploader, err := GetUploader()
+	if err != nil {
+		response := models.Reply{
+			Message: "storage is not available",
+			Success: false,
+			Error:   err.Error(),
+		}
+		context.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
+	local, ok := uploader.storage.(*LocalStorage)
+	if !ok {
+		context.Status(http.StatusNotFound)
+		return
+	}
+	path, err := local.Open(context.Param("object"))
+	if err != nil {
+		response := models.Reply{
+			Message: "file not found",
+			Success: false,
+			Error:   errors.New("file not found").Error(),
+		}
+		context.JSON(http.StatusNotFound, response)
+		return
+	}
+	context.File(path)
+}
+
+func CreateUploadToken(context *gin.Context) {
+	claims, err := auth.ValidateToken(context)
+	if err != nil {
+		response := models.Reply{
+			Message: "error authorizing user",
+			Success: false,
+			Error:   err.Error(),
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return
+	}
+	token, err := IssueUploadToken(claims.Subject)
+	if err != nil {
+		response := models.Reply{
+			Message: "could not create an upload token",
+			Success: false,
+			Error:   err.Error(),
+		}
+		context.JSON(http.StatusInternalServerError, response)
+		return
+	}
+	response := models.Reply{
+		Message: "upload token created",
+		Success: true,
+		Data:    token,
+	}
+	context.JSON(http.StatusOK, response)
+}
 
+// multipart upload of one or more files in the images field. The returned
+// image ids are then sent with the product
+func UploadImages(context *gin.Context) {
+	userID, err := uploadingUser(context)
+	if err != nil {
+		response := models.Reply{
+			Message: "error authorizing upload",
+			Success: false,
+			Error:   err.Error(),
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return
+	}
+
+	context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, int64(MaxImageBytes()*maxUploadFiles+(1<<20)))
+	form, err := context.MultipartForm()
+	if err != nil {
+		response := models.Reply{
+			Message: "could not read the upload",
+			Success: false,
+			Error:   err.Error(),
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	files := form.File["images"]
+	if len(files) == 0 {
+		response := models.Reply{
+			Message: "no images were sent",
+			Success: false,
+			Error:   errors.New("send the files in the images field").Error(),
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	} else if len(files) > maxUploadFiles {
+		response := models.Reply{
+			Message: "too many images",
+			Success: false,
+			Error:   fmt.Errorf("at most %d images can be uploaded at once", maxUploadFiles).Error(),
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	uploaded := []models.ProductImage{}
+	for _, file := range files {
+		data, err := readUpload(file)
+		var image models.ProductImage
+		if err == nil {
+			image, err = SaveUpload(userID, data)
+		}
+		if err != nil {
+			// the whole upload fails so the client does not have to work out
+			// which files made it
+			for _, image := range uploaded {
+				DeleteRenditions(image.ObjectKey)
+				database.Database.Unscoped().Delete(&image)
+			}
+			response := models.Reply{
+				Message: "could not upload " + file.Filename,
+				Success: false,
+				Error:   err.Error(),
+			}
+			context.JSON(http.StatusBadRequest, response)
+			return
+		}
+		uploaded = append(uploaded, image)
+	}
+
+	response := models.Reply{
+		Message: "images uploaded",
+		Success: true,
+		Data:    uploaded,
+	}
+	context.JSON(http.StatusCreated, response)
 }
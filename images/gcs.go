@@ -0,0 +1,65 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	gcs "cloud.google.com/go/storage"
+)
+
+const (
+	defaultProjectID  = "eduka-404606"
+	defaultBucketName = "eduka-bucket"
+)
+
+type GCSStorage struct {
+	cl         *gcs.Client
+	projectID  string
+	bucketName string
+}
+
+// google cloud storage, configured with GCS_BUCKET, GCS_PROJECT_ID and the
+// usual GOOGLE_APPLICATION_CREDENTIALS
+func NewGCSStorage(ctx context.Context) (*GCSStorage, error) {
+	if os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") == "" {
+		os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "./application_default_credentials.json")
+	}
+	client, err := gcs.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create the storage client: %v", err)
+	}
+	return &GCSStorage{
+		cl:         client,
+		projectID:  envOr("GCS_PROJECT_ID", defaultProjectID),
+		bucketName: envOr("GCS_BUCKET", defaultBucketName),
+	}, nil
+}
+
+func (s *GCSStorage) Put(ctx context.Context, object string, file io.Reader, contentType string) error {
+	wc := s.cl.Bucket(s.bucketName).Object(object).NewWriter(ctx)
+	wc.ContentType = contentType
+	if _, err := io.Copy(wc, file); err != nil {
+		wc.Close()
+		return fmt.Errorf("io.Copy: %v", err)
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("Writer.Close: %v", err)
+	}
+	return nil
+}
+
+// deleting an object that is already gone is not an error
+func (s *GCSStorage) Delete(ctx context.Context, object string) error {
+	err := s.cl.Bucket(s.bucketName).Object(object).Delete(ctx)
+	if err != nil && !errors.Is(err, gcs.ErrObjectNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *GCSStorage) URL(object string) string {
+	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", s.bucketName, object)
+}
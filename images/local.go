@@ -0,0 +1,92 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// files on the local disk, for development and ci. They are served by the
+// /images/files route
+type LocalStorage struct {
+	dir     string
+	baseURL string
+}
+
+func NewLocalStorage(dir string, baseURL string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// path on disk for an object, names can not escape the storage directory
+func (s *LocalStorage) path(object string) (string, error) {
+	clean := filepath.Clean("/" + filepath.FromSlash(object))
+	if clean == string(filepath.Separator) {
+		return "", errors.New("object name is required")
+	}
+	return filepath.Join(s.dir, clean), nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, object string, file io.Reader, contentType string) error {
+	path, err := s.path(object)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// write to a temporary file first so a failed upload never leaves half
+	// a file behind
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, file); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, object string) error {
+	path, err := s.path(object)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) URL(object string) string {
+	return s.baseURL + "/" + object
+}
+
+// file on disk for an object, an error when it does not exist
+func (s *LocalStorage) Open(object string) (string, error) {
+	path, err := s.path(object)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	} else if info.IsDir() {
+		return "", os.ErrNotExist
+	}
+	return path, nil
+}
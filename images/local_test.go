@@ -0,0 +1,53 @@
+package images
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewLocalStorage(dir, "http://localhost:8000/images/files/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := storage.Put(ctx, "eduka/images/phone/1", strings.NewReader("image"), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "eduka", "images", "phone", "1"))
+	if err != nil || string(data) != "image" {
+		t.Fatalf("expected the file on disk got %q %v", data, err)
+	}
+	if got := storage.URL("eduka/images/phone/1"); got != "http://localhost:8000/images/files/eduka/images/phone/1" {
+		t.Errorf("unexpected url %s", got)
+	}
+	if _, err := storage.Open("/eduka/images/phone/1"); err != nil {
+		t.Errorf("expected to open the file got %v", err)
+	}
+
+	// names are kept inside the storage directory
+	if err := storage.Put(ctx, "../../escaped", strings.NewReader("image"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escaped")); err != nil {
+		t.Errorf("expected the file inside the storage directory got %v", err)
+	}
+	if _, err := storage.Open("/"); err == nil {
+		t.Error("expected an error opening the storage directory")
+	}
+
+	if err := storage.Delete(ctx, "eduka/images/phone/1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.Open("eduka/images/phone/1"); err == nil {
+		t.Error("expected the file to be deleted")
+	}
+	if err := storage.Delete(ctx, "eduka/images/phone/1"); err != nil {
+		t.Errorf("deleting a missing file should not fail got %v", err)
+	}
+}
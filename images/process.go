@@ -0,0 +1,241 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"strconv"
+
+	"github.com/gabriel-vasile/mimetype"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	defaultMaxImageBytes = 5 << 20
+	maxImagePixels       = 40_000_000
+	jpegQuality          = 85
+	RenditionThumbnail   = "thumbnail"
+	RenditionCard        = "card"
+	RenditionFull        = "full"
+)
+
+// sizes every upload is resized to, the longest side is scaled down to the
+// given number of pixels and smaller images are never scaled up
+type rendition struct {
+	Name    string
+	MaxSide int
+}
+
+var renditions = []rendition{
+	{RenditionThumbnail, 200},
+	{RenditionCard, 600},
+	{RenditionFull, 1600},
+}
+
+var allowedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// one resized copy of an upload, always a jpeg without any metadata
+type ProcessedImage struct {
+	Name        string
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+}
+
+// largest upload accepted in bytes, IMAGE_MAX_BYTES or 5MB
+func MaxImageBytes() int {
+	size, err := strconv.Atoi(os.Getenv("IMAGE_MAX_BYTES"))
+	if err != nil || size <= 0 {
+		return defaultMaxImageBytes
+	}
+	return size
+}
+
+// check the size of an upload and sniff its type from the content, the type
+// the client claims is never trusted
+func ValidateImage(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", errors.New("image is empty")
+	} else if len(data) > MaxImageBytes() {
+		return "", fmt.Errorf("image is larger than %dKB", MaxImageBytes()>>10)
+	}
+	kind := mimetype.Detect(data).String()
+	if !allowedImageTypes[kind] {
+		return "", fmt.Errorf("unsupported image type %s", kind)
+	}
+	return kind, nil
+}
+
+// validate an upload and produce the thumbnail, card and full renditions.
+// Decoding and encoding again drops exif and any other metadata, the exif
+// orientation is applied first so photos stay the right way up
+func ProcessImage(data []byte) ([]ProcessedImage, error) {
+	kind, err := ValidateImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// check the dimensions before decoding so a small file cannot claim a
+	// huge canvas
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not read the image: %v", err)
+	} else if config.Width*config.Height > maxImagePixels {
+		return nil, errors.New("image dimensions are too large")
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not read the image: %v", err)
+	}
+	if kind == "image/jpeg" {
+		src = applyOrientation(src, jpegOrientation(data))
+	}
+
+	processed := make([]ProcessedImage, 0, len(renditions))
+	for _, r := range renditions {
+		resized := resize(src, r.MaxSide)
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		processed = append(processed, ProcessedImage{
+			Name:        r.Name,
+			ContentType: "image/jpeg",
+			Data:        buf.Bytes(),
+			Width:       resized.Bounds().Dx(),
+			Height:      resized.Bounds().Dy(),
+		})
+	}
+	return processed, nil
+}
+
+// scale the image so its longest side fits, transparent areas are put on
+// white since jpegs have no alpha channel
+func resize(src image.Image, maxSide int) image.Image {
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	if width > maxSide || height > maxSide {
+		if width >= height {
+			height = max(1, height*maxSide/width)
+			width = maxSide
+		} else {
+			width = max(1, width*maxSide/height)
+			height = maxSide
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+	return dst
+}
+
+// exif orientation of a jpeg, 1 when there is none
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		// exif only ever comes before the image data
+		if marker == 0xDA || length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[offset:]))
+	for n := 0; n < entries; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// turn and flip the image the way the exif orientation says it should be
+// shown
+func applyOrientation(src image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 {
+		dstWidth, dstHeight = height, width
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = width-1-x, y
+			case 3:
+				dx, dy = width-1-x, height-1-y
+			case 4:
+				dx, dy = x, height-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = height-1-y, x
+			case 7:
+				dx, dy = height-1-y, width-1-x
+			case 8:
+				dx, dy = y, width-1-x
+			}
+			dst.Set(dx, dy, src.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,165 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, width int, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 100, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// jpeg with an exif segment holding only the orientation tag
+func jpegWithOrientation(t *testing.T, orientation uint16) []byte {
+	t.Helper()
+	var plain bytes.Buffer
+	if err := jpeg.Encode(&plain, image.NewRGBA(image.Rect(0, 0, 4, 2)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	data = binary.BigEndian.AppendUint16(data, uint16(len(segment)+2))
+	data = append(data, segment...)
+	return append(data, plain.Bytes()[2:]...)
+}
+
+func TestValidateImage(t *testing.T) {
+	t.Setenv("IMAGE_MAX_BYTES", "")
+
+	type testCase struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr bool
+	}
+	cases := []testCase{
+		{"png", encodePNG(t, 10, 10), "image/png", false},
+		{"text", []byte("definitely not an image"), "", true},
+		{"empty", []byte{}, "", true},
+		{"too large", append(encodePNG(t, 10, 10), make([]byte, defaultMaxImageBytes)...), "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ValidateImage(c.data)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v got %v", c.wantErr, err)
+			}
+			if got != c.want {
+				t.Errorf("expected %s got %s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestProcessImage(t *testing.T) {
+	processed, err := ProcessImage(encodePNG(t, 2000, 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][2]int{
+		RenditionThumbnail: {200, 100},
+		RenditionCard:      {600, 300},
+		RenditionFull:      {1600, 800},
+	}
+	if len(processed) != len(want) {
+		t.Fatalf("expected %d renditions got %d", len(want), len(processed))
+	}
+	for _, rendition := range processed {
+		size := want[rendition.Name]
+		if rendition.Width != size[0] || rendition.Height != size[1] {
+			t.Errorf("%s: expected %v got %dx%d", rendition.Name, size, rendition.Width, rendition.Height)
+		}
+		if rendition.ContentType != "image/jpeg" {
+			t.Errorf("%s: expected a jpeg got %s", rendition.Name, rendition.ContentType)
+		}
+	}
+
+	// small images are not scaled up
+	processed, err = ProcessImage(encodePNG(t, 120, 80))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rendition := range processed {
+		if rendition.Width != 120 || rendition.Height != 80 {
+			t.Errorf("%s: expected 120x80 got %dx%d", rendition.Name, rendition.Width, rendition.Height)
+		}
+	}
+}
+
+func TestExifIsStripped(t *testing.T) {
+	data := jpegWithOrientation(t, 6)
+	if got := jpegOrientation(data); got != 6 {
+		t.Fatalf("expected orientation 6 got %d", got)
+	}
+
+	processed, err := ProcessImage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rendition := range processed {
+		// the photo is turned upright and the exif is gone
+		if rendition.Width != 2 || rendition.Height != 4 {
+			t.Errorf("%s: expected 2x4 got %dx%d", rendition.Name, rendition.Width, rendition.Height)
+		}
+		if bytes.Contains(rendition.Data, []byte("Exif")) {
+			t.Errorf("%s: exif was not removed", rendition.Name)
+		}
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	src.Set(1, 0, color.RGBA{0, 0, 255, 255})
+
+	type testCase struct {
+		orientation int
+		width       int
+		height      int
+		red         image.Point
+	}
+	cases := []testCase{
+		{1, 2, 1, image.Pt(0, 0)},
+		{2, 2, 1, image.Pt(1, 0)},
+		{3, 2, 1, image.Pt(1, 0)},
+		{6, 1, 2, image.Pt(0, 0)},
+		{8, 1, 2, image.Pt(0, 1)},
+	}
+
+	for _, c := range cases {
+		got := applyOrientation(src, c.orientation)
+		if got.Bounds().Dx() != c.width || got.Bounds().Dy() != c.height {
+			t.Errorf("orientation %d: expected %dx%d got %v", c.orientation, c.width, c.height, got.Bounds())
+			continue
+		}
+		if r, _, _, _ := got.At(c.red.X, c.red.Y).RGBA(); r != 0xffff {
+			t.Errorf("orientation %d: expected red at %v", c.orientation, c.red)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package images
 
 import (
+	"eleliafrika.com/backend/auth"
 	"github.com/gin-gonic/gin"
 )
 
@@ -9,5 +10,9 @@ func Imagesroutes(router *gin.Engine) {
 
 	{
 		imagesRoutes.GET("/getimages/:id", Getimages)
+		imagesRoutes.GET("/files/*object", ServeFile)
+		// upload checks either an access token or an upload token itself
+		imagesRoutes.POST("/upload", UploadImages)
+		imagesRoutes.POST("/uploadtoken", auth.RequireUser(), CreateUploadToken)
 	}
 }
@@ -0,0 +1,87 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+type S3Storage struct {
+	client    *s3.S3
+	uploader  *s3manager.Uploader
+	bucket    string
+	publicURL string
+}
+
+// amazon s3 or any s3 compatible store such as minio. S3_ENDPOINT points at
+// a compatible store, S3_PUBLIC_URL is where objects are downloaded from
+// when that is not the bucket's own address. The default aws credential
+// chain is used unless S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are set
+func NewS3Storage() (*S3Storage, error) {
+	bucket := os.Getenv("S3_BUCKET")
+	if bucket == "" {
+		return nil, errors.New("S3_BUCKET is required for the s3 storage backend")
+	}
+
+	config := aws.NewConfig().WithRegion(envOr("S3_REGION", "us-east-1"))
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint != "" {
+		config = config.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+	}
+	if key := os.Getenv("S3_ACCESS_KEY_ID"); key != "" {
+		config = config.WithCredentials(credentials.NewStaticCredentials(key, os.Getenv("S3_SECRET_ACCESS_KEY"), ""))
+	}
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, fmt.Errorf("could not create the s3 session: %v", err)
+	}
+
+	publicURL := os.Getenv("S3_PUBLIC_URL")
+	if publicURL == "" && endpoint != "" {
+		publicURL = strings.TrimSuffix(endpoint, "/") + "/" + bucket
+	} else if publicURL == "" {
+		publicURL = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, aws.StringValue(config.Region))
+	}
+
+	return &S3Storage{
+		client:    s3.New(sess),
+		uploader:  s3manager.NewUploader(sess),
+		bucket:    bucket,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+	}, nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, object string, file io.Reader, contentType string) error {
+	input := &s3manager.UploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(object),
+		Body:   file,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	_, err := s.uploader.UploadWithContext(ctx, input)
+	return err
+}
+
+// s3 does not report deleting a missing key as an error
+func (s *S3Storage) Delete(ctx context.Context, object string) error {
+	_, err := s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(object),
+	})
+	return err
+}
+
+func (s *S3Storage) URL(object string) string {
+	return s.publicURL + "/" + object
+}
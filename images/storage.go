@@ -0,0 +1,109 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	BackendGCS   = "gcs"
+	BackendS3    = "s3"
+	BackendLocal = "local"
+	uploadPath   = "eduka/images/"
+	uploadWait   = 50 * time.Second
+)
+
+// somewhere uploaded objects are kept. Object names are relative to the
+// upload path and URL returns the address clients download them from
+type Storage interface {
+	Put(ctx context.Context, object string, file io.Reader, contentType string) error
+	Delete(ctx context.Context, object string) error
+	URL(object string) string
+}
+
+type ClientUploader struct {
+	storage    Storage
+	uploadPath string
+}
+
+var (
+	uploaderMutex sync.Mutex
+	Uploader      *ClientUploader
+)
+
+// the backend is picked with STORAGE_BACKEND, gcs when it is not set. The
+// env file is only loaded after package init so the uploader is created on
+// first use
+func GetUploader() (*ClientUploader, error) {
+	uploaderMutex.Lock()
+	defer uploaderMutex.Unlock()
+	if Uploader != nil {
+		return Uploader, nil
+	}
+
+	storage, err := newStorage(strings.ToLower(strings.TrimSpace(os.Getenv("STORAGE_BACKEND"))))
+	if err != nil {
+		return nil, err
+	}
+	Uploader = &ClientUploader{storage: storage, uploadPath: uploadPath}
+	return Uploader, nil
+}
+
+// replace the storage backend, used by tests and tools. Setting nil goes
+// back to the configured backend
+func SetStorage(storage Storage) {
+	uploaderMutex.Lock()
+	defer uploaderMutex.Unlock()
+	if storage == nil {
+		Uploader = nil
+		return
+	}
+	Uploader = &ClientUploader{storage: storage, uploadPath: uploadPath}
+}
+
+func newStorage(backend string) (Storage, error) {
+	switch backend {
+	case "", BackendGCS:
+		return NewGCSStorage(context.Background())
+	case BackendS3:
+		return NewS3Storage()
+	case BackendLocal:
+		return NewLocalStorage(envOr("LOCAL_STORAGE_DIR", "./uploads"), envOr("LOCAL_STORAGE_URL", "/images/files/"))
+	default:
+		return nil, errors.New("unknown storage backend " + backend)
+	}
+}
+
+func envOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// UploadFile uploads an object
+func (c *ClientUploader) UploadFile(file io.Reader, object string) error {
+	return c.UploadFileWithType(file, object, "")
+}
+
+func (c *ClientUploader) UploadFileWithType(file io.Reader, object string, contentType string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), uploadWait)
+	defer cancel()
+	return c.storage.Put(ctx, c.uploadPath+object, file, contentType)
+}
+
+func (c *ClientUploader) DeleteFile(object string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), uploadWait)
+	defer cancel()
+	return c.storage.Delete(ctx, c.uploadPath+object)
+}
+
+// address an uploaded object can be downloaded from
+func (c *ClientUploader) FileURL(object string) string {
+	return c.storage.URL(c.uploadPath + object)
+}
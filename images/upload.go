@@ -0,0 +1,205 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"strconv"
+	"time"
+
+	"eleliafrika.com/backend/auth"
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	uploadAudience        = "image-upload"
+	maxUploadFiles        = 10
+	defaultUploadTokenTTL = 15 * time.Minute
+	defaultOrphanTTL      = 24 * time.Hour
+	orphanBatch           = 100
+)
+
+type UploadToken struct {
+	Token     string    `json:"token"`
+	UploadURL string    `json:"uploadurl"`
+	ExpiresAt time.Time `json:"expiresat"`
+}
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || seconds <= 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// how long an upload may wait to be added to a product, IMAGE_ORPHAN_TTL
+func OrphanTTL() time.Duration {
+	return durationFromEnv("IMAGE_ORPHAN_TTL", defaultOrphanTTL)
+}
+
+// short lived token a client can upload images with, so uploads can run
+// separately from the signed in session
+func IssueUploadToken(userID string) (UploadToken, error) {
+	expiresAt := time.Now().Add(durationFromEnv("IMAGE_UPLOAD_TOKEN_TTL", defaultUploadTokenTTL))
+	token, err := auth.GenerateActionToken(userID, uploadAudience, expiresAt)
+	if err != nil {
+		return UploadToken{}, err
+	}
+	return UploadToken{Token: token, UploadURL: "/images/upload", ExpiresAt: expiresAt}, nil
+}
+
+// user an upload is made for, either from the x-upload-token header or a
+// user's access token
+func uploadingUser(context *gin.Context) (string, error) {
+	if token := context.Request.Header.Get("x-upload-token"); token != "" {
+		return auth.ParseActionToken(token, uploadAudience)
+	}
+
+	claims, err := auth.ValidateToken(context)
+	if err != nil {
+		return "", err
+	} else if claims.SubjectType != auth.SubjectUser {
+		return "", errors.New("only users can upload images")
+	}
+	return claims.Subject, nil
+}
+
+func readUpload(header *multipart.FileHeader) ([]byte, error) {
+	if header.Size > int64(MaxImageBytes()) {
+		return nil, fmt.Errorf("%s is larger than %dKB", header.Filename, MaxImageBytes()>>10)
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	// the header size comes from the client so the read is limited as well
+	data, err := io.ReadAll(io.LimitReader(file, int64(MaxImageBytes())+1))
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// process and store an upload that is not attached to a product yet. It is
+// collected if no product claims it within the orphan ttl
+func SaveUpload(ownerID string, data []byte) (models.ProductImage, error) {
+	uploaded, err := UploadImage("uploads/"+ownerID, data)
+	if err != nil {
+		return models.ProductImage{}, err
+	}
+	image := NewProductImage(uploaded, ownerID, "")
+	if err := database.Database.Create(&image).Error; err != nil {
+		DeleteRenditions(uploaded.ObjectKey)
+		return models.ProductImage{}, err
+	}
+	return image, nil
+}
+
+func NewProductImage(uploaded UploadedImage, ownerID string, productID string) models.ProductImage {
+	return models.ProductImage{
+		ImageID:      uuid.New().String(),
+		ProductID:    productID,
+		OwnerID:      ownerID,
+		ImageUrl:     uploaded.ImageUrl,
+		ThumbnailUrl: uploaded.ThumbnailUrl,
+		CardUrl:      uploaded.CardUrl,
+		Width:        uploaded.Width,
+		Height:       uploaded.Height,
+		ObjectKey:    uploaded.ObjectKey,
+	}
+}
+
+// uploads of a user that no product has claimed yet, in the order of ids.
+// An error is returned if any of them is missing
+func FindPendingUploads(db *gorm.DB, ownerID string, ids []string) ([]models.ProductImage, error) {
+	if len(ids) == 0 {
+		return []models.ProductImage{}, nil
+	}
+	var found []models.ProductImage
+	err := db.Where("image_id IN ? AND owner_id=? AND product_id=''", ids, ownerID).Find(&found).Error
+	if err != nil {
+		return nil, err
+	}
+	byID := map[string]models.ProductImage{}
+	for _, image := range found {
+		byID[image.ImageID] = image
+	}
+	ordered := make([]models.ProductImage, 0, len(ids))
+	for _, id := range ids {
+		image, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("image %s was not found or is already in use", id)
+		}
+		ordered = append(ordered, image)
+	}
+	return ordered, nil
+}
+
+// attach pending uploads to a product, the main image is flagged so it is
+// not listed with the other images. Gallery images are placed in the order
+// of ids starting at firstPosition
+func AttachUploads(db *gorm.DB, ownerID string, productID string, mainID string, ids []string, firstPosition int) error {
+	all := ids
+	if mainID != "" {
+		all = append([]string{mainID}, ids...)
+	}
+	if len(all) == 0 {
+		return nil
+	}
+	result := db.Model(&models.ProductImage{}).
+		Where("image_id IN ? AND owner_id=? AND product_id=''", all, ownerID).
+		Updates(map[string]interface{}{"product_id": productID, "is_main": gorm.Expr("image_id = ?", mainID)})
+	if result.Error != nil {
+		return result.Error
+	} else if result.RowsAffected != int64(len(all)) {
+		return errors.New("some images were not found or are already in use")
+	}
+	for n, id := range ids {
+		err := db.Model(&models.ProductImage{}).Where("image_id=?", id).Update("position", firstPosition+n).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delete uploads that were never attached to a product, along with their
+// files
+func CollectOrphans() error {
+	var orphans []models.ProductImage
+	err := database.Database.Where("product_id='' AND created_at < ?", time.Now().Add(-OrphanTTL())).
+		Limit(orphanBatch).Find(&orphans).Error
+	if err != nil {
+		return err
+	}
+	for _, orphan := range orphans {
+		if orphan.ObjectKey != "" {
+			if err := DeleteRenditions(orphan.ObjectKey); err != nil {
+				return err
+			}
+		}
+		if err := database.Database.Unscoped().Delete(&orphan).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func UploadedImageFrom(image models.ProductImage) UploadedImage {
+	return UploadedImage{
+		ObjectKey:    image.ObjectKey,
+		ImageUrl:     image.ImageUrl,
+		ThumbnailUrl: image.ThumbnailUrl,
+		CardUrl:      image.CardUrl,
+		Width:        image.Width,
+		Height:       image.Height,
+	}
+}
@@ -0,0 +1,87 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUploadToken(t *testing.T) {
+	t.Setenv("JWT_PRIVATE_KEY", "test-key")
+	gin.SetMode(gin.TestMode)
+
+	token, err := IssueUploadToken("user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	context, _ := gin.CreateTestContext(httptest.NewRecorder())
+	context.Request = httptest.NewRequest("POST", "/images/upload", nil)
+	context.Request.Header.Set("x-upload-token", token.Token)
+	if userID, err := uploadingUser(context); err != nil || userID != "user-1" {
+		t.Errorf("expected user-1 got %q %v", userID, err)
+	}
+
+	context.Request.Header.Set("x-upload-token", "not a token")
+	if _, err := uploadingUser(context); err == nil {
+		t.Error("expected an invalid upload token to be refused")
+	}
+}
+
+// storage kept in memory that can be made to fail after a number of puts
+type memoryStorage struct {
+	objects   map[string][]byte
+	failAfter int
+}
+
+func (s *memoryStorage) Put(ctx context.Context, object string, file io.Reader, contentType string) error {
+	if s.failAfter == 0 {
+		return errors.New("storage is down")
+	}
+	s.failAfter--
+	data, err := io.ReadAll(file)
+	s.objects[object] = data
+	return err
+}
+
+func (s *memoryStorage) Delete(ctx context.Context, object string) error {
+	delete(s.objects, object)
+	return nil
+}
+
+func (s *memoryStorage) URL(object string) string {
+	return "memory://" + object
+}
+
+func TestUploadImage(t *testing.T) {
+	storage := &memoryStorage{objects: map[string][]byte{}, failAfter: 3}
+	SetStorage(storage)
+	defer SetStorage(nil)
+
+	uploaded, err := UploadImage("phone", encodePNG(t, 800, 400))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(storage.objects) != 3 {
+		t.Errorf("expected 3 renditions got %d", len(storage.objects))
+	}
+	if uploaded.ImageUrl != "memory://"+uploadPath+RenditionObject(uploaded.ObjectKey, RenditionFull) {
+		t.Errorf("unexpected full url %s", uploaded.ImageUrl)
+	}
+	if err := DeleteRenditions(uploaded.ObjectKey); err != nil || len(storage.objects) != 0 {
+		t.Errorf("expected the renditions to be deleted got %d %v", len(storage.objects), err)
+	}
+
+	// a failed upload does not leave renditions behind
+	storage.failAfter = 2
+	if _, err := UploadImage("phone", encodePNG(t, 800, 400)); err == nil {
+		t.Fatal("expected the upload to fail")
+	}
+	if len(storage.objects) != 0 {
+		t.Errorf("expected no renditions left got %d", len(storage.objects))
+	}
+}
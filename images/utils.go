@@ -2,16 +2,10 @@ package images
 
 import (
 	"bytes"
-	"context"
 	"encoding/base64"
 	"fmt"
-	"io"
-	"log"
-	"os"
 	"strings"
-	"time"
 
-	"cloud.google.com/go/storage"
 	"eleliafrika.com/backend/database"
 	"eleliafrika.com/backend/models"
 	"github.com/gin-gonic/gin"
@@ -31,7 +25,7 @@ func GetProductImages() ([]models.ProductImage, error) {
 func GetSpecificProductImage(productid string) ([]string, error) {
 	var productsImages []models.ProductImage
 	var images []string
-	err := database.Database.Where("product_id=?", productid).Find(&productsImages).Error
+	err := database.Database.Where("product_id=? AND is_main=?", productid, false).Order("position, id").Find(&productsImages).Error
 	if err != nil {
 		return []string{}, err
 	}
@@ -41,71 +35,96 @@ func GetSpecificProductImage(productid string) ([]string, error) {
 	return images, nil
 }
 
-func UploadHandler(productName string, imageString string, context *gin.Context) (string, error) {
-	imageData, err := base64.StdEncoding.DecodeString(imageString)
+// an uploaded image and the addresses of its renditions. The renditions are
+// stored under the object key
+type UploadedImage struct {
+	ObjectKey    string
+	ImageUrl     string
+	ThumbnailUrl string
+	CardUrl      string
+	Width        int
+	Height       int
+}
 
-	if err != nil {
-		return "", err
+// decode a base64 image, optionally sent as a data url, and refuse it before
+// decoding when it is too large
+func DecodeImageString(imageString string) ([]byte, error) {
+	if strings.HasPrefix(imageString, "data:") {
+		if comma := strings.Index(imageString, ","); comma != -1 {
+			imageString = imageString[comma+1:]
+		}
 	}
+	imageString = strings.TrimSpace(imageString)
+	if base64.StdEncoding.DecodedLen(len(imageString)) > MaxImageBytes()+2 {
+		return nil, fmt.Errorf("image is larger than %dKB", MaxImageBytes()>>10)
+	}
+	return base64.StdEncoding.DecodeString(imageString)
+}
 
-	imageUUID := uuid.New()
-	err = Uploader.UploadFile(bytes.NewReader(imageData), strings.ReplaceAll(productName, " ", "")+"/"+imageUUID.String())
+// process an image into its renditions and upload all of them
+func UploadImage(name string, imageData []byte) (UploadedImage, error) {
+	processed, err := ProcessImage(imageData)
 	if err != nil {
-		return "", err
+		return UploadedImage{}, err
+	}
+	uploader, err := GetUploader()
+	if err != nil {
+		return UploadedImage{}, err
 	}
 
-	imageUrl := fmt.Sprintf("https://storage.googleapis.com/%s/eduka/images/%s",
-		BucketName,
-		strings.ReplaceAll(productName, " ", "")+"/"+imageUUID.String())
-
-	return imageUrl, nil
+	uploaded := UploadedImage{ObjectKey: strings.ReplaceAll(name, " ", "") + "/" + uuid.New().String()}
+	for _, rendition := range processed {
+		object := RenditionObject(uploaded.ObjectKey, rendition.Name)
+		err := uploader.UploadFileWithType(bytes.NewReader(rendition.Data), object, rendition.ContentType)
+		if err != nil {
+			DeleteRenditions(uploaded.ObjectKey)
+			return UploadedImage{}, err
+		}
+		switch rendition.Name {
+		case RenditionThumbnail:
+			uploaded.ThumbnailUrl = uploader.FileURL(object)
+		case RenditionCard:
+			uploaded.CardUrl = uploader.FileURL(object)
+		case RenditionFull:
+			uploaded.ImageUrl = uploader.FileURL(object)
+			uploaded.Width, uploaded.Height = rendition.Width, rendition.Height
+		}
+	}
+	return uploaded, nil
 }
 
-const (
-	projectID  = "eduka-404606" // FILL IN WITH YOURS
-	BucketName = "eduka-bucket" // FILL IN WITH YOURS
-)
-
-type ClientUploader struct {
-	cl         *storage.Client
-	projectID  string
-	bucketName string
-	uploadPath string
+func RenditionObject(objectKey string, rendition string) string {
+	return objectKey + "/" + rendition + ".jpg"
 }
 
-var Uploader *ClientUploader
-
-func init() {
-	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "./application_default_credentials.json") // FILL IN WITH YOUR FILE PATH
-	client, err := storage.NewClient(context.Background())
+// remove every rendition of an uploaded image
+func DeleteRenditions(objectKey string) error {
+	uploader, err := GetUploader()
 	if err != nil {
-		log.Fatalf("Failed to create client: %v", err)
+		return err
 	}
-
-	Uploader = &ClientUploader{
-		cl:         client,
-		bucketName: BucketName,
-		projectID:  projectID,
-		uploadPath: "eduka/images/",
+	for _, rendition := range renditions {
+		if err := uploader.DeleteFile(RenditionObject(objectKey, rendition.Name)); err != nil {
+			return err
+		}
 	}
-
+	return nil
 }
 
-// UploadFile uploads an object
-func (c *ClientUploader) UploadFile(file io.Reader, object string) error {
-	ctx := context.Background()
-
-	ctx, cancel := context.WithTimeout(ctx, time.Second*50)
-	defer cancel()
-
-	// Upload an object with storage.Writer.
-	wc := c.cl.Bucket(c.bucketName).Object(c.uploadPath + object).NewWriter(ctx)
-	if _, err := io.Copy(wc, file); err != nil {
-		return fmt.Errorf("io.Copy: %v", err)
-	}
-	if err := wc.Close(); err != nil {
-		return fmt.Errorf("Writer.Close: %v", err)
+// upload an image sent as base64 and return the address of its full size
+// rendition
+func UploadHandler(productName string, imageString string, context *gin.Context) (string, error) {
+	uploaded, err := UploadBase64Image(productName, imageString)
+	if err != nil {
+		return "", err
 	}
+	return uploaded.ImageUrl, nil
+}
 
-	return nil
+func UploadBase64Image(name string, imageString string) (UploadedImage, error) {
+	imageData, err := DecodeImageString(imageString)
+	if err != nil {
+		return UploadedImage{}, err
+	}
+	return UploadImage(name, imageData)
 }
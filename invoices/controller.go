@@ -0,0 +1,138 @@
+package invoices
+
+import (
+	"bytes"
+	"html"
+	"net/http"
+	"strings"
+
+	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/pagination"
+	"eleliafrika.com/backend/users"
+	"github.com/gin-gonic/gin"
+)
+
+func GetInvoices(context *gin.Context) {
+	user, ok := users.CurrentUserOrReply(context)
+	if !ok {
+		return
+	}
+	request, err := pagination.ParseRequest(context, "date", true)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "invalid pagination parameters",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	_, page, err := FetchUserInvoicesPage(user.UserID, request)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "error fetching the invoices",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Data:    page,
+		Message: "invoices fetched",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+func GetSingleInvoice(context *gin.Context) {
+	user, ok := users.CurrentUserOrReply(context)
+	if !ok {
+		return
+	}
+	invoice, err := FindUserInvoice(user.UserID, strings.ReplaceAll(context.Query("id"), "'", ""))
+	if err != nil {
+		response := models.Reply{
+			Message: "could not find the invoice",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusNotFound, response)
+		return
+	}
+	response := models.Reply{
+		Data:    invoice,
+		Message: "invoice fetched",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+func DownloadReceipt(context *gin.Context) {
+	user, ok := users.CurrentUserOrReply(context)
+	if !ok {
+		return
+	}
+	invoice, err := FindUserInvoice(user.UserID, strings.ReplaceAll(context.Query("id"), "'", ""))
+	if err != nil {
+		response := models.Reply{
+			Message: "could not find the invoice",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	// names are stored html escaped
+	document := RenderPDF(invoice, BillTo{
+		Name:  html.UnescapeString(strings.TrimSpace(user.Firstname + " " + user.Lastname)),
+		Email: html.UnescapeString(user.Email),
+		Phone: user.Phone,
+	})
+	filename := "invoice-" + invoice.InvoiceID + ".pdf"
+	if invoice.Number != nil {
+		filename = *invoice.Number + ".pdf"
+	}
+	context.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	context.Data(http.StatusOK, "application/pdf", document)
+}
+
+// every invoice issued between the from and to dates, as csv
+func ExportInvoices(context *gin.Context) {
+	from, to, err := ParseExportRange(context.Query("from"), context.Query("to"))
+	if err != nil {
+		response := models.Reply{
+			Message: "invalid date range",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	exported, err := FetchIssuedBetween(from, to)
+	if err != nil {
+		response := models.Reply{
+			Message: "could not fetch the invoices",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	var out bytes.Buffer
+	if err := WriteCSV(&out, exported); err != nil {
+		response := models.Reply{
+			Message: "could not write the export",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusInternalServerError, response)
+		return
+	}
+	filename := "invoices-" + context.Query("from") + "-to-" + context.Query("to") + ".csv"
+	context.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	context.Data(http.StatusOK, "text/csv", out.Bytes())
+}
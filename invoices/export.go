@@ -0,0 +1,98 @@
+package invoices
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dateLayout     = "2006-01-02"
+	maxExportRange = 366 * 24 * time.Hour
+)
+
+var exportHeader = []string{
+	"number", "status", "issued_at", "paid_at", "voided_at", "user_id", "transaction_id",
+	"receipt", "items", "currency", "tax_rate", "subtotal", "tax", "total",
+}
+
+// the from and to dates of an export, both included. The returned end is
+// the start of the day after to
+func ParseExportRange(from string, to string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation(dateLayout, from, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("from should be a date such as 2024-01-31")
+	}
+	end, err := time.ParseInLocation(dateLayout, to, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("to should be a date such as 2024-01-31")
+	}
+	end = end.AddDate(0, 0, 1)
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, errors.New("from should not be after to")
+	} else if end.Sub(start) > maxExportRange {
+		return time.Time{}, time.Time{}, errors.New("invoices can be exported for at most a year at a time")
+	}
+	return start, end, nil
+}
+
+func formatTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.Format(time.RFC3339)
+}
+
+// spreadsheets run cells starting with these as formulas
+const formulaPrefixes = "=+-@\t\r"
+
+// text that cannot be read as a formula when the export is opened in a
+// spreadsheet
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune(formulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// one row for every invoice, amounts in the currency and not in cents
+func WriteCSV(writer io.Writer, exported []Invoice) error {
+	out := csv.NewWriter(writer)
+	if err := out.Write(exportHeader); err != nil {
+		return err
+	}
+	for _, invoice := range exported {
+		number := ""
+		if invoice.Number != nil {
+			number = *invoice.Number
+		}
+		var descriptions []string
+		for _, item := range invoice.Items {
+			descriptions = append(descriptions, item.Description)
+		}
+		row := []string{
+			csvSafe(number),
+			csvSafe(invoice.Status),
+			formatTime(invoice.IssuedAt),
+			formatTime(invoice.PaidAt),
+			formatTime(invoice.VoidedAt),
+			csvSafe(invoice.UserID),
+			csvSafe(invoice.TransactionID),
+			csvSafe(invoice.Receipt),
+			csvSafe(strings.Join(descriptions, "; ")),
+			csvSafe(invoice.Currency),
+			strconv.FormatFloat(invoice.TaxRate, 'f', -1, 64),
+			strings.ReplaceAll(FormatAmount(invoice.Subtotal), ",", ""),
+			strings.ReplaceAll(FormatAmount(invoice.Tax), ",", ""),
+			strings.ReplaceAll(FormatAmount(invoice.Total), ",", ""),
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
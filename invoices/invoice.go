@@ -0,0 +1,274 @@
+package invoices
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	StatusDraft  = "draft"
+	StatusIssued = "issued"
+	StatusPaid   = "paid"
+	StatusVoid   = "void"
+
+	Currency = "KES"
+
+	defaultTaxRate = 16
+	defaultPrefix  = "INV"
+)
+
+// a bill for a package or main ad. Drafts have no number, the number is
+// taken from the sequence when the invoice is issued so the numbers have no
+// gaps. Amounts are in cents and prices include tax
+type Invoice struct {
+	gorm.Model
+	InvoiceID     string        `gorm:"column:invoice_id;not null;unique" json:"invoiceid"`
+	Number        *string       `gorm:"column:number;unique" json:"number"`
+	UserID        string        `gorm:"column:user_id;not null;index" json:"userid"`
+	TransactionID string        `gorm:"column:transaction_id;not null;index" json:"transactionid"`
+	Status        string        `gorm:"column:status;not null;index" json:"status"`
+	Currency      string        `gorm:"column:currency;not null" json:"currency"`
+	TaxRate       float64       `gorm:"column:tax_rate;not null" json:"taxrate"`
+	Subtotal      int64         `gorm:"column:subtotal;not null" json:"subtotal"`
+	Tax           int64         `gorm:"column:tax;not null" json:"tax"`
+	Total         int64         `gorm:"column:total;not null" json:"total"`
+	Receipt       string        `gorm:"column:receipt;not null;default:''" json:"receipt"`
+	IssuedAt      *time.Time    `gorm:"column:issued_at;index" json:"issuedat"`
+	PaidAt        *time.Time    `gorm:"column:paid_at" json:"paidat"`
+	VoidedAt      *time.Time    `gorm:"column:voided_at" json:"voidedat"`
+	VoidReason    string        `gorm:"column:void_reason;not null;default:''" json:"voidreason"`
+	Items         []InvoiceItem `gorm:"foreignKey:InvoiceID;references:InvoiceID" json:"items,omitempty"`
+}
+
+type InvoiceItem struct {
+	gorm.Model
+	InvoiceID   string `gorm:"column:invoice_id;not null;index" json:"invoiceid"`
+	Kind        string `gorm:"column:kind;not null" json:"kind"`
+	ItemID      string `gorm:"column:item_id;not null" json:"itemid"`
+	Description string `gorm:"column:description;not null" json:"description"`
+	Quantity    int    `gorm:"column:quantity;not null" json:"quantity"`
+	UnitPrice   int64  `gorm:"column:unit_price;not null" json:"unitprice"`
+	Amount      int64  `gorm:"column:amount;not null" json:"amount"`
+}
+
+// the last invoice number handed out
+type InvoiceSequence struct {
+	Name  string `gorm:"column:name;primaryKey"`
+	Value uint   `gorm:"column:value;not null"`
+}
+
+func MigrateInvoices() error {
+	return database.Database.AutoMigrate(&Invoice{}, &InvoiceItem{}, &InvoiceSequence{})
+}
+
+// INVOICE_TAX_RATE is the vat in percent, 16 when it is not set
+func TaxRate() float64 {
+	rate, err := strconv.ParseFloat(os.Getenv("INVOICE_TAX_RATE"), 64)
+	if err != nil || rate < 0 {
+		return defaultTaxRate
+	}
+	return rate
+}
+
+// INVOICE_PREFIX goes in front of every invoice number
+func numberPrefix() string {
+	if prefix := os.Getenv("INVOICE_PREFIX"); prefix != "" {
+		return prefix
+	}
+	return defaultPrefix
+}
+
+func FormatNumber(prefix string, sequence uint) string {
+	return fmt.Sprintf("%s-%06d", prefix, sequence)
+}
+
+// the tax included in a total at a rate in percent, rounded to the cent
+func taxIncluded(total int64, rate float64) int64 {
+	return int64(math.Round(float64(total) * rate / (100 + rate)))
+}
+
+// work out the item amounts and the invoice totals
+func (invoice *Invoice) computeTotals() {
+	var total int64
+	for i := range invoice.Items {
+		item := &invoice.Items[i]
+		item.Amount = item.UnitPrice * int64(item.Quantity)
+		total += item.Amount
+	}
+	invoice.Total = total
+	invoice.Tax = taxIncluded(total, invoice.TaxRate)
+	invoice.Subtotal = total - invoice.Tax
+}
+
+// next number of the sequence. The row stays locked until the transaction
+// ends so two invoices can never take the same number, and a rolled back
+// transaction gives its number back
+func nextNumber(tx *gorm.DB) (string, error) {
+	sequence := InvoiceSequence{Name: "invoice", Value: 1}
+	err := tx.Clauses(
+		clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"value": gorm.Expr("invoice_sequences.value + 1")}),
+		},
+		clause.Returning{Columns: []clause.Column{{Name: "value"}}},
+	).Create(&sequence).Error
+	if err != nil {
+		return "", err
+	}
+	return FormatNumber(numberPrefix(), sequence.Value), nil
+}
+
+// the line of an invoice for something bought, prices are in shillings
+type Line struct {
+	Kind        string
+	ItemID      string
+	Description string
+	Quantity    int
+	UnitPrice   uint
+}
+
+// draft invoice for a payment that has been started, inside the transaction
+// that records it
+func CreateDraft(tx *gorm.DB, userId string, transactionId string, lines []Line) (Invoice, error) {
+	if len(lines) == 0 {
+		return Invoice{}, errors.New("an invoice needs at least one item")
+	}
+	invoice := Invoice{
+		InvoiceID:     uuid.New().String(),
+		UserID:        userId,
+		TransactionID: transactionId,
+		Status:        StatusDraft,
+		Currency:      Currency,
+		TaxRate:       TaxRate(),
+	}
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			return Invoice{}, errors.New("invoice items need a quantity")
+		}
+		invoice.Items = append(invoice.Items, InvoiceItem{
+			InvoiceID:   invoice.InvoiceID,
+			Kind:        line.Kind,
+			ItemID:      line.ItemID,
+			Description: line.Description,
+			Quantity:    line.Quantity,
+			UnitPrice:   int64(line.UnitPrice) * 100,
+		})
+	}
+	invoice.computeTotals()
+	if err := tx.Create(&invoice).Error; err != nil {
+		return Invoice{}, err
+	}
+	return invoice, nil
+}
+
+// invoice of a payment, locked for the rest of the transaction. The invoice
+// id is empty when the payment has none
+func findByTransaction(tx *gorm.DB, transactionId string) (Invoice, error) {
+	var invoice Invoice
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("transaction_id=?", transactionId).Find(&invoice).Error
+	return invoice, err
+}
+
+// give a draft its number
+func issue(tx *gorm.DB, invoice *Invoice, now time.Time) error {
+	number, err := nextNumber(tx)
+	if err != nil {
+		return err
+	}
+	invoice.Number = &number
+	invoice.Status = StatusIssued
+	invoice.IssuedAt = &now
+	return tx.Model(&Invoice{}).Where("invoice_id=?", invoice.InvoiceID).
+		Updates(map[string]interface{}{"number": number, "status": StatusIssued, "issued_at": now}).Error
+}
+
+// mark the invoice of a payment as paid, issuing it first when it is still
+// a draft
+func MarkPaid(tx *gorm.DB, transactionId string, receipt string, paidAt time.Time) (Invoice, error) {
+	invoice, err := findByTransaction(tx, transactionId)
+	if err != nil || invoice.InvoiceID == "" {
+		return invoice, err
+	}
+	switch invoice.Status {
+	case StatusPaid:
+		return invoice, nil
+	case StatusVoid:
+		return Invoice{}, errors.New("invoice " + invoice.InvoiceID + " was voided")
+	case StatusDraft:
+		if err := issue(tx, &invoice, paidAt); err != nil {
+			return Invoice{}, err
+		}
+	}
+	invoice.Status = StatusPaid
+	invoice.Receipt = receipt
+	invoice.PaidAt = &paidAt
+	err = tx.Model(&Invoice{}).Where("invoice_id=?", invoice.InvoiceID).
+		Updates(map[string]interface{}{"status": StatusPaid, "receipt": receipt, "paid_at": paidAt}).Error
+	return invoice, err
+}
+
+// void the invoice of a payment that did not go through. Paid invoices
+// cannot be voided
+func Void(tx *gorm.DB, transactionId string, reason string, now time.Time) error {
+	invoice, err := findByTransaction(tx, transactionId)
+	if err != nil || invoice.InvoiceID == "" || invoice.Status == StatusVoid {
+		return err
+	} else if invoice.Status == StatusPaid {
+		return errors.New("a paid invoice cannot be voided")
+	}
+	return tx.Model(&Invoice{}).Where("invoice_id=?", invoice.InvoiceID).
+		Updates(map[string]interface{}{"status": StatusVoid, "void_reason": reason, "voided_at": now}).Error
+}
+
+// invoices a seller can see, drafts are left out until they are issued
+func userInvoicesQuery(userId string) *gorm.DB {
+	return database.Database.Model(&Invoice{}).Where("user_id=? AND status <> ?", userId, StatusDraft)
+}
+
+func FindUserInvoice(userId string, invoiceId string) (Invoice, error) {
+	var invoice Invoice
+	err := userInvoicesQuery(userId).Preload("Items").Where("invoice_id=?", invoiceId).Find(&invoice).Error
+	if err != nil {
+		return Invoice{}, err
+	} else if invoice.InvoiceID == "" {
+		return Invoice{}, errors.New("invoice not found")
+	}
+	return invoice, nil
+}
+
+// invoices issued in [from, to), oldest first, for the finance export
+func FetchIssuedBetween(from time.Time, to time.Time) ([]Invoice, error) {
+	var issued []Invoice
+	err := database.Database.Preload("Items").
+		Where("issued_at >= ? AND issued_at < ?", from, to).
+		Order("issued_at, id").Find(&issued).Error
+	if err != nil {
+		return []Invoice{}, err
+	}
+	return issued, nil
+}
+
+var invoiceSorts = map[string]pagination.Sort[Invoice]{
+	"date": {
+		Column: "created_at",
+		Value:  func(invoice Invoice) interface{} { return invoice.CreatedAt },
+	},
+	"total": {
+		Column: "total",
+		Value:  func(invoice Invoice) interface{} { return invoice.Total },
+	},
+}
+
+func FetchUserInvoicesPage(userId string, request pagination.Request) ([]Invoice, pagination.Page, error) {
+	return pagination.Fetch(userInvoicesQuery(userId), request, invoiceSorts, func(invoice Invoice) uint { return invoice.ID })
+}
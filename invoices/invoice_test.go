@@ -0,0 +1,127 @@
+package invoices
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+)
+
+func TestComputeTotals(t *testing.T) {
+	invoice := Invoice{
+		TaxRate: 16,
+		Items: []InvoiceItem{
+			{UnitPrice: 50000, Quantity: 2},
+			{UnitPrice: 16000, Quantity: 1},
+		},
+	}
+	invoice.computeTotals()
+	if invoice.Items[0].Amount != 100000 {
+		t.Errorf("expected the item amount to be 100000 got %d", invoice.Items[0].Amount)
+	}
+	if invoice.Total != 116000 || invoice.Tax != 16000 || invoice.Subtotal != 100000 {
+		t.Errorf("unexpected totals %d %d %d", invoice.Subtotal, invoice.Tax, invoice.Total)
+	}
+
+	// tax is rounded to the cent and the subtotal takes the remainder
+	invoice = Invoice{TaxRate: 16, Items: []InvoiceItem{{UnitPrice: 100, Quantity: 1}}}
+	invoice.computeTotals()
+	if invoice.Tax != 14 || invoice.Subtotal != 86 {
+		t.Errorf("expected 86 + 14 got %d + %d", invoice.Subtotal, invoice.Tax)
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	if got := FormatNumber("INV", 42); got != "INV-000042" {
+		t.Errorf("expected INV-000042 got %s", got)
+	}
+
+	type testCase struct {
+		cents int64
+		want  string
+	}
+	cases := []testCase{
+		{0, "0.00"},
+		{5, "0.05"},
+		{123450, "1,234.50"},
+		{100000000, "1,000,000.00"},
+		{-2500, "-25.00"},
+	}
+	for _, c := range cases {
+		if got := FormatAmount(c.cents); got != c.want {
+			t.Errorf("expected %s got %s", c.want, got)
+		}
+	}
+}
+
+func TestParseExportRange(t *testing.T) {
+	from, to, err := ParseExportRange("2024-01-01", "2024-01-31")
+	if err != nil {
+		t.Fatal(err)
+	} else if to.Sub(from) != 31*24*time.Hour {
+		t.Errorf("expected the range to include the last day, got %v", to.Sub(from))
+	}
+
+	for _, bad := range [][2]string{{"2024-02-01", "2024-01-01"}, {"yesterday", "2024-01-01"}, {"2022-01-01", "2024-01-01"}} {
+		if _, _, err := ParseExportRange(bad[0], bad[1]); err == nil {
+			t.Errorf("expected %s to %s to be refused", bad[0], bad[1])
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	number := "INV-000001"
+	issued := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var out bytes.Buffer
+	err := WriteCSV(&out, []Invoice{{
+		Number:   &number,
+		Status:   StatusPaid,
+		IssuedAt: &issued,
+		PaidAt:   &issued,
+		UserID:   "user",
+		Receipt:  "NLJ7RT61SV",
+		Currency: Currency,
+		TaxRate:  16,
+		Subtotal: 431034,
+		Tax:      68966,
+		Total:    500000,
+		Items:    []InvoiceItem{{Description: "gold package, \"yearly\""}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid csv: %v", err)
+	} else if len(rows) != 2 || len(rows[1]) != len(exportHeader) {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+	row := rows[1]
+	if row[0] != number || row[8] != "gold package, \"yearly\"" || row[10] != "16" || row[13] != "5000.00" {
+		t.Errorf("unexpected row %v", row)
+	}
+}
+
+func TestCSVSafe(t *testing.T) {
+	type testCase struct {
+		value string
+		want  string
+	}
+	cases := []testCase{
+		{"gold package", "gold package"},
+		{"", ""},
+		{"=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"+254700000000", "'+254700000000"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"a=1", "a=1"},
+	}
+
+	for _, c := range cases {
+		if got := csvSafe(c.value); got != c.want {
+			t.Errorf("expected %q got %q", c.want, got)
+		}
+	}
+}
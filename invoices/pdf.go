@@ -0,0 +1,243 @@
+package invoices
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// a4 in points
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	margin     = 50.0
+)
+
+// a very small pdf writer, enough for receipts: text in the standard
+// helvetica fonts and lines, on as many a4 pages as needed
+type pdfDocument struct {
+	pages []*bytes.Buffer
+}
+
+func (document *pdfDocument) page() *bytes.Buffer {
+	if len(document.pages) == 0 {
+		document.newPage()
+	}
+	return document.pages[len(document.pages)-1]
+}
+
+func (document *pdfDocument) newPage() {
+	document.pages = append(document.pages, &bytes.Buffer{})
+}
+
+// text is written in winansi, anything outside printable ascii is replaced
+func pdfEscape(text string) string {
+	var escaped strings.Builder
+	for _, char := range text {
+		switch {
+		case char == '\\' || char == '(' || char == ')':
+			escaped.WriteRune('\\')
+			escaped.WriteRune(char)
+		case char < 32 || char > 126:
+			escaped.WriteRune('?')
+		default:
+			escaped.WriteRune(char)
+		}
+	}
+	return escaped.String()
+}
+
+func (document *pdfDocument) text(x float64, y float64, size float64, bold bool, text string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(document.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfEscape(text))
+}
+
+// text ending at x, for amounts
+func (document *pdfDocument) textRight(x float64, y float64, size float64, bold bool, text string) {
+	document.text(x-textWidth(text, size), y, size, bold, text)
+}
+
+func (document *pdfDocument) line(x1 float64, y1 float64, x2 float64, y2 float64) {
+	fmt.Fprintf(document.page(), "%.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+// width of text in helvetica, close enough to line amounts up
+func textWidth(text string, size float64) float64 {
+	var units float64
+	for _, char := range text {
+		switch {
+		case char == ' ' || char == ',' || char == '.':
+			units += 278
+		case char >= '0' && char <= '9':
+			units += 556
+		case char >= 'A' && char <= 'Z':
+			units += 667
+		default:
+			units += 556
+		}
+	}
+	return units * size / 1000
+}
+
+func (document *pdfDocument) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	if len(document.pages) == 0 {
+		document.newPage()
+	}
+	// catalog, page tree and the two fonts come first, then a page and its
+	// content for every page
+	var kids []string
+	for i := range document.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+	}
+	out.WriteString("%PDF-1.4\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(document.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range document.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// who the invoice is made out to
+type BillTo struct {
+	Name  string
+	Email string
+	Phone string
+}
+
+// cents as 1,234.50
+func FormatAmount(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	whole := fmt.Sprintf("%d", cents/100)
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	return fmt.Sprintf("%s%s.%02d", sign, grouped.String(), cents%100)
+}
+
+func businessName() string {
+	if name := os.Getenv("INVOICE_BUSINESS_NAME"); name != "" {
+		return name
+	}
+	return "Eleliafrika"
+}
+
+// the invoice as a pdf, titled receipt once it has been paid
+func RenderPDF(invoice Invoice, billTo BillTo) []byte {
+	document := &pdfDocument{}
+	title := "INVOICE"
+	if invoice.Status == StatusPaid {
+		title = "RECEIPT"
+	}
+	number := "DRAFT"
+	if invoice.Number != nil {
+		number = *invoice.Number
+	}
+	right := pageWidth - margin
+	y := pageHeight - margin - 20
+
+	document.text(margin, y, 20, true, businessName())
+	document.textRight(right, y, 20, true, title)
+	y -= 20
+	if pin := os.Getenv("INVOICE_BUSINESS_PIN"); pin != "" {
+		document.text(margin, y, 10, false, "PIN: "+pin)
+	}
+	document.textRight(right, y, 10, false, "No. "+number)
+	y -= 14
+	if invoice.IssuedAt != nil {
+		document.textRight(right, y, 10, false, "Issued "+invoice.IssuedAt.Format("02 Jan 2006"))
+		y -= 14
+	}
+	if invoice.PaidAt != nil {
+		document.textRight(right, y, 10, false, "Paid "+invoice.PaidAt.Format("02 Jan 2006 15:04"))
+		y -= 14
+	}
+	if invoice.Receipt != "" {
+		document.textRight(right, y, 10, false, "M-Pesa receipt "+invoice.Receipt)
+		y -= 14
+	}
+	if invoice.Status == StatusVoid {
+		document.textRight(right, y, 10, true, "VOID")
+		y -= 14
+	}
+
+	y -= 16
+	document.text(margin, y, 10, true, "Billed to")
+	y -= 14
+	for _, detail := range []string{billTo.Name, billTo.Email, billTo.Phone} {
+		if detail != "" {
+			document.text(margin, y, 10, false, detail)
+			y -= 14
+		}
+	}
+
+	// item table
+	y -= 20
+	quantityX, priceX := right-180.0, right-90.0
+	header := func() {
+		document.text(margin, y, 10, true, "Description")
+		document.textRight(quantityX, y, 10, true, "Qty")
+		document.textRight(priceX, y, 10, true, "Unit price")
+		document.textRight(right, y, 10, true, "Amount")
+		y -= 6
+		document.line(margin, y, right, y)
+		y -= 16
+	}
+	header()
+	for _, item := range invoice.Items {
+		if y < margin+100 {
+			document.newPage()
+			y = pageHeight - margin - 20
+			header()
+		}
+		document.text(margin, y, 10, false, item.Description)
+		document.textRight(quantityX, y, 10, false, fmt.Sprintf("%d", item.Quantity))
+		document.textRight(priceX, y, 10, false, FormatAmount(item.UnitPrice))
+		document.textRight(right, y, 10, false, FormatAmount(item.Amount))
+		y -= 16
+	}
+	document.line(margin, y+10, right, y+10)
+
+	y -= 8
+	totals := [][2]string{
+		{"Subtotal", FormatAmount(invoice.Subtotal)},
+		{fmt.Sprintf("VAT %g%% (included)", invoice.TaxRate), FormatAmount(invoice.Tax)},
+		{"Total " + invoice.Currency, FormatAmount(invoice.Total)},
+	}
+	for i, total := range totals {
+		bold := i == len(totals)-1
+		document.textRight(priceX, y, 10, bold, total[0])
+		document.textRight(right, y, 10, bold, total[1])
+		y -= 16
+	}
+	return document.Bytes()
+}
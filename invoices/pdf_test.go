@@ -0,0 +1,63 @@
+package invoices
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRenderPDF(t *testing.T) {
+	number := "INV-000007"
+	paid := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	invoice := Invoice{
+		Number:   &number,
+		Status:   StatusPaid,
+		IssuedAt: &paid,
+		PaidAt:   &paid,
+		Receipt:  "NLJ7RT61SV",
+		Currency: Currency,
+		TaxRate:  16,
+	}
+	// enough items to need a second page
+	for i := 0; i < 60; i++ {
+		invoice.Items = append(invoice.Items, InvoiceItem{Description: fmt.Sprintf("item (%d)", i), Quantity: 1, UnitPrice: 100})
+	}
+	invoice.computeTotals()
+	document := RenderPDF(invoice, BillTo{Name: "Jane Wanjiru", Phone: "254712345678"})
+
+	if !bytes.HasPrefix(document, []byte("%PDF-1.4")) || !bytes.HasSuffix(document, []byte("%%EOF\n")) {
+		t.Fatal("document is not framed as a pdf")
+	}
+	if !bytes.Contains(document, []byte("(RECEIPT)")) || !bytes.Contains(document, []byte(`(item \(3\))`)) {
+		t.Error("expected the title and escaped item text in the document")
+	}
+	if !bytes.Contains(document, []byte("/Count 2")) {
+		t.Error("expected the items to run onto a second page")
+	}
+
+	// every object has to start where the xref table says it does
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(document)
+	if startxref == nil {
+		t.Fatal("no startxref")
+	}
+	xref, _ := strconv.Atoi(string(startxref[1]))
+	if !bytes.HasPrefix(document[xref:], []byte("xref\n")) {
+		t.Fatal("startxref does not point at the xref table")
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(document[xref:], -1)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if !bytes.HasPrefix(document[offset:], []byte(fmt.Sprintf("%d 0 obj", i+1))) {
+			t.Errorf("object %d is not at offset %d", i+1, offset)
+		}
+	}
+}
+
+func TestPDFEscape(t *testing.T) {
+	if got := pdfEscape(`a(b)\c é`); got != `a\(b\)\\c ?` {
+		t.Errorf("unexpected escape %s", got)
+	}
+}
@@ -0,0 +1,16 @@
+package invoices
+
+import (
+	"eleliafrika.com/backend/auth"
+	"github.com/gin-gonic/gin"
+)
+
+func InvoicesRoutes(router *gin.Engine) {
+	invoicesRoutes := router.Group("/invoices")
+	{
+		invoicesRoutes.GET("", auth.RequireUser(), GetInvoices)
+		invoicesRoutes.GET("/single", auth.RequireUser(), GetSingleInvoice)
+		invoicesRoutes.GET("/receipt", auth.RequireUser(), DownloadReceipt)
+		invoicesRoutes.GET("/export", auth.RequireAdmin(auth.PermissionViewFinance), ExportInvoices)
+	}
+}
@@ -7,5 +7,6 @@ import (
 func main() {
 	globalcomps.LoadEnv()
 	globalcomps.LoadDatabase()
+	globalcomps.StartJobs()
 	globalcomps.ServeApplication()
 }
@@ -0,0 +1,520 @@
+package mainad
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"eleliafrika.com/backend/category"
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/images"
+	"eleliafrika.com/backend/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// banners on the homepage are booked under this placement, every other
+	// placement is the name of a category
+	HomepagePlacement = "homepage"
+
+	BookingPending   = "pending"
+	BookingApproved  = "approved"
+	BookingRejected  = "rejected"
+	BookingPaid      = "paid"
+	BookingCancelled = "cancelled"
+	BookingExpired   = "expired"
+
+	defaultSlots        = 3
+	defaultPaymentHours = 48
+	bookingDay          = "2006-01-02"
+
+	// longest a banner can be booked for at once
+	MaxPaidDays = 90
+)
+
+// bookings that hold a slot on their days
+var holdingBookings = []string{BookingApproved, BookingPaid}
+
+// a seller's request to show a banner in a placement between two days. An
+// admin approves it, and once it is paid the main ad is made from it. The
+// end date is the midnight after the last day. An approved booking holds
+// its slot until PayBy, after that it expires and the slot is freed
+type Booking struct {
+	gorm.Model
+	BookingID  string     `gorm:"column:booking_id;not null;unique" json:"bookingid"`
+	UserID     string     `gorm:"column:user_id;not null;index" json:"userid"`
+	AdCategory string     `gorm:"column:ad_category;not null;index" json:"adcategory"`
+	StartDate  time.Time  `gorm:"column:start_date;not null" json:"startdate"`
+	EndDate    time.Time  `gorm:"column:end_date;not null" json:"enddate"`
+	Days       int        `gorm:"column:days;not null" json:"days"`
+	Amount     uint       `gorm:"column:amount;not null" json:"amount"`
+	AdName     string     `gorm:"column:ad_name;not null" json:"adname"`
+	AdImage    string     `gorm:"column:ad_image;not null" json:"adimage"`
+	AdImageKey string     `gorm:"column:ad_image_key;not null;default:''" json:"-"`
+	AdLink     string     `gorm:"column:ad_link;not null;default:''" json:"adlink"`
+	Status     string     `gorm:"column:status;not null;index" json:"status"`
+	Reason     string     `gorm:"column:reason;not null;default:''" json:"reason"`
+	ReviewedBy string     `gorm:"column:reviewed_by;not null;default:''" json:"reviewedby"`
+	ReviewedAt *time.Time `gorm:"column:reviewed_at" json:"reviewedat"`
+	PayBy      *time.Time `gorm:"column:pay_by" json:"payby"`
+	AdID       string     `gorm:"column:ad_id;not null;default:''" json:"adid"`
+}
+
+type BookingInput struct {
+	AdCategory string `json:"adcategory"`
+	StartDate  string `json:"startdate"`
+	EndDate    string `json:"enddate"`
+	AdName     string `json:"adname"`
+	AdImage    string `json:"adimage"`
+	AdLink     string `json:"adlink"`
+}
+
+type ReviewInput struct {
+	Approve bool   `json:"approve"`
+	Reason  string `json:"reason"`
+}
+
+// how busy a placement is over some days
+type Availability struct {
+	AdCategory string   `json:"adcategory"`
+	Capacity   int      `json:"capacity"`
+	Booked     int      `json:"booked"`
+	FullDays   []string `json:"fulldays"`
+	Available  bool     `json:"available"`
+}
+
+// price of showing a main ad for a number of days, MAINAD_DAILY_PRICE is the
+// price of one day
+func MainAdPrice(days int) (uint, error) {
+	if days <= 0 || days > MaxPaidDays {
+		return 0, errors.New("a main ad can be paid for between 1 and " + strconv.Itoa(MaxPaidDays) + " days")
+	}
+	daily, err := strconv.Atoi(os.Getenv("MAINAD_DAILY_PRICE"))
+	if err != nil || daily <= 0 {
+		return 0, errors.New("main ad prices have not been set")
+	}
+	return uint(daily * days), nil
+}
+
+func MigrateBookings() error {
+	return database.Database.AutoMigrate(&Booking{})
+}
+
+// how long an approved booking has to be paid for, MAINAD_PAYMENT_HOURS
+func paymentWindow() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("MAINAD_PAYMENT_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = defaultPaymentHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// when a booking approved at a time has to be paid by, never after its
+// last day
+func payBy(approved time.Time, end time.Time, window time.Duration) time.Time {
+	due := approved.Add(window)
+	if due.After(end) {
+		return end
+	}
+	return due
+}
+
+// an approved booking that was not paid for in time has lost its slot
+func (booking Booking) paymentOverdue(now time.Time) bool {
+	return booking.PayBy != nil && !booking.PayBy.After(now)
+}
+
+// banners shown side by side in a placement, MAINAD_SLOTS
+func slotCapacity() int {
+	slots, err := strconv.Atoi(os.Getenv("MAINAD_SLOTS"))
+	if err != nil || slots <= 0 {
+		return defaultSlots
+	}
+	return slots
+}
+
+// the first and last day of a booking as midnights, the end being the
+// midnight after the last day
+func ParseBookingDays(from string, to string, now time.Time) (time.Time, time.Time, int, error) {
+	start, err := time.ParseInLocation(bookingDay, from, now.Location())
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, errors.New("start date should be a date such as 2024-01-31")
+	}
+	last, err := time.ParseInLocation(bookingDay, to, now.Location())
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, errors.New("end date should be a date such as 2024-01-31")
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if start.Before(today) {
+		return time.Time{}, time.Time{}, 0, errors.New("a booking cannot start in the past")
+	} else if last.Before(start) {
+		return time.Time{}, time.Time{}, 0, errors.New("the booking should end after it starts")
+	}
+	end := last.AddDate(0, 0, 1)
+	days := 0
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		days++
+	}
+	if days > MaxPaidDays {
+		return time.Time{}, time.Time{}, 0, fmt.Errorf("a banner can be booked for at most %d days", MaxPaidDays)
+	}
+	return start, end, days, nil
+}
+
+// the homepage or a category that exists
+func validatePlacement(placement string) error {
+	if placement == HomepagePlacement {
+		return nil
+	}
+	existing, err := category.FetchSingleCategory(placement)
+	if err != nil {
+		return err
+	} else if existing.CategoryName == "" {
+		return errors.New("the category provided does not exist")
+	}
+	return nil
+}
+
+// the most bookings on any one day between start and end, and the days that
+// have no slot left
+func busiestDays(bookings []Booking, start time.Time, end time.Time, capacity int) (int, []string) {
+	most := 0
+	full := []string{}
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		booked := 0
+		for _, booking := range bookings {
+			if !booking.StartDate.After(day) && booking.EndDate.After(day) {
+				booked++
+			}
+		}
+		if booked > most {
+			most = booked
+		}
+		if booked >= capacity {
+			full = append(full, day.Format(bookingDay))
+		}
+	}
+	return most, full
+}
+
+func checkAvailability(tx *gorm.DB, placement string, start time.Time, end time.Time, skipBookingId string) (Availability, error) {
+	var overlapping []Booking
+	// approved bookings past their time to pay no longer count, even before
+	// the job expires them
+	err := tx.Where("ad_category=? AND status IN ? AND start_date < ? AND end_date > ? AND booking_id <> ?",
+		placement, holdingBookings, end, start, skipBookingId).
+		Where("NOT (status=? AND pay_by IS NOT NULL AND pay_by <= ?)", BookingApproved, time.Now()).
+		Find(&overlapping).Error
+	if err != nil {
+		return Availability{}, err
+	}
+	capacity := slotCapacity()
+	booked, full := busiestDays(overlapping, start, end, capacity)
+	return Availability{
+		AdCategory: placement,
+		Capacity:   capacity,
+		Booked:     booked,
+		FullDays:   full,
+		Available:  len(full) == 0,
+	}, nil
+}
+
+func CheckAvailability(placement string, from string, to string) (Availability, error) {
+	if err := validatePlacement(placement); err != nil {
+		return Availability{}, err
+	}
+	start, end, _, err := ParseBookingDays(from, to, time.Now())
+	if err != nil {
+		return Availability{}, err
+	}
+	return checkAvailability(database.Database, placement, start, end, "")
+}
+
+// bookings of a placement take and check slots one at a time
+func lockPlacement(tx *gorm.DB, placement string) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "mainad-placement:"+placement).Error
+}
+
+func unavailable(availability Availability) error {
+	return errors.New("the placement is fully booked on " + strings.Join(availability.FullDays, ", "))
+}
+
+// book a banner, waiting for an admin to review it
+func CreateBooking(userId string, input BookingInput) (Booking, error) {
+	input.AdCategory = strings.TrimSpace(input.AdCategory)
+	input.AdName = strings.TrimSpace(input.AdName)
+	if len(input.AdName) < 3 {
+		return Booking{}, errors.New("ad name should atleast be 3 characters long")
+	} else if err := ValidateAdLink(input.AdLink); err != nil {
+		return Booking{}, err
+	} else if err := validatePlacement(input.AdCategory); err != nil {
+		return Booking{}, err
+	}
+	start, end, days, err := ParseBookingDays(input.StartDate, input.EndDate, time.Now())
+	if err != nil {
+		return Booking{}, err
+	}
+	amount, err := MainAdPrice(days)
+	if err != nil {
+		return Booking{}, err
+	}
+	availability, err := checkAvailability(database.Database, input.AdCategory, start, end, "")
+	if err != nil {
+		return Booking{}, err
+	} else if !availability.Available {
+		return Booking{}, unavailable(availability)
+	}
+
+	uploaded, err := images.UploadBase64Image(input.AdName, input.AdImage)
+	if err != nil {
+		return Booking{}, err
+	}
+	booking := Booking{
+		BookingID:  uuid.New().String(),
+		UserID:     userId,
+		AdCategory: input.AdCategory,
+		StartDate:  start,
+		EndDate:    end,
+		Days:       days,
+		Amount:     amount,
+		AdName:     input.AdName,
+		AdImage:    uploaded.ImageUrl,
+		AdImageKey: uploaded.ObjectKey,
+		AdLink:     input.AdLink,
+		Status:     BookingPending,
+	}
+	if err := database.Database.Create(&booking).Error; err != nil {
+		if deleteErr := images.DeleteRenditions(uploaded.ObjectKey); deleteErr != nil {
+			return Booking{}, fmt.Errorf("%v, and the upload could not be deleted: %v", err, deleteErr)
+		}
+		return Booking{}, err
+	}
+	return booking, nil
+}
+
+func findBooking(tx *gorm.DB, bookingId string) (Booking, error) {
+	var booking Booking
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("booking_id=?", bookingId).Find(&booking).Error
+	if err != nil {
+		return Booking{}, err
+	} else if booking.BookingID == "" {
+		return Booking{}, errors.New("booking not found")
+	}
+	return booking, nil
+}
+
+// approve a pending booking if its days are still free, or reject it with a
+// reason the seller can read
+func ReviewBooking(bookingId string, adminId string, input ReviewInput) (Booking, error) {
+	reason := strings.TrimSpace(input.Reason)
+	if !input.Approve && reason == "" {
+		return Booking{}, errors.New("give a reason for rejecting the booking")
+	}
+	var booking Booking
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		var err error
+		booking, err = findBooking(tx, bookingId)
+		if err != nil {
+			return err
+		} else if booking.Status != BookingPending {
+			return errors.New("booking is " + booking.Status + " and cannot be reviewed")
+		}
+
+		if input.Approve {
+			if err := lockPlacement(tx, booking.AdCategory); err != nil {
+				return err
+			}
+			availability, err := checkAvailability(tx, booking.AdCategory, booking.StartDate, booking.EndDate, booking.BookingID)
+			if err != nil {
+				return err
+			} else if !availability.Available {
+				return unavailable(availability)
+			}
+			booking.Status = BookingApproved
+		} else {
+			booking.Status = BookingRejected
+		}
+		now := time.Now()
+		booking.Reason = reason
+		booking.ReviewedBy = adminId
+		booking.ReviewedAt = &now
+		if booking.Status == BookingApproved {
+			due := payBy(now, booking.EndDate, paymentWindow())
+			booking.PayBy = &due
+		}
+		return tx.Model(&Booking{}).Where("booking_id=?", booking.BookingID).Updates(map[string]interface{}{
+			"status":      booking.Status,
+			"reason":      booking.Reason,
+			"reviewed_by": booking.ReviewedBy,
+			"reviewed_at": now,
+			"pay_by":      booking.PayBy,
+		}).Error
+	})
+	if err != nil {
+		return booking, err
+	} else if booking.Status == BookingRejected {
+		deleteBookingImage(booking)
+	}
+	return booking, nil
+}
+
+// withdraw a booking that has not been paid for
+func CancelBooking(userId string, bookingId string) (Booking, error) {
+	var booking Booking
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		var err error
+		booking, err = findBooking(tx, bookingId)
+		if err != nil {
+			return err
+		} else if booking.UserID != userId {
+			return errors.New("booking not found")
+		} else if booking.Status != BookingPending && booking.Status != BookingApproved {
+			return errors.New("booking is " + booking.Status + " and cannot be cancelled")
+		}
+		booking.Status = BookingCancelled
+		return tx.Model(&Booking{}).Where("booking_id=?", booking.BookingID).Update("status", BookingCancelled).Error
+	})
+	if err != nil {
+		return booking, err
+	}
+	deleteBookingImage(booking)
+	return booking, nil
+}
+
+// approved booking of the user that can be paid for
+func FindPayableBooking(userId string, bookingId string) (Booking, error) {
+	var booking Booking
+	err := database.Database.Where("booking_id=? AND user_id=?", bookingId, userId).Find(&booking).Error
+	if err != nil {
+		return Booking{}, err
+	} else if booking.BookingID == "" {
+		return Booking{}, errors.New("booking not found")
+	} else if booking.Status != BookingApproved {
+		return Booking{}, errors.New("only approved bookings can be paid for, this one is " + booking.Status)
+	} else if !booking.EndDate.After(time.Now()) {
+		return Booking{}, errors.New("the booking has ended")
+	} else if booking.paymentOverdue(time.Now()) {
+		return Booking{}, errors.New("the time to pay for the booking is over, book the banner again")
+	}
+	return booking, nil
+}
+
+// make the main ad of a paid booking inside the transaction that records
+// the payment. The ad goes live on the first booked day. A payment that
+// comes in after the booking lost its slot is refused so it can be refunded
+func FulfilBooking(tx *gorm.DB, bookingId string, amount uint) (models.MainAd, error) {
+	booking, err := findBooking(tx, bookingId)
+	if err != nil {
+		return models.MainAd{}, err
+	} else if booking.Status != BookingApproved {
+		return models.MainAd{}, errors.New("booking is " + booking.Status + " and cannot be paid for")
+	}
+
+	now := time.Now()
+	if booking.paymentOverdue(now) {
+		return models.MainAd{}, errors.New("the time to pay for the booking is over")
+	}
+	if err := lockPlacement(tx, booking.AdCategory); err != nil {
+		return models.MainAd{}, err
+	}
+	availability, err := checkAvailability(tx, booking.AdCategory, booking.StartDate, booking.EndDate, booking.BookingID)
+	if err != nil {
+		return models.MainAd{}, err
+	} else if !availability.Available {
+		return models.MainAd{}, unavailable(availability)
+	}
+	end := booking.EndDate
+	status := scheduleStatus(booking.StartDate, &end, now)
+	if status == models.MainAdExpired {
+		return models.MainAd{}, errors.New("the booking has ended")
+	}
+	ad := models.MainAd{
+		Advertid:    uuid.New().String(),
+		AdBy:        booking.UserID,
+		AdName:      booking.AdName,
+		AdImage:     booking.AdImage,
+		AdImageKey:  booking.AdImageKey,
+		AdLink:      booking.AdLink,
+		AdCategory:  booking.AdCategory,
+		AdActive:    status == models.MainAdLive,
+		Status:      status,
+		DateCreated: now,
+		StartDate:   booking.StartDate,
+		EndingDate:  &end,
+		AmountPaid:  amount,
+		DailyRate:   amount / uint(booking.Days),
+	}
+	if err := tx.Create(&ad).Error; err != nil {
+		return models.MainAd{}, err
+	}
+	err = tx.Model(&Booking{}).Where("booking_id=?", booking.BookingID).
+		Updates(map[string]interface{}{"status": BookingPaid, "ad_id": ad.Advertid}).Error
+	if err != nil {
+		return models.MainAd{}, err
+	}
+	return ad, nil
+}
+
+// the banner of a booking that will never run. Paid bookings hand their
+// image to the main ad and keep it
+func deleteBookingImage(booking Booking) {
+	if booking.AdImageKey == "" {
+		return
+	}
+	if err := images.DeleteRenditions(booking.AdImageKey); err != nil {
+		log.Printf("could not delete the image of booking %s: %v", booking.BookingID, err)
+	}
+}
+
+// bookings whose days are over without being paid for, and approved ones
+// that were not paid for in time, along with their images
+func ExpireBookings() error {
+	now := time.Now()
+	var expired []Booking
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("(status IN ? AND end_date <= ?) OR (status=? AND pay_by <= ?)",
+				[]string{BookingPending, BookingApproved}, now, BookingApproved, now).
+			Find(&expired).Error
+		if err != nil || len(expired) == 0 {
+			return err
+		}
+		ids := make([]string, len(expired))
+		for n, booking := range expired {
+			ids[n] = booking.BookingID
+		}
+		return tx.Model(&Booking{}).Where("booking_id IN ?", ids).Update("status", BookingExpired).Error
+	})
+	if err != nil {
+		return err
+	}
+	for _, booking := range expired {
+		deleteBookingImage(booking)
+	}
+	return nil
+}
+
+func FetchUserBookings(userId string) ([]Booking, error) {
+	var bookings []Booking
+	err := database.Database.Where("user_id=?", userId).Order("created_at DESC").Find(&bookings).Error
+	if err != nil {
+		return []Booking{}, err
+	}
+	return bookings, nil
+}
+
+// bookings waiting for review, oldest first
+func FetchPendingBookings() ([]Booking, error) {
+	var bookings []Booking
+	err := database.Database.Where("status=?", BookingPending).Order("created_at, id").Find(&bookings).Error
+	if err != nil {
+		return []Booking{}, err
+	}
+	return bookings, nil
+}
@@ -0,0 +1,128 @@
+package mainad
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseBookingDays(t *testing.T) {
+	now := time.Date(2024, 3, 1, 15, 0, 0, 0, time.UTC)
+
+	type testCase struct {
+		name     string
+		from     string
+		to       string
+		wantDays int
+		wantErr  bool
+	}
+	cases := []testCase{
+		{"today only", "2024-03-01", "2024-03-01", 1, false},
+		{"a week", "2024-03-04", "2024-03-10", 7, false},
+		{"starts in the past", "2024-02-29", "2024-02-29", 0, true},
+		{"ends before it starts", "2024-03-05", "2024-03-04", 0, true},
+		{"not a date", "tomorrow", "2024-03-04", 0, true},
+		{"longest", "2024-03-01", "2024-05-29", MaxPaidDays, false},
+		{"too long", "2024-03-01", "2024-05-30", 0, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			start, end, days, err := ParseBookingDays(c.from, c.to, now)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error=%v got %v", c.wantErr, err)
+			} else if err != nil {
+				return
+			}
+			if days != c.wantDays {
+				t.Errorf("expected %d days got %d", c.wantDays, days)
+			}
+			if start.Format(bookingDay) != c.from || end.AddDate(0, 0, -1).Format(bookingDay) != c.to {
+				t.Errorf("expected %s to %s got %v to %v", c.from, c.to, start, end)
+			}
+		})
+	}
+}
+
+func TestBusiestDays(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	bookings := []Booking{
+		{StartDate: day(1), EndDate: day(4)},
+		{StartDate: day(2), EndDate: day(3)},
+		{StartDate: day(3), EndDate: day(6)},
+	}
+
+	type testCase struct {
+		name     string
+		start    time.Time
+		end      time.Time
+		capacity int
+		wantMost int
+		wantFull []string
+	}
+	cases := []testCase{
+		{"room on every day", day(1), day(6), 3, 2, []string{}},
+		{"full on the busy days", day(1), day(6), 2, 2, []string{"2024-03-02", "2024-03-03"}},
+		{"only the days asked for", day(4), day(6), 1, 1, []string{"2024-03-04", "2024-03-05"}},
+		{"after every booking", day(6), day(8), 1, 0, []string{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			most, full := busiestDays(bookings, c.start, c.end, c.capacity)
+			if most != c.wantMost {
+				t.Errorf("expected %d booked got %d", c.wantMost, most)
+			}
+			if !reflect.DeepEqual(full, c.wantFull) {
+				t.Errorf("expected full days %v got %v", c.wantFull, full)
+			}
+		})
+	}
+}
+
+func TestPayBy(t *testing.T) {
+	approved := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	type testCase struct {
+		name string
+		end  time.Time
+		want time.Time
+	}
+	cases := []testCase{
+		{"within the window", time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 3, 9, 0, 0, 0, time.UTC)},
+		{"ends before the window", time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := payBy(approved, c.end, 48*time.Hour); !got.Equal(c.want) {
+				t.Errorf("expected %v got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestPaymentOverdue(t *testing.T) {
+	due := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	type testCase struct {
+		name    string
+		booking Booking
+		now     time.Time
+		want    bool
+	}
+	cases := []testCase{
+		{"not approved yet", Booking{}, due, false},
+		{"within the time to pay", Booking{PayBy: &due}, due.Add(-time.Hour), false},
+		{"due now", Booking{PayBy: &due}, due, true},
+		{"past the time to pay", Booking{PayBy: &due}, due.Add(time.Hour), true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.booking.paymentOverdue(c.now); got != c.want {
+				t.Errorf("expected %v got %v", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package mainad
+
+import (
+	"net/http"
+	"strings"
+
+	"eleliafrika.com/backend/auth"
+	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/users"
+	"github.com/gin-gonic/gin"
+)
+
+// how many slots of a placement are taken between two days
+func GetAvailability(context *gin.Context) {
+	placement := strings.TrimSpace(context.Query("category"))
+	if placement == "" {
+		placement = HomepagePlacement
+	}
+	availability, err := CheckAvailability(placement, context.Query("from"), context.Query("to"))
+	if err != nil {
+		response := models.Reply{
+			Message: "could not check the availability",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "availability fetched",
+		Success: true,
+		Data:    availability,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+func CreateBookingHandler(context *gin.Context) {
+	user, ok := users.CurrentUserOrReply(context)
+	if !ok {
+		return
+	}
+	var input BookingInput
+	if err := context.ShouldBindJSON(&input); err != nil {
+		response := models.Reply{
+			Message: "could not bind data from the user",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	booking, err := CreateBooking(user.UserID, input)
+	if err != nil {
+		response := models.Reply{
+			Message: "could not book the banner",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "banner booked, it will be reviewed before it can be paid for",
+		Success: true,
+		Data:    booking,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+func GetUserBookings(context *gin.Context) {
+	user, ok := users.CurrentUserOrReply(context)
+	if !ok {
+		return
+	}
+	bookings, err := FetchUserBookings(user.UserID)
+	if err != nil {
+		response := models.Reply{
+			Message: "error fetching the bookings",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "bookings fetched",
+		Success: true,
+		Data:    bookings,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+func CancelBookingHandler(context *gin.Context) {
+	user, ok := users.CurrentUserOrReply(context)
+	if !ok {
+		return
+	}
+	booking, err := CancelBooking(user.UserID, context.Query("id"))
+	if err != nil {
+		response := models.Reply{
+			Message: "could not cancel the booking",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "booking cancelled",
+		Success: true,
+		Data:    booking,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+// bookings waiting for an admin, oldest first
+func GetPendingBookings(context *gin.Context) {
+	bookings, err := FetchPendingBookings()
+	if err != nil {
+		response := models.Reply{
+			Message: "error fetching the bookings",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "bookings fetched",
+		Success: true,
+		Data:    bookings,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+func ReviewBookingHandler(context *gin.Context) {
+	claims, err := auth.ValidateToken(context)
+	if err != nil {
+		response := models.Reply{
+			Message: "error authorizing admin",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return
+	}
+	var input ReviewInput
+	if err := context.ShouldBindJSON(&input); err != nil {
+		response := models.Reply{
+			Message: "could not bind data from the user",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	booking, err := ReviewBooking(context.Query("id"), claims.Subject, input)
+	if err != nil {
+		response := models.Reply{
+			Message: "could not review the booking",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "booking " + booking.Status,
+		Success: true,
+		Data:    booking,
+	}
+	context.JSON(http.StatusOK, response)
+}
@@ -1,12 +1,13 @@
 package mainad
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
-	"eleliafrika.com/backend/category"
+	"eleliafrika.com/backend/auth"
 	"eleliafrika.com/backend/models"
-	"eleliafrika.com/backend/users"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -27,8 +28,13 @@ func CreateMainAd(context *gin.Context) {
 	adid := uuid.New()
 	currentTime := time.Now()
 	var currentuserId string
-	formattedTime := currentTime.Format("2006-01-02 15:04:05")
+	if createAdInput.StartDate.IsZero() {
+		createAdInput.StartDate = currentTime
+	}
 	success, err := ValidateMainAdInput(&createAdInput)
+	if err == nil {
+		err = ValidateSchedule(createAdInput.StartDate, createAdInput.EndingDate)
+	}
 
 	if err != nil {
 		response := models.Reply{
@@ -47,51 +53,40 @@ func CreateMainAd(context *gin.Context) {
 		context.JSON(http.StatusBadRequest, response)
 		return
 	} else {
-		currentuser, err := users.CurrentUser(context)
+		// ads made here are house ads, sellers book theirs
+		claims, err := auth.ValidateToken(context)
 		if err != nil {
 			response := models.Reply{
-				Message: "could not find current user",
+				Message: "error authorizing admin",
 				Error:   err.Error(),
 				Success: false,
 			}
-			context.JSON(http.StatusBadRequest, response)
-			return
-		} else if currentuser.Firstname == "" {
-			response := models.Reply{
-				Message: "user not found",
-				Success: false,
-			}
-			context.JSON(http.StatusNetworkAuthenticationRequired, response)
+			context.JSON(http.StatusUnauthorized, response)
 			return
 		} else {
-			currentuserId = currentuser.UserID
+			currentuserId = claims.Subject
 			newMainAd := models.MainAd{
 				Advertid:    adid.String(),
 				AdBy:        currentuserId,
 				AdImage:     createAdInput.AdImage,
 				AdName:      createAdInput.AdName,
-				DateCreated: formattedTime,
+				DateCreated: currentTime,
+				StartDate:   createAdInput.StartDate,
+				EndingDate:  createAdInput.EndingDate,
+				Status:      models.MainAdInactive,
 				AdCategory:  createAdInput.AdCategory,
+				AdLink:      createAdInput.AdLink,
 			}
 
-			// check if the caegory exists
-			categoryExists, err := category.FetchSingleCategory(createAdInput.AdCategory)
-			if err != nil {
+			// house ads can go on the homepage or in a category
+			if err := validatePlacement(createAdInput.AdCategory); err != nil {
 				response := models.Reply{
-					Message: "error fetching the category provided",
+					Message: "the placement provided is not valid",
 					Error:   err.Error(),
 					Success: false,
 				}
 				context.JSON(http.StatusBadRequest, response)
 				return
-			} else if categoryExists.CategoryName == "" {
-				response := models.Reply{
-					Message: "the category provided does not exist",
-					Success: false,
-					Data:    newMainAd,
-				}
-				context.JSON(http.StatusBadRequest, response)
-				return
 			}
 			ad, err := newMainAd.Save()
 			if err != nil {
@@ -144,14 +139,86 @@ func GetAllMainAds(context *gin.Context) {
 		return
 	}
 }
+
+// ads waiting for a moderator to look at their new creative
+func GetMainAdsInReview(context *gin.Context) {
+	adlist, err := FetchMainAdsInReview()
+	if err != nil {
+		response := models.Reply{
+			Message: "error fetching the ads",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "ads fetched",
+		Success: true,
+		Data:    adlist,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+// edit the name, image, category or ending date of an ad. Advertisers can
+// edit their own ads and moderators any ad
 func UpdateMainAd(context *gin.Context) {
+	claims, err := auth.ValidateToken(context)
+	if err != nil {
+		response := models.Reply{
+			Message: "error authorizing user",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return
+	}
+	var updateInput UpdateMainAdInput
+	if err := context.ShouldBindJSON(&updateInput); err != nil {
+		response := models.Reply{
+			Message: "could not bind data from the user",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
 
+	moderator := claims.Can(auth.PermissionModerateAds)
+	if claims.SubjectType == auth.SubjectAdmin && !moderator {
+		response := models.Reply{
+			Message: "admin cannot edit ads",
+			Error:   errors.New("permission denied").Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusForbidden, response)
+		return
+	}
+	updatedAd, err := EditMainAd(context.Query("id"), claims.Subject, moderator, updateInput)
+	if err != nil {
+		response := models.Reply{
+			Message: "error updating the ad",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	message := "ad updated succesfully"
+	if updatedAd.Status == models.MainAdInReview {
+		message = "ad updated, it will show again once it has been reviewed"
+	}
+	response := models.Reply{
+		Message: message,
+		Success: true,
+		Data:    updatedAd,
+	}
+	context.JSON(http.StatusOK, response)
 }
 func GetSingleMainAd(context *gin.Context) {
 	adid := context.Query("id")
-	query := "ad_id=" + adid
 
-	singlead, err := GetSingleMainAdUtil(query)
+	singlead, err := GetSingleMainAdUtil("ad_id=?", adid)
 	if err != nil {
 		response := models.Reply{
 			Message: "error fetching single ad",
@@ -187,10 +254,9 @@ func GetSingleMainAd(context *gin.Context) {
 }
 func DeleteMainAd(context *gin.Context) {
 	adid := context.Query("id")
-	query := "ad_id=" + adid
 
 	// check if ad exists and is not deleted or inactive
-	adExist, err := GetSingleMainAdUtil(query)
+	adExist, err := GetSingleMainAdUtil("ad_id=?", adid)
 	if err != nil {
 		response := models.Reply{
 			Message: "error fetching the ad",
@@ -221,9 +287,9 @@ func DeleteMainAd(context *gin.Context) {
 		context.JSON(http.StatusOK, response)
 		return
 	} else {
-		deletedAd, err := UpdateMainAdutil(query, models.MainAd{
+		deletedAd, err := UpdateMainAdutil(models.MainAd{
 			IsDeleted: true,
-		})
+		}, "ad_id=?", adid)
 		if err != nil {
 			response := models.Reply{
 				Message: "error deleting the ad",
@@ -247,10 +313,9 @@ func DeleteMainAd(context *gin.Context) {
 
 func RestoreMainAd(context *gin.Context) {
 	adid := context.Query("id")
-	query := "ad_id=" + adid
 
 	// check if ad exists and is not deleted or inactive
-	adExist, err := GetSingleMainAdUtil(query)
+	adExist, err := GetSingleMainAdUtil("ad_id=?", adid)
 	if err != nil {
 		response := models.Reply{
 			Message: "error fetching the ad",
@@ -274,7 +339,7 @@ func RestoreMainAd(context *gin.Context) {
 		context.JSON(http.StatusOK, response)
 		return
 	} else {
-		deletedAd, err := RestoreAdUtil(query)
+		deletedAd, err := RestoreAdUtil("ad_id=?", adid)
 		if err != nil {
 			response := models.Reply{
 				Message: "error restoring the ad",
@@ -298,10 +363,9 @@ func RestoreMainAd(context *gin.Context) {
 
 func ActivateMainAd(context *gin.Context) {
 	adid := context.Query("id")
-	query := "ad_id=" + adid
 
 	// check if ad exists and is not deleted or inactive
-	adExist, err := GetSingleMainAdUtil(query)
+	adExist, err := GetSingleMainAdUtil("ad_id=?", adid)
 	if err != nil {
 		response := models.Reply{
 			Message: "error fetching the ad",
@@ -318,9 +382,9 @@ func ActivateMainAd(context *gin.Context) {
 		context.JSON(http.StatusOK, response)
 		return
 
-	} else if adExist.AdActive {
+	} else if adExist.Status == models.MainAdLive || adExist.Status == models.MainAdScheduled {
 		response := models.Reply{
-			Message: "ad is active",
+			Message: "ad is already " + adExist.Status,
 			Success: true,
 		}
 		context.JSON(http.StatusOK, response)
@@ -333,9 +397,7 @@ func ActivateMainAd(context *gin.Context) {
 		context.JSON(http.StatusOK, response)
 		return
 	} else {
-		activatedtedAd, err := UpdateMainAdutil(query, models.MainAd{
-			AdActive: true,
-		})
+		activatedtedAd, err := EnableMainAd(adid)
 		if err != nil {
 			response := models.Reply{
 				Message: "error activating the ad",
@@ -359,10 +421,9 @@ func ActivateMainAd(context *gin.Context) {
 
 func DeactivateMainAd(context *gin.Context) {
 	adid := context.Query("id")
-	query := "ad_id=" + adid
 
 	// check if ad exists and is not deleted or inactive
-	adExist, err := GetSingleMainAdUtil(query)
+	adExist, err := GetSingleMainAdUtil("ad_id=?", adid)
 	if err != nil {
 		response := models.Reply{
 			Message: "error fetching the ad",
@@ -378,7 +439,7 @@ func DeactivateMainAd(context *gin.Context) {
 		}
 		context.JSON(http.StatusOK, response)
 		return
-	} else if !adExist.AdActive {
+	} else if adExist.Status == models.MainAdInactive {
 		response := models.Reply{
 			Message: "ad is not active",
 			Success: true,
@@ -386,7 +447,7 @@ func DeactivateMainAd(context *gin.Context) {
 		context.JSON(http.StatusOK, response)
 		return
 	} else {
-		deactivatedtedAd, err := DeactivateUtil(query)
+		deactivatedtedAd, err := DisableMainAd(adid)
 		if err != nil {
 			response := models.Reply{
 				Message: "error deactivating the ad",
@@ -407,3 +468,33 @@ func DeactivateMainAd(context *gin.Context) {
 
 	}
 }
+
+// banners showing right now in a category, for the storefront
+func GetLiveMainAds(context *gin.Context) {
+	category := strings.TrimSpace(context.Query("category"))
+	if category == "" {
+		response := models.Reply{
+			Message: "a category is needed",
+			Error:   errors.New("category query is empty").Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	live, err := FetchLiveMainAds(category)
+	if err != nil {
+		response := models.Reply{
+			Message: "error fetching the ads",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "live ads fetched",
+		Success: true,
+		Data:    live,
+	}
+	context.JSON(http.StatusOK, response)
+}
@@ -1,20 +1,33 @@
 package mainad
 
 import (
-	"eleliafrika.com/backend/users"
+	"eleliafrika.com/backend/auth"
 	"github.com/gin-gonic/gin"
 )
 
 func Mainadsroutes(router *gin.Engine) {
 	mainadsroutes := router.Group("/mainads")
 	{
-		mainadsroutes.POST("/create", users.JWTAuthMiddleWare(), CreateMainAd)
+		mainadsroutes.POST("/create", auth.RequireAdmin(auth.PermissionModerateAds), CreateMainAd)
 		mainadsroutes.GET("/getmainads", GetAllMainAds)
 		mainadsroutes.GET("/getsinglemainad", GetSingleMainAd)
-		mainadsroutes.POST("/update", users.JWTAuthMiddleWare(), UpdateMainAd)
-		mainadsroutes.POST("/delete", users.JWTAuthMiddleWare(), DeleteMainAd)
-		mainadsroutes.POST("/restore", users.JWTAuthMiddleWare(), RestoreMainAd)
-		mainadsroutes.POST("/activate", users.JWTAuthMiddleWare(), ActivateMainAd)
-		mainadsroutes.POST("/deactivate", users.JWTAuthMiddleWare(), DeactivateMainAd)
+		mainadsroutes.GET("/review", auth.RequireAdmin(auth.PermissionModerateAds), GetMainAdsInReview)
+		mainadsroutes.GET("/live", GetLiveMainAds)
+		mainadsroutes.GET("/serve", ServeMainAdHandler)
+		mainadsroutes.GET("/click", ClickMainAd)
+		mainadsroutes.GET("/stats", auth.JWTAuthMiddleWare(), GetMainAdStats)
+		mainadsroutes.POST("/update", auth.JWTAuthMiddleWare(), UpdateMainAd)
+		mainadsroutes.POST("/delete", auth.RequireAdmin(auth.PermissionModerateAds), DeleteMainAd)
+		mainadsroutes.POST("/restore", auth.RequireAdmin(auth.PermissionModerateAds), RestoreMainAd)
+		mainadsroutes.POST("/activate", auth.RequireAdmin(auth.PermissionModerateAds), ActivateMainAd)
+		mainadsroutes.POST("/deactivate", auth.RequireAdmin(auth.PermissionModerateAds), DeactivateMainAd)
+
+		// sellers book banners and pay for them once an admin approves
+		mainadsroutes.GET("/availability", GetAvailability)
+		mainadsroutes.POST("/bookings/create", auth.RequireUser(), CreateBookingHandler)
+		mainadsroutes.GET("/bookings", auth.RequireUser(), GetUserBookings)
+		mainadsroutes.POST("/bookings/cancel", auth.RequireUser(), CancelBookingHandler)
+		mainadsroutes.GET("/bookings/pending", auth.RequireAdmin(auth.PermissionModerateAds), GetPendingBookings)
+		mainadsroutes.POST("/bookings/review", auth.RequireAdmin(auth.PermissionModerateAds), ReviewBookingHandler)
 	}
 }
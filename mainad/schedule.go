@@ -0,0 +1,88 @@
+package mainad
+
+import (
+	"errors"
+	"time"
+
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/models"
+)
+
+// where an enabled ad with these dates is in its schedule
+func scheduleStatus(start time.Time, end *time.Time, now time.Time) string {
+	if end != nil && !end.After(now) {
+		return models.MainAdExpired
+	} else if start.After(now) {
+		return models.MainAdScheduled
+	}
+	return models.MainAdLive
+}
+
+// the start defaults to now and the ad has to end after it starts
+func ValidateSchedule(start time.Time, end *time.Time) error {
+	if end != nil && !end.After(start) {
+		return errors.New("the ad should end after it starts")
+	}
+	return nil
+}
+
+// start showing an ad on its schedule, straight away when it has already
+// started
+func EnableMainAd(adId string) (models.MainAd, error) {
+	ad, err := GetSingleMainAdUtil("ad_id=?", adId)
+	if err != nil {
+		return models.MainAd{}, err
+	} else if ad.Advertid == "" {
+		return models.MainAd{}, errors.New("ad does not exist")
+	}
+	status := scheduleStatus(ad.StartDate, ad.EndingDate, time.Now())
+	if status == models.MainAdExpired {
+		return models.MainAd{}, errors.New("the ad has ended, give it a new ending date first")
+	}
+	return setStatus(adId, status)
+}
+
+// stop showing an ad until it is enabled again
+func DisableMainAd(adId string) (models.MainAd, error) {
+	return setStatus(adId, models.MainAdInactive)
+}
+
+func setStatus(adId string, status string) (models.MainAd, error) {
+	result := database.Database.Model(&models.MainAd{}).Where("ad_id=?", adId).
+		Updates(map[string]interface{}{"status": status, "is_active": status == models.MainAdLive})
+	if result.Error != nil {
+		return models.MainAd{}, result.Error
+	} else if result.RowsAffected == 0 {
+		return models.MainAd{}, errors.New("could not update the main ad")
+	}
+	return GetSingleMainAdUtil("ad_id=?", adId)
+}
+
+// put scheduled ads live once they start and take ads down once they end
+func ScheduleMainAds() error {
+	now := time.Now()
+	err := database.Database.Model(&models.MainAd{}).
+		Where("status=? AND is_deleted=? AND start_date <= ? AND (ending_date IS NULL OR ending_date > ?)", models.MainAdScheduled, false, now, now).
+		Updates(map[string]interface{}{"status": models.MainAdLive, "is_active": true}).Error
+	if err != nil {
+		return err
+	}
+	return database.Database.Model(&models.MainAd{}).
+		Where("status IN ? AND ending_date IS NOT NULL AND ending_date <= ?", []string{models.MainAdLive, models.MainAdScheduled, models.MainAdInReview}, now).
+		Updates(map[string]interface{}{"status": models.MainAdExpired, "is_active": false}).Error
+}
+
+// ads showing right now in a category. The dates are checked as well so an
+// ad stops showing on time even before the scheduler has run
+func FetchLiveMainAds(category string) ([]models.MainAd, error) {
+	now := time.Now()
+	var live []models.MainAd
+	err := database.Database.
+		Where("ad_category=? AND status=? AND is_deleted=? AND start_date <= ? AND (ending_date IS NULL OR ending_date > ?)",
+			category, models.MainAdLive, false, now, now).
+		Order("start_date").Find(&live).Error
+	if err != nil {
+		return []models.MainAd{}, err
+	}
+	return live, nil
+}
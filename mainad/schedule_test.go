@@ -0,0 +1,47 @@
+package mainad
+
+import (
+	"testing"
+	"time"
+
+	"eleliafrika.com/backend/models"
+)
+
+func TestScheduleStatus(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	type testCase struct {
+		name  string
+		start time.Time
+		end   *time.Time
+		want  string
+	}
+	cases := []testCase{
+		{"started and running", past, &future, models.MainAdLive},
+		{"started without an end", past, nil, models.MainAdLive},
+		{"starts later", future, nil, models.MainAdScheduled},
+		{"ended", past.Add(-time.Hour), &past, models.MainAdExpired},
+		{"ends now", past, &now, models.MainAdExpired},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := scheduleStatus(c.start, c.end, now); got != c.want {
+				t.Errorf("expected %s got %s", c.want, got)
+			}
+			ad := models.MainAd{Status: c.want, StartDate: c.start, EndingDate: c.end}
+			if ad.IsLive(now) != (c.want == models.MainAdLive) {
+				t.Errorf("expected live=%v", c.want == models.MainAdLive)
+			}
+		})
+	}
+
+	if err := ValidateSchedule(now, &past); err == nil {
+		t.Error("expected an ad ending before it starts to be refused")
+	}
+	if err := ValidateSchedule(now, nil); err != nil {
+		t.Errorf("expected an ad without an end to be allowed: %v", err)
+	}
+}
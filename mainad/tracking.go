@@ -0,0 +1,204 @@
+package mainad
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/rand"
+	"net/url"
+	"regexp"
+	"time"
+
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	EventImpression = "impression"
+	EventClick      = "click"
+
+	// the same viewer is counted once per ad in every window
+	dedupWindow = 30 * time.Minute
+	// how long the seen viewers are kept for
+	eventRetention = 48 * time.Hour
+	// days are kept as text so they sort and compare in order
+	statsDay = "2006-01-02"
+)
+
+// impressions and clicks of an ad on one day
+type MainAdStat struct {
+	AdID        string `gorm:"column:ad_id;primaryKey" json:"adid"`
+	Day         string `gorm:"column:day;primaryKey;size:10" json:"day"`
+	Impressions int64  `gorm:"column:impressions;not null;default:0" json:"impressions"`
+	Clicks      int64  `gorm:"column:clicks;not null;default:0" json:"clicks"`
+}
+
+// a viewer that has been counted for an ad in a window, so reloading a page
+// or clicking again is not counted twice
+type MainAdEvent struct {
+	AdID      string    `gorm:"column:ad_id;primaryKey"`
+	Kind      string    `gorm:"column:kind;primaryKey"`
+	Viewer    string    `gorm:"column:viewer;primaryKey"`
+	Window    time.Time `gorm:"column:window_start;primaryKey"`
+	CreatedAt time.Time `gorm:"column:created_at;index"`
+}
+
+func MigrateStats() error {
+	return database.Database.AutoMigrate(&MainAdStat{}, &MainAdEvent{})
+}
+
+// crawlers, link previews and scripts
+var botAgents = regexp.MustCompile(`(?i)bot|crawl|spider|slurp|preview|facebookexternalhit|headless|curl|wget|python|java/|go-http-client|okhttp|axios|postman|httpclient|scrapy`)
+
+func IsBot(userAgent string) bool {
+	return userAgent == "" || botAgents.MatchString(userAgent)
+}
+
+// viewers are known by their address and browser, hashed so neither is
+// stored
+func ViewerKey(ip string, userAgent string) string {
+	sum := sha256.Sum256([]byte(ip + "|" + userAgent))
+	return hex.EncodeToString(sum[:16])
+}
+
+// only links to web pages can be opened from a banner
+func ValidateAdLink(link string) error {
+	if link == "" {
+		return nil
+	}
+	parsed, err := url.Parse(link)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("ad link should be a full http or https address")
+	}
+	return nil
+}
+
+// ads that paid more a day come up more often, so a long booking does not
+// outweigh a short one at the same price. Ads that paid nothing still get a
+// share so house ads are shown
+func adWeight(ad models.MainAd) int64 {
+	if ad.DailyRate == 0 {
+		return 1
+	}
+	return int64(ad.DailyRate)
+}
+
+// pick one ad, each with a chance in proportion to its weight. roll returns
+// a number in [0, n)
+func pickWeighted(ads []models.MainAd, roll func(n int64) int64) (models.MainAd, bool) {
+	var total int64
+	for _, ad := range ads {
+		total += adWeight(ad)
+	}
+	if total == 0 {
+		return models.MainAd{}, false
+	}
+	point := roll(total)
+	for _, ad := range ads {
+		point -= adWeight(ad)
+		if point < 0 {
+			return ad, true
+		}
+	}
+	return ads[len(ads)-1], true
+}
+
+// the banner to show in a category's slot
+func ServeMainAd(category string) (models.MainAd, bool, error) {
+	live, err := FetchLiveMainAds(category)
+	if err != nil {
+		return models.MainAd{}, false, err
+	}
+	ad, ok := pickWeighted(live, rand.Int63n)
+	return ad, ok, nil
+}
+
+// count an impression or click once for each viewer in a window. Returns
+// whether it was counted
+func RecordEvent(adId string, kind string, viewer string, now time.Time) (bool, error) {
+	counted := false
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&MainAdEvent{
+			AdID:      adId,
+			Kind:      kind,
+			Viewer:    viewer,
+			Window:    now.Truncate(dedupWindow),
+			CreatedAt: now,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		counted = true
+
+		column := "impressions"
+		if kind == EventClick {
+			column = "clicks"
+		}
+		stat := MainAdStat{AdID: adId, Day: now.Format(statsDay)}
+		if kind == EventClick {
+			stat.Clicks = 1
+		} else {
+			stat.Impressions = 1
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "ad_id"}, {Name: "day"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{column: gorm.Expr("main_ad_stats." + column + " + 1")}),
+		}).Create(&stat).Error
+	})
+	return counted, err
+}
+
+// forget the viewers once their windows are long over
+func PruneEvents() error {
+	return database.Database.Where("created_at < ?", time.Now().Add(-eventRetention)).Delete(&MainAdEvent{}).Error
+}
+
+type DailyStat struct {
+	Day         string  `json:"day"`
+	Impressions int64   `json:"impressions"`
+	Clicks      int64   `json:"clicks"`
+	CTR         float64 `json:"ctr"`
+}
+
+type AdStats struct {
+	AdID   string      `json:"adid"`
+	Days   []DailyStat `json:"days"`
+	Totals DailyStat   `json:"totals"`
+}
+
+// clicks per impression in percent
+func clickThroughRate(impressions int64, clicks int64) float64 {
+	if impressions == 0 {
+		return 0
+	}
+	return float64(clicks) * 100 / float64(impressions)
+}
+
+func summarize(adId string, rows []MainAdStat) AdStats {
+	stats := AdStats{AdID: adId, Days: []DailyStat{}}
+	for _, row := range rows {
+		stats.Days = append(stats.Days, DailyStat{
+			Day:         row.Day,
+			Impressions: row.Impressions,
+			Clicks:      row.Clicks,
+			CTR:         clickThroughRate(row.Impressions, row.Clicks),
+		})
+		stats.Totals.Impressions += row.Impressions
+		stats.Totals.Clicks += row.Clicks
+	}
+	stats.Totals.CTR = clickThroughRate(stats.Totals.Impressions, stats.Totals.Clicks)
+	return stats
+}
+
+// daily stats of an ad between two days, both included
+func FetchAdStats(adId string, from time.Time, to time.Time) (AdStats, error) {
+	var rows []MainAdStat
+	err := database.Database.Where("ad_id=? AND day >= ? AND day <= ?", adId, from.Format(statsDay), to.Format(statsDay)).
+		Order("day").Find(&rows).Error
+	if err != nil {
+		return AdStats{}, err
+	}
+	return summarize(adId, rows), nil
+}
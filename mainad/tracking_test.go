@@ -0,0 +1,99 @@
+package mainad
+
+import (
+	"testing"
+	"time"
+
+	"eleliafrika.com/backend/models"
+)
+
+func TestPickWeighted(t *testing.T) {
+	ads := []models.MainAd{
+		{Advertid: "house"},
+		{Advertid: "small", AmountPaid: 99 * 30, DailyRate: 99},
+		{Advertid: "big", AmountPaid: 900, DailyRate: 900},
+	}
+	// the house ad has weight 1, so rolls 0, 1-99 and 100-999 fall on the
+	// three ads in order
+	type testCase struct {
+		roll int64
+		want string
+	}
+	cases := []testCase{{0, "house"}, {1, "small"}, {99, "small"}, {100, "big"}, {999, "big"}}
+	for _, c := range cases {
+		ad, ok := pickWeighted(ads, func(n int64) int64 {
+			if n != 1000 {
+				t.Fatalf("expected a total weight of 1000 got %d", n)
+			}
+			return c.roll
+		})
+		if !ok || ad.Advertid != c.want {
+			t.Errorf("roll %d: expected %s got %s", c.roll, c.want, ad.Advertid)
+		}
+	}
+
+	if _, ok := pickWeighted(nil, func(n int64) int64 { return 0 }); ok {
+		t.Error("expected nothing to be picked from no ads")
+	}
+}
+
+func TestBotsAndViewers(t *testing.T) {
+	browser := "Mozilla/5.0 (Linux; Android 13) AppleWebKit/537.36 Chrome/118.0 Mobile Safari/537.36"
+	for _, agent := range []string{"", "Googlebot/2.1", "curl/8.0.1", "facebookexternalhit/1.1", "python-requests/2.31"} {
+		if !IsBot(agent) {
+			t.Errorf("expected %q to be a bot", agent)
+		}
+	}
+	if IsBot(browser) {
+		t.Error("expected a browser not to be a bot")
+	}
+
+	if ViewerKey("10.0.0.1", browser) != ViewerKey("10.0.0.1", browser) {
+		t.Error("expected the same viewer to get the same key")
+	} else if ViewerKey("10.0.0.1", browser) == ViewerKey("10.0.0.2", browser) {
+		t.Error("expected different addresses to be different viewers")
+	}
+}
+
+func TestValidateAdLink(t *testing.T) {
+	for _, link := range []string{"", "https://shop.example.com/sale", "http://example.com"} {
+		if err := ValidateAdLink(link); err != nil {
+			t.Errorf("expected %q to be allowed: %v", link, err)
+		}
+	}
+	for _, link := range []string{"javascript:alert(1)", "/relative", "ftp://example.com", "https://"} {
+		if err := ValidateAdLink(link); err == nil {
+			t.Errorf("expected %q to be refused", link)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	stats := summarize("ad", []MainAdStat{
+		{Day: "2024-03-01", Impressions: 200, Clicks: 5},
+		{Day: "2024-03-02", Impressions: 0, Clicks: 0},
+		{Day: "2024-03-03", Impressions: 300, Clicks: 10},
+	})
+	if stats.Days[0].CTR != 2.5 || stats.Days[1].CTR != 0 {
+		t.Errorf("unexpected daily ctr %v", stats.Days)
+	}
+	if stats.Totals.Impressions != 500 || stats.Totals.Clicks != 15 || stats.Totals.CTR != 3 {
+		t.Errorf("unexpected totals %+v", stats.Totals)
+	}
+}
+
+func TestStatsRange(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	from, to, err := statsRange("", "", now)
+	if err != nil {
+		t.Fatal(err)
+	} else if from.Format(statsDay) != "2024-03-02" || to.Format(statsDay) != "2024-03-31" {
+		t.Errorf("expected the last 30 days got %s to %s", from.Format(statsDay), to.Format(statsDay))
+	}
+
+	for _, bad := range [][2]string{{"2024-03-10", "2024-03-01"}, {"march", ""}, {"2022-01-01", "2024-01-01"}} {
+		if _, _, err := statsRange(bad[0], bad[1], now); err == nil {
+			t.Errorf("expected %s to %s to be refused", bad[0], bad[1])
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package mainad
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"eleliafrika.com/backend/auth"
+	"eleliafrika.com/backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 366
+)
+
+// an ad to show with the address its clicks go through
+type ServedAd struct {
+	models.MainAd
+	ClickURL string `json:"clickurl"`
+}
+
+// count a view or click unless it came from a bot. Counting is best effort
+// and never keeps the ad from being served
+func recordFromRequest(context *gin.Context, adId string, kind string) {
+	userAgent := context.Request.UserAgent()
+	if IsBot(userAgent) {
+		return
+	}
+	if _, err := RecordEvent(adId, kind, ViewerKey(context.ClientIP(), userAgent), time.Now()); err != nil {
+		log.Printf("could not record the %s of ad %s: %v", kind, adId, err)
+	}
+}
+
+// one banner for a category's slot, rotated by what the live ads paid
+func ServeMainAdHandler(context *gin.Context) {
+	category := strings.TrimSpace(context.Query("category"))
+	if category == "" {
+		response := models.Reply{
+			Message: "a category is needed",
+			Error:   errors.New("category query is empty").Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	ad, ok, err := ServeMainAd(category)
+	if err != nil {
+		response := models.Reply{
+			Message: "error fetching the ads",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	} else if !ok {
+		response := models.Reply{
+			Message: "there are no ads",
+			Success: true,
+		}
+		context.JSON(http.StatusOK, response)
+		return
+	}
+
+	recordFromRequest(context, ad.Advertid, EventImpression)
+	response := models.Reply{
+		Message: "ad fetched",
+		Success: true,
+		Data:    ServedAd{MainAd: ad, ClickURL: "/mainads/click?id=" + ad.Advertid},
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+// count the click and send the viewer on to the advertiser
+func ClickMainAd(context *gin.Context) {
+	adid := context.Query("id")
+	ad, err := GetSingleMainAdUtil("ad_id=?", adid)
+	if err != nil {
+		response := models.Reply{
+			Message: "error fetching the ad",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	} else if ad.Advertid == "" || ad.IsDeleted || ad.AdLink == "" {
+		response := models.Reply{
+			Message: "ad does not exist",
+			Success: false,
+		}
+		context.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	if ad.IsLive(time.Now()) {
+		recordFromRequest(context, ad.Advertid, EventClick)
+	}
+	context.Header("Cache-Control", "no-store")
+	context.Redirect(http.StatusFound, ad.AdLink)
+}
+
+// the from and to days of a stats request, the last 30 days by default
+func statsRange(from string, to string, now time.Time) (time.Time, time.Time, error) {
+	end := now
+	if to != "" {
+		parsed, err := time.ParseInLocation(statsDay, to, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("to should be a date such as 2024-01-31")
+		}
+		end = parsed
+	}
+	start := end.AddDate(0, 0, 1-defaultStatsDays)
+	if from != "" {
+		parsed, err := time.ParseInLocation(statsDay, from, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("from should be a date such as 2024-01-01")
+		}
+		start = parsed
+	}
+	if start.After(end) {
+		return time.Time{}, time.Time{}, errors.New("from should not be after to")
+	} else if end.Sub(start) > maxStatsDays*24*time.Hour {
+		return time.Time{}, time.Time{}, errors.New("stats can be fetched for at most a year at a time")
+	}
+	return start, end, nil
+}
+
+// daily impressions, clicks and click through rate for the advertiser or
+// an admin
+func GetMainAdStats(context *gin.Context) {
+	claims, err := auth.ValidateToken(context)
+	if err != nil {
+		response := models.Reply{
+			Message: "error authorizing user",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return
+	}
+	adid := context.Query("id")
+	ad, err := GetSingleMainAdUtil("ad_id=?", adid)
+	if err != nil {
+		response := models.Reply{
+			Message: "error fetching the ad",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	} else if ad.Advertid == "" {
+		response := models.Reply{
+			Message: "ad does not exist",
+			Success: false,
+		}
+		context.JSON(http.StatusNotFound, response)
+		return
+	} else if claims.SubjectType != auth.SubjectAdmin && claims.Subject != ad.AdBy {
+		response := models.Reply{
+			Message: "only the advertiser can see the stats of this ad",
+			Error:   errors.New("user does not own the ad").Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusForbidden, response)
+		return
+	}
+
+	from, to, err := statsRange(context.Query("from"), context.Query("to"), time.Now())
+	if err != nil {
+		response := models.Reply{
+			Message: "invalid date range",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	stats, err := FetchAdStats(ad.Advertid, from, to)
+	if err != nil {
+		response := models.Reply{
+			Message: "error fetching the stats",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "stats fetched",
+		Success: true,
+		Data:    stats,
+	}
+	context.JSON(http.StatusOK, response)
+}
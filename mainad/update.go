@@ -0,0 +1,125 @@
+package mainad
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/images"
+	"eleliafrika.com/backend/models"
+)
+
+// the parts of an ad that can be edited, empty fields are left as they are.
+// A new image is sent as base64 and goes through the uploader
+type UpdateMainAdInput struct {
+	AdName     string     `json:"adname"`
+	AdImage    string     `json:"adimage"`
+	AdCategory string     `json:"adcategory"`
+	EndingDate *time.Time `json:"endingdate"`
+}
+
+// apply an edit to an ad and validate the result. Paid ads keep the
+// placement and days they were paid for unless a moderator changes them.
+// Returns whether the name or image changed
+func mergeMainAdUpdate(ad models.MainAd, input UpdateMainAdInput, moderator bool) (models.MainAd, bool, error) {
+	creativeChanged := input.AdImage != ""
+	if name := strings.TrimSpace(input.AdName); name != "" && name != ad.AdName {
+		ad.AdName = name
+		creativeChanged = true
+	}
+	if placement := strings.TrimSpace(input.AdCategory); placement != "" && placement != ad.AdCategory {
+		if ad.AmountPaid > 0 && !moderator {
+			return models.MainAd{}, false, errors.New("the placement of a paid ad cannot be changed, book a new banner instead")
+		}
+		ad.AdCategory = placement
+	}
+	if input.EndingDate != nil {
+		if ad.AmountPaid > 0 && !moderator && (ad.EndingDate == nil || input.EndingDate.After(*ad.EndingDate)) {
+			return models.MainAd{}, false, errors.New("a paid ad cannot run past the days paid for")
+		}
+		ad.EndingDate = input.EndingDate
+	}
+
+	if _, err := ValidateMainAdInput(&ad); err != nil {
+		return models.MainAd{}, false, err
+	} else if err := ValidateSchedule(ad.StartDate, ad.EndingDate); err != nil {
+		return models.MainAd{}, false, err
+	}
+	return ad, creativeChanged, nil
+}
+
+// edit an ad for its advertiser or a moderator. A creative changed by the
+// advertiser takes the ad down until a moderator activates it again. A
+// replaced image is deleted once the edit is saved
+func EditMainAd(adId string, editorId string, moderator bool, input UpdateMainAdInput) (models.MainAd, error) {
+	ad, err := GetSingleMainAdUtil("ad_id=?", adId)
+	if err != nil {
+		return models.MainAd{}, err
+	} else if ad.Advertid == "" {
+		return models.MainAd{}, errors.New("ad does not exist")
+	} else if !moderator && ad.AdBy != editorId {
+		return models.MainAd{}, errors.New("only the advertiser can edit this ad")
+	} else if ad.IsDeleted {
+		return models.MainAd{}, errors.New("ad is deleted, restore it before editing")
+	}
+	edited, creativeChanged, err := mergeMainAdUpdate(ad, input, moderator)
+	if err != nil {
+		return models.MainAd{}, err
+	}
+	if edited.AdCategory != ad.AdCategory {
+		if err := validatePlacement(edited.AdCategory); err != nil {
+			return models.MainAd{}, err
+		}
+	}
+
+	var uploaded images.UploadedImage
+	if input.AdImage != "" {
+		uploaded, err = images.UploadBase64Image(edited.AdName, input.AdImage)
+		if err != nil {
+			return models.MainAd{}, err
+		}
+		edited.AdImage = uploaded.ImageUrl
+		edited.AdImageKey = uploaded.ObjectKey
+	}
+
+	update := map[string]interface{}{
+		"ad_name":      edited.AdName,
+		"ad_image":     edited.AdImage,
+		"ad_image_key": edited.AdImageKey,
+		"ad_category":  edited.AdCategory,
+		"ending_date":  edited.EndingDate,
+	}
+	if creativeChanged && !moderator && ad.Status != models.MainAdExpired {
+		update["status"] = models.MainAdInReview
+		update["is_active"] = false
+	}
+	err = database.Database.Model(&models.MainAd{}).Where("ad_id=?", ad.Advertid).Updates(update).Error
+	if err != nil {
+		if uploaded.ObjectKey != "" {
+			if deleteErr := images.DeleteRenditions(uploaded.ObjectKey); deleteErr != nil {
+				return models.MainAd{}, fmt.Errorf("%v, and the upload could not be deleted: %v", err, deleteErr)
+			}
+		}
+		return models.MainAd{}, err
+	}
+	if uploaded.ObjectKey != "" && ad.AdImageKey != "" {
+		if err := images.DeleteRenditions(ad.AdImageKey); err != nil {
+			log.Printf("could not delete the replaced image %s: %v", ad.AdImageKey, err)
+		}
+	}
+	return GetSingleMainAdUtil("ad_id=?", ad.Advertid)
+}
+
+// ads whose creative changed and wait for a moderator, oldest first
+func FetchMainAdsInReview() ([]models.MainAd, error) {
+	var review []models.MainAd
+	err := database.Database.Where("status=? AND is_deleted=?", models.MainAdInReview, false).
+		Order("created_on, ad_id").Find(&review).Error
+	if err != nil {
+		return []models.MainAd{}, err
+	}
+	return review, nil
+}
@@ -0,0 +1,103 @@
+package mainad
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"eleliafrika.com/backend/models"
+)
+
+func TestValidateMainAdInput(t *testing.T) {
+	valid := models.MainAd{AdName: "Big sale", AdImage: "/images/files/sale/full.jpg", AdCategory: "electronics"}
+
+	type testCase struct {
+		name    string
+		edit    func(ad *models.MainAd)
+		wantErr bool
+	}
+	cases := []testCase{
+		{"valid", func(ad *models.MainAd) {}, false},
+		{"name is trimmed", func(ad *models.MainAd) { ad.AdName = "  Big sale  " }, false},
+		{"no name", func(ad *models.MainAd) { ad.AdName = "  " }, true},
+		{"name too long", func(ad *models.MainAd) { ad.AdName = strings.Repeat("a", maxAdNameLength+1) }, true},
+		{"no image", func(ad *models.MainAd) { ad.AdImage = "" }, true},
+		{"image with spaces", func(ad *models.MainAd) { ad.AdImage = "not an address" }, true},
+		{"short category", func(ad *models.MainAd) { ad.AdCategory = "tv" }, true},
+		{"category with special characters", func(ad *models.MainAd) { ad.AdCategory = "tv;drop" }, true},
+		{"link that is not web", func(ad *models.MainAd) { ad.AdLink = "javascript:alert(1)" }, true},
+		{"web link", func(ad *models.MainAd) { ad.AdLink = "https://example.com/sale" }, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ad := valid
+			c.edit(&ad)
+			ok, err := ValidateMainAdInput(&ad)
+			if (err != nil) != c.wantErr || ok == c.wantErr {
+				t.Fatalf("expected error=%v got %v", c.wantErr, err)
+			}
+			if err == nil && ad.AdName != strings.TrimSpace(ad.AdName) {
+				t.Errorf("expected the name to be trimmed got %q", ad.AdName)
+			}
+		})
+	}
+}
+
+func TestMergeMainAdUpdate(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 7)
+	later := end.AddDate(0, 0, 7)
+	sooner := end.AddDate(0, 0, -2)
+	ad := models.MainAd{
+		Advertid:   "ad",
+		AdName:     "Big sale",
+		AdImage:    "/images/files/sale/full.jpg",
+		AdCategory: "electronics",
+		StartDate:  start,
+		EndingDate: &end,
+	}
+	paid := ad
+	paid.AmountPaid = 700
+
+	type testCase struct {
+		name         string
+		ad           models.MainAd
+		input        UpdateMainAdInput
+		moderator    bool
+		wantCreative bool
+		wantErr      bool
+	}
+	cases := []testCase{
+		{"nothing changed", ad, UpdateMainAdInput{AdName: "Big sale"}, false, false, false},
+		{"new name", ad, UpdateMainAdInput{AdName: "Bigger sale"}, false, true, false},
+		{"new image", ad, UpdateMainAdInput{AdImage: "aGVsbG8="}, false, true, false},
+		{"new category", ad, UpdateMainAdInput{AdCategory: "furniture"}, false, false, false},
+		{"ends before it starts", ad, UpdateMainAdInput{EndingDate: &start}, false, false, true},
+		{"name too short", ad, UpdateMainAdInput{AdName: "ab"}, false, false, true},
+		{"paid ad moved", paid, UpdateMainAdInput{AdCategory: "furniture"}, false, false, true},
+		{"paid ad moved by a moderator", paid, UpdateMainAdInput{AdCategory: "furniture"}, true, false, false},
+		{"paid ad extended", paid, UpdateMainAdInput{EndingDate: &later}, false, false, true},
+		{"paid ad shortened", paid, UpdateMainAdInput{EndingDate: &sooner}, false, false, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			merged, creative, err := mergeMainAdUpdate(c.ad, c.input, c.moderator)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error=%v got %v", c.wantErr, err)
+			} else if err != nil {
+				return
+			}
+			if creative != c.wantCreative {
+				t.Errorf("expected creative changed=%v", c.wantCreative)
+			}
+			if c.input.AdCategory != "" && merged.AdCategory != c.input.AdCategory {
+				t.Errorf("expected category %s got %s", c.input.AdCategory, merged.AdCategory)
+			}
+			if c.input.EndingDate != nil && !merged.EndingDate.Equal(*c.input.EndingDate) {
+				t.Errorf("expected ending date %v got %v", c.input.EndingDate, merged.EndingDate)
+			}
+		})
+	}
+}
@@ -2,81 +2,75 @@ package mainad
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"eleliafrika.com/backend/database"
 	"eleliafrika.com/backend/models"
 )
 
-func ValidateMainAdInput(mainad *models.MainAd) (bool, error) {
-	mainaddetails := []string{mainad.AdImage, mainad.AdCategory}
-	charPattern := "[!@#$%^&*()_+\\-=\\[\\]{};:\\\\|,.<>?]"
-	for _, value := range mainaddetails {
-		if value == mainad.AdBy {
-			if len(value) < 3 {
-				return false, errors.New("ad owner id should be longer than 3 character long")
-			}
-		} else if value == mainad.AdImage {
-			if len(value) < 3 {
-				return false, errors.New("ad image string should be longer than 3 character long")
-			}
-		} else if value == mainad.Advertid {
-			if len(value) < 3 {
-				return false, errors.New("ad id should be longer than 3 character long")
-			}
-		} else if value == mainad.AdCategory {
-			if len(value) < 3 {
-				return false, errors.New("ad category should atleast be 3 characters long")
-			} else if regexp.MustCompile(charPattern).MatchString(mainad.AdCategory) {
-				return false, errors.New("ad category should not contain special character")
-			}
-		}
+var specialCharacters = regexp.MustCompile("[!@#$%^&*()_+\\-=\\[\\]{};:\\\\|,.<>?]")
+
+const maxAdNameLength = 100
 
+// the name, image, placement and link of an ad before it is saved
+func ValidateMainAdInput(mainad *models.MainAd) (bool, error) {
+	mainad.AdName = strings.TrimSpace(mainad.AdName)
+	mainad.AdCategory = strings.TrimSpace(mainad.AdCategory)
+	if utf8.RuneCountInString(mainad.AdName) < 3 {
+		return false, errors.New("ad name should atleast be 3 characters long")
+	} else if utf8.RuneCountInString(mainad.AdName) > maxAdNameLength {
+		return false, fmt.Errorf("ad name should be at most %d characters long", maxAdNameLength)
+	} else if len(mainad.AdImage) < 3 {
+		return false, errors.New("ad image string should be longer than 3 character long")
+	} else if strings.ContainsAny(mainad.AdImage, " \t\n") {
+		return false, errors.New("ad image should be the address of an uploaded image")
+	} else if len(mainad.AdCategory) < 3 {
+		return false, errors.New("ad category should atleast be 3 characters long")
+	} else if specialCharacters.MatchString(mainad.AdCategory) {
+		return false, errors.New("ad category should not contain special character")
+	} else if err := ValidateAdLink(mainad.AdLink); err != nil {
+		return false, err
 	}
 	return true, nil
 }
 
+// ads that are not deleted and have not ended
 func GetAllMainAdsUtil() ([]models.MainAd, error) {
 	var allmainads []models.MainAd
-	err := database.Database.Find(&allmainads).Error
+	err := database.Database.Where("is_deleted=? AND status<>? AND (ending_date IS NULL OR ending_date > ?)", false, models.MainAdExpired, time.Now()).
+		Find(&allmainads).Error
 	if err != nil {
 		return []models.MainAd{}, err
 	}
 
 	return allmainads, nil
 }
-func GetSingleMainAdUtil(query string) (models.MainAd, error) {
+func GetSingleMainAdUtil(query string, args ...interface{}) (models.MainAd, error) {
 	var singlemainad models.MainAd
-	err := database.Database.Where(query).Find(&singlemainad).Error
+	err := database.Database.Where(query, args...).Find(&singlemainad).Error
 	if err != nil {
 		return models.MainAd{}, err
 	}
 	return singlemainad, nil
 }
 
-func UpdateMainAdutil(query string, update models.MainAd) (models.MainAd, error) {
+func UpdateMainAdutil(update models.MainAd, query string, args ...interface{}) (models.MainAd, error) {
 	var updatedAd models.MainAd
 
-	result := database.Database.Model(&updatedAd).Where(query).Updates(update)
+	result := database.Database.Model(&updatedAd).Where(query, args...).Updates(update)
 	if result.RowsAffected == 0 {
 		return models.MainAd{}, errors.New("could not update the main ad")
 	}
 	return updatedAd, nil
 }
-func RestoreAdUtil(query string) (models.MainAd, error) {
-	var updatedAd models.MainAd
-
-	result := database.Database.Model(&updatedAd).Where(query).Update("is_deleted", false)
-	if result.RowsAffected == 0 {
-		return models.MainAd{}, errors.New("could not update the main ad")
-	}
-	return updatedAd, nil
-}
-
-func DeactivateUtil(query string) (models.MainAd, error) {
+func RestoreAdUtil(query string, args ...interface{}) (models.MainAd, error) {
 	var updatedAd models.MainAd
 
-	result := database.Database.Model(&updatedAd).Where(query).Update("is_active", false)
+	result := database.Database.Model(&updatedAd).Where(query, args...).Update("is_deleted", false)
 	if result.RowsAffected == 0 {
 		return models.MainAd{}, errors.New("could not update the main ad")
 	}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"errors"
+
+	"eleliafrika.com/backend/database"
+	"gorm.io/gorm"
+)
+
+// conversation between a buyer and the seller of one product. There is at
+// most one conversation per buyer, seller and product. Each participant has
+// their own count of messages they have not read yet
+type Conversation struct {
+	ConversationId string `gorm:"column:conversation_id;no null;unique" json:"conversation_id"`
+	CustomerId     string `gorm:"customer_id;not null;require" json:"customer_id"`
+	SellerId       string `gorm:"seller_id;not null;require" json:"seller_id"`
+	ProductId      string `gorm:"column:product_id;not null;default:''" json:"product_id"`
+	LastMessage    string `gorm:"column:last_message;type:text;" json:"last_message"`
+	MessagesCount  int    `gorm:"column:messages_count;default:0;" json:"messages_count"`
+	CustomerUnread int    `gorm:"column:customer_unread;not null;default:0" json:"customer_unread"`
+	SellerUnread   int    `gorm:"column:seller_unread;not null;default:0" json:"seller_unread"`
+	Unread         int    `gorm:"-" json:"unread"`
+	IsViewed       bool   `gorm:"column:is_viewed;default:false;" json:"is_viewed"`
+	IsDeleted      bool   `gorm:"column:is_deleted;default:false;" json:"is_deleted"`
+	DateAdded      string `gorm:"column:date_created;not null" json:"date_added"`
+}
+
+// conversations from before they were tied to a product have no product id
+// and are left out of the unique index
+const conversationIndexSQL = `CREATE UNIQUE INDEX IF NOT EXISTS idx_conversations_buyer_seller_product
+	ON conversations (customer_id, seller_id, product_id) WHERE product_id <> ''`
+
+// unread counts worked out from the chats for when the counter columns are
+// first added
+const unreadBackfillSQL = `UPDATE conversations SET
+	customer_unread = (SELECT count(*) FROM chats WHERE chats.conversation_id = conversations.conversation_id
+		AND chats.seller_id = conversations.customer_id AND NOT chats.is_viewed AND chats.deleted_at IS NULL),
+	seller_unread = (SELECT count(*) FROM chats WHERE chats.conversation_id = conversations.conversation_id
+		AND chats.seller_id = conversations.seller_id AND NOT chats.is_viewed AND chats.deleted_at IS NULL)`
+
+const userUnreadBackfillSQL = `UPDATE users SET chats =
+	(SELECT coalesce(sum(customer_unread), 0) FROM conversations WHERE customer_id = users.user_id AND NOT is_deleted) +
+	(SELECT coalesce(sum(seller_unread), 0) FROM conversations WHERE seller_id = users.user_id AND NOT is_deleted)`
+
+func MigrateConversations() error {
+	migrator := database.Database.Migrator()
+	if !migrator.HasColumn(&Conversation{}, "ProductId") {
+		if err := migrator.AddColumn(&Conversation{}, "ProductId"); err != nil {
+			return err
+		}
+	}
+	if !migrator.HasColumn(&Conversation{}, "CustomerUnread") {
+		err := database.Database.Transaction(func(tx *gorm.DB) error {
+			for _, field := range []string{"CustomerUnread", "SellerUnread"} {
+				if err := tx.Migrator().AddColumn(&Conversation{}, field); err != nil {
+					return err
+				}
+			}
+			if err := tx.Exec(unreadBackfillSQL).Error; err != nil {
+				return err
+			}
+			return tx.Exec(userUnreadBackfillSQL).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return database.Database.Exec(conversationIndexSQL).Error
+}
+
+func (conversation *Conversation) Save() (*Conversation, error) {
+	err := database.Database.Create(&conversation).Error
+	if err != nil {
+		return &Conversation{}, err
+	}
+	return conversation, nil
+}
+
+func FindConversation(db *gorm.DB, conversationId string) (Conversation, error) {
+	var conversation Conversation
+	err := db.Where("conversation_id=? AND is_deleted=?", conversationId, false).Find(&conversation).Error
+	if err != nil {
+		return Conversation{}, err
+	} else if conversation.ConversationId == "" {
+		return Conversation{}, errors.New("conversation not found")
+	}
+	return conversation, nil
+}
+
+// number of messages the user has not read in the conversation
+func (conversation Conversation) UnreadFor(userId string) int {
+	if conversation.CustomerId == userId {
+		return conversation.CustomerUnread
+	} else if conversation.SellerId == userId {
+		return conversation.SellerUnread
+	}
+	return 0
+}
+
+func (conversation Conversation) HasParticipant(userId string) bool {
+	return userId != "" && (conversation.CustomerId == userId || conversation.SellerId == userId)
+}
+
+// the participant who is not the user
+func (conversation Conversation) OtherParticipant(userId string) string {
+	if conversation.CustomerId == userId {
+		return conversation.SellerId
+	}
+	return conversation.CustomerId
+}
+
+// find a conversation the user takes part in, other users get the same error
+// as for a missing conversation so ids cannot be probed
+func FindUserConversation(db *gorm.DB, conversationId string, userId string) (Conversation, error) {
+	conversation, err := FindConversation(db, conversationId)
+	if err != nil {
+		return Conversation{}, err
+	} else if !conversation.HasParticipant(userId) {
+		return Conversation{}, errors.New("conversation not found")
+	}
+	return conversation, nil
+}
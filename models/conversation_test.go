@@ -0,0 +1,35 @@
+package models
+
+import "testing"
+
+func TestConversationParticipants(t *testing.T) {
+	conversation := Conversation{CustomerId: "buyer", SellerId: "seller", CustomerUnread: 2, SellerUnread: 5}
+
+	type testCase struct {
+		name   string
+		userId string
+		member bool
+		other  string
+		unread int
+	}
+	cases := []testCase{
+		{"buyer", "buyer", true, "seller", 2},
+		{"seller", "seller", true, "buyer", 5},
+		{"stranger", "someone", false, "", 0},
+		{"empty user", "", false, "", 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := conversation.HasParticipant(c.userId); got != c.member {
+				t.Errorf("expected membership %v got %v", c.member, got)
+			}
+			if c.member && conversation.OtherParticipant(c.userId) != c.other {
+				t.Errorf("expected %s got %s", c.other, conversation.OtherParticipant(c.userId))
+			}
+			if got := conversation.UnreadFor(c.userId); got != c.unread {
+				t.Errorf("expected %d unread got %d", c.unread, got)
+			}
+		})
+	}
+}
@@ -7,11 +7,35 @@ import (
 	"gorm.io/gorm"
 )
 
+// an image of a product. ImageUrl is the full size rendition, listings
+// should use the card or thumbnail renditions. Uploads have no product id
+// until a product claims them, gallery images are shown by position
 type ProductImage struct {
 	gorm.Model
-	ImageID   string `gorm:"primary_key;not null;unique"`
-	ProductID string `gorm:"not null" json:"productid"`
-	ImageUrl  string `gorm:"type:text;size:65535;not null;" json:"imageurl"`
+	ImageID      string `gorm:"primary_key;not null;unique"`
+	ProductID    string `gorm:"not null" json:"productid"`
+	OwnerID      string `gorm:"column:owner_id;index" json:"-"`
+	IsMain       bool   `gorm:"column:is_main;not null;default:false" json:"ismain"`
+	Position     int    `gorm:"column:position;not null;default:0" json:"position"`
+	ImageUrl     string `gorm:"type:text;size:65535;not null;" json:"imageurl"`
+	ThumbnailUrl string `gorm:"column:thumbnail_url;type:text" json:"thumbnailurl"`
+	CardUrl      string `gorm:"column:card_url;type:text" json:"cardurl"`
+	Width        int    `gorm:"column:width;default:0" json:"width"`
+	Height       int    `gorm:"column:height;default:0" json:"height"`
+	ObjectKey    string `gorm:"column:object_key" json:"-"`
+}
+
+func MigrateProductImages() error {
+	migrator := database.Database.Migrator()
+	for _, field := range []string{"ThumbnailUrl", "CardUrl", "Width", "Height", "ObjectKey", "OwnerID", "IsMain", "Position"} {
+		if migrator.HasColumn(&ProductImage{}, field) {
+			continue
+		}
+		if err := migrator.AddColumn(&ProductImage{}, field); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (image *ProductImage) Save() (*ProductImage, error) {
@@ -1,17 +1,116 @@
 package models
 
-import "eleliafrika.com/backend/database"
+import (
+	"strings"
+	"time"
 
+	"eleliafrika.com/backend/database"
+	"gorm.io/gorm"
+)
+
+// where a main ad is in its schedule. The scheduler moves scheduled ads to
+// live once they start and live ads to expired once they end. Ads whose
+// creative was changed by the advertiser wait in review until an admin
+// activates them again
+const (
+	MainAdInactive  = "inactive"
+	MainAdInReview  = "review"
+	MainAdScheduled = "scheduled"
+	MainAdLive      = "live"
+	MainAdExpired   = "expired"
+)
+
+// AdActive is kept for the clients that read it and is true while the ad is
+// live. An ad without an ending date runs until it is deactivated. Clicks
+// are sent on to the ad link and what was paid for each day weighs the ad in
+// rotation
 type MainAd struct {
-	Advertid    string `gorm:"column:ad_id;primary key;unique;not null" json:"advertid"`
-	AdBy        string `gorm:"column:ad_by;not null;type:text" json:"adby"`
-	AdName      string `gorm:"column:ad_name;not null;type:text" json:"adname"`
-	AdImage     string `gorm:"column:ad_image;not null;type:text" json:"adimage"`
-	AdActive    bool   `gorm:"column:is_active;" json:"isactive"`
-	IsDeleted   bool   `gorm:"column:is_deleted;default:false;" json:"isdeleted"`
-	DateCreated string `gorm:"column:created_on;not null" json:"datecreated"`
-	EndingDate  string `gorm:"column:ending_date;not null;" json:"endingdate"`
-	AdCategory  string `gorm:"column:ad_category;type:text;not null" json:"adcategory"`
+	Advertid    string     `gorm:"column:ad_id;primary key;unique;not null" json:"advertid"`
+	AdBy        string     `gorm:"column:ad_by;not null;type:text" json:"adby"`
+	AdName      string     `gorm:"column:ad_name;not null;type:text" json:"adname"`
+	AdImage     string     `gorm:"column:ad_image;not null;type:text" json:"adimage"`
+	AdImageKey  string     `gorm:"column:ad_image_key;not null;default:''" json:"-"`
+	AdActive    bool       `gorm:"column:is_active;" json:"isactive"`
+	IsDeleted   bool       `gorm:"column:is_deleted;default:false;" json:"isdeleted"`
+	Status      string     `gorm:"column:status;not null;default:'inactive';index" json:"status"`
+	DateCreated time.Time  `gorm:"column:created_on;not null;default:CURRENT_TIMESTAMP" json:"datecreated"`
+	StartDate   time.Time  `gorm:"column:start_date;not null;default:CURRENT_TIMESTAMP;index" json:"startdate"`
+	EndingDate  *time.Time `gorm:"column:ending_date;index" json:"endingdate"`
+	AdCategory  string     `gorm:"column:ad_category;type:text;not null" json:"adcategory"`
+	AdLink      string     `gorm:"column:ad_link;type:text;not null;default:''" json:"adlink"`
+	AmountPaid  uint       `gorm:"column:amount_paid;not null;default:0" json:"amountpaid"`
+	DailyRate   uint       `gorm:"column:daily_rate;not null;default:0" json:"dailyrate"`
+}
+
+// the dates used to be stored as text, empty when they were never set
+var mainAdDateColumns = []string{"created_on", "ending_date"}
+
+func MigrateMainAds() error {
+	return database.Database.Transaction(func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+		if !migrator.HasTable(&MainAd{}) {
+			return tx.AutoMigrate(&MainAd{})
+		}
+
+		columnTypes, err := migrator.ColumnTypes(&MainAd{})
+		if err != nil {
+			return err
+		}
+		for _, columnType := range columnTypes {
+			databaseType := strings.ToLower(columnType.DatabaseTypeName())
+			if !contains(mainAdDateColumns, columnType.Name()) || (databaseType != "text" && databaseType != "varchar") {
+				continue
+			}
+			err := tx.Exec(`ALTER TABLE main_ads ALTER COLUMN ` + columnType.Name() + ` DROP NOT NULL,
+				ALTER COLUMN ` + columnType.Name() + ` TYPE timestamptz USING NULLIF(` + columnType.Name() + `, '')::timestamptz`).Error
+			if err != nil {
+				return err
+			}
+		}
+		// ads that were never given a creation date get the time of the
+		// migration
+		if err := tx.Exec(`UPDATE main_ads SET created_on = now() WHERE created_on IS NULL`).Error; err != nil {
+			return err
+		}
+
+		addingStatus := !migrator.HasColumn(&MainAd{}, "Status")
+		addingDailyRate := !migrator.HasColumn(&MainAd{}, "DailyRate")
+		if err := tx.AutoMigrate(&MainAd{}); err != nil {
+			return err
+		}
+		// paid ads spread what they paid over the days they run
+		if addingDailyRate {
+			err := tx.Exec(`UPDATE main_ads SET daily_rate = CASE WHEN ending_date IS NULL THEN amount_paid
+				ELSE amount_paid / GREATEST(1, CEIL(EXTRACT(EPOCH FROM ending_date - start_date) / 86400))::bigint END
+				WHERE amount_paid > 0`).Error
+			if err != nil {
+				return err
+			}
+		}
+		if addingStatus {
+			err := tx.Exec(`UPDATE main_ads SET status = CASE WHEN is_active THEN ? ELSE ? END, start_date = created_on`,
+				MainAdLive, MainAdInactive).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// whether the ad should be showing at the time
+func (mainad MainAd) IsLive(now time.Time) bool {
+	return mainad.Status == MainAdLive && !mainad.IsDeleted && !mainad.StartDate.After(now) &&
+		(mainad.EndingDate == nil || mainad.EndingDate.After(now))
 }
 
 func (mainad *MainAd) Save() (*MainAd, error) {
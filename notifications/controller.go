@@ -0,0 +1,106 @@
+package notifications
+
+import (
+	"net/http"
+	"time"
+
+	"eleliafrika.com/backend/auth"
+	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/pagination"
+	"github.com/gin-gonic/gin"
+)
+
+// id of the signed in user, the routes only let users through
+func currentUserId(context *gin.Context) (string, bool) {
+	claims, err := auth.ValidateToken(context)
+	if err != nil {
+		response := models.Reply{
+			Message: "error authorizing user",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return "", false
+	}
+	return claims.Subject, true
+}
+
+// newest first, only the unread ones with ?unread=true
+func GetNotifications(context *gin.Context) {
+	userId, ok := currentUserId(context)
+	if !ok {
+		return
+	}
+	request, err := pagination.ParseRequest(context, "date", true)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "invalid pagination parameters",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	_, page, err := FetchUserNotificationsPage(userId, context.Query("unread") == "true", request)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "error fetching the notifications",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Data:    page,
+		Message: "notifications fetched",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+func GetUnreadCount(context *gin.Context) {
+	userId, ok := currentUserId(context)
+	if !ok {
+		return
+	}
+	unread, err := CountUnread(userId)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "error counting the notifications",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Data:    gin.H{"unread": unread},
+		Message: "unread notifications counted",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+// mark the notification in ?id as read, or every notification without it
+func ReadNotifications(context *gin.Context) {
+	userId, ok := currentUserId(context)
+	if !ok {
+		return
+	}
+	if err := MarkRead(userId, context.Query("id"), time.Now()); err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "could not mark the notifications as read",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "notifications marked as read",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
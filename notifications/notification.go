@@ -0,0 +1,79 @@
+package notifications
+
+import (
+	"errors"
+	"time"
+
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// kinds of notifications, clients use them to pick an icon and a link
+const (
+	KindProductSuspended   = "product.suspended"
+	KindProductUnsuspended = "product.unsuspended"
+)
+
+// a message for a seller, shown in the app until it is read
+type Notification struct {
+	gorm.Model
+	NotificationID string     `gorm:"column:notification_id;not null;unique" json:"notificationid"`
+	UserID         string     `gorm:"column:user_id;not null;index" json:"userid"`
+	Kind           string     `gorm:"column:kind;not null" json:"kind"`
+	Title          string     `gorm:"column:title;not null" json:"title"`
+	Body           string     `gorm:"column:body;not null;default:''" json:"body"`
+	ItemID         string     `gorm:"column:item_id;not null;default:''" json:"itemid"`
+	ReadAt         *time.Time `gorm:"column:read_at" json:"readat"`
+}
+
+func MigrateNotifications() error {
+	return database.Database.AutoMigrate(&Notification{})
+}
+
+// save a notification inside the transaction of the change it is about, so
+// it is only sent when the change is saved
+func Notify(tx *gorm.DB, notification Notification) error {
+	if notification.UserID == "" {
+		return errors.New("a notification needs a user")
+	}
+	notification.NotificationID = uuid.New().String()
+	return tx.Create(&notification).Error
+}
+
+var notificationSorts = map[string]pagination.Sort[Notification]{
+	"date": {
+		Column: "created_at",
+		Value:  func(notification Notification) interface{} { return notification.CreatedAt },
+	},
+}
+
+func userNotificationsQuery(userId string, unreadOnly bool) *gorm.DB {
+	query := database.Database.Model(&Notification{}).Where("user_id=?", userId)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+	return query
+}
+
+func FetchUserNotificationsPage(userId string, unreadOnly bool, request pagination.Request) ([]Notification, pagination.Page, error) {
+	return pagination.Fetch(userNotificationsQuery(userId, unreadOnly), request, notificationSorts,
+		func(notification Notification) uint { return notification.ID })
+}
+
+func CountUnread(userId string) (int64, error) {
+	var unread int64
+	err := userNotificationsQuery(userId, true).Count(&unread).Error
+	return unread, err
+}
+
+// mark one notification of the user as read, or all of them when the id is
+// empty
+func MarkRead(userId string, notificationId string, now time.Time) error {
+	query := userNotificationsQuery(userId, true)
+	if notificationId != "" {
+		query = query.Where("notification_id=?", notificationId)
+	}
+	return query.Update("read_at", now).Error
+}
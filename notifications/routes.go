@@ -0,0 +1,15 @@
+package notifications
+
+import (
+	"eleliafrika.com/backend/auth"
+	"github.com/gin-gonic/gin"
+)
+
+func NotificationsRoutes(router *gin.Engine) {
+	notificationsRoutes := router.Group("/notifications")
+	{
+		notificationsRoutes.GET("", auth.RequireUser(), GetNotifications)
+		notificationsRoutes.GET("/unread", auth.RequireUser(), GetUnreadCount)
+		notificationsRoutes.POST("/read", auth.RequireUser(), ReadNotifications)
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"strings"
 	"time"
 
+	"eleliafrika.com/backend/database"
 	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/users"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -46,15 +48,25 @@ func CreatePackage(context *gin.Context) {
 		context.JSON(http.StatusOK, response)
 		return
 	} else {
+		if err := ValidateEntitlements(packageInput.Entitlements); err != nil {
+			response := models.Reply{
+				Message: err.Error(),
+				Success: false,
+				Error:   err.Error(),
+			}
+			context.JSON(http.StatusBadRequest, response)
+			return
+		}
 		if packageInput.Duration != 0 && packageInput.PackageName != "" {
 			newPackage := PackageModel{
-				PackageId:   packageuuid.String(),
-				PackageName: packageInput.PackageName,
-				UsersNumber: 0,
-				Price:       packageInput.Price,
-				Duration:    packageInput.Duration,
-				DateCreated: currentTime,
-				DateUpdated: currentTime,
+				PackageId:    packageuuid.String(),
+				PackageName:  packageInput.PackageName,
+				UsersNumber:  0,
+				Price:        packageInput.Price,
+				Duration:     packageInput.Duration,
+				DateCreated:  currentTime,
+				DateUpdated:  currentTime,
+				Entitlements: packageInput.Entitlements,
 			}
 			savedPackage, err := newPackage.Save()
 			if err != nil {
@@ -87,7 +99,7 @@ func CreatePackage(context *gin.Context) {
 
 func UpdatePackage(context *gin.Context) {
 
-	var packageInput PackageModel
+	var packageInput EditPackage
 
 	if err := context.ShouldBindJSON(&packageInput); err != nil {
 		response := models.Reply{
@@ -142,12 +154,27 @@ func UpdatePackage(context *gin.Context) {
 
 		newPackage := PackageModel{
 			PackageName: packageInput.PackageName,
-			UsersNumber: packageInput.UsersNumber,
 			Price:       packageInput.Price,
 			Duration:    packageInput.Duration,
 			DateUpdated: currentTime,
 		}
-		packageUpdate, err := UpdatePackageUtil(id, newPackage)
+		if err := ValidateEntitlementsUpdate(packageInput.EntitlementsUpdate); err != nil {
+			response := models.Reply{
+				Message: err.Error(),
+				Error:   err.Error(),
+				Success: false,
+			}
+			context.JSON(http.StatusBadRequest, response)
+			return
+		}
+		_, err = UpdatePackageUtil(id, newPackage)
+		if err == nil {
+			err = UpdateEntitlementsUtil(id, packageInput.EntitlementsUpdate)
+		}
+		var packageUpdate PackageModel
+		if err == nil {
+			packageUpdate, err = QuerySinglePackageUtil(id)
+		}
 
 		if err != nil {
 			response := models.Reply{
@@ -234,3 +261,117 @@ func FetchAllPackages(context *gin.Context) {
 		return
 	}
 }
+
+func replySubscription(context *gin.Context, subscription Subscription, err error, message string) {
+	if err != nil {
+		response := models.Reply{
+			Message: "could not update the subscription",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: message,
+		Data:    subscription,
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+func SubscribeToPackage(context *gin.Context) {
+	user, ok := users.CurrentUserOrReply(context)
+	if !ok {
+		return
+	}
+	subscription, err := Subscribe(user.UserID, strings.ReplaceAll(context.Query("id"), "'", ""))
+	replySubscription(context, subscription, err, "subscribed to the package")
+}
+
+func RenewSubscription(context *gin.Context) {
+	user, ok := users.CurrentUserOrReply(context)
+	if !ok {
+		return
+	}
+	subscription, err := Renew(user.UserID)
+	replySubscription(context, subscription, err, "subscription renewed")
+}
+
+func UpgradeSubscription(context *gin.Context) {
+	user, ok := users.CurrentUserOrReply(context)
+	if !ok {
+		return
+	}
+	subscription, err := Upgrade(user.UserID, strings.ReplaceAll(context.Query("id"), "'", ""))
+	replySubscription(context, subscription, err, "subscription changed")
+}
+
+func CancelSubscription(context *gin.Context) {
+	user, ok := users.CurrentUserOrReply(context)
+	if !ok {
+		return
+	}
+	if err := Cancel(user.UserID); err != nil {
+		response := models.Reply{
+			Message: "could not cancel the subscription",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "subscription cancelled",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+// the user's subscriptions, newest first
+func GetSubscriptions(context *gin.Context) {
+	user, ok := users.CurrentUserOrReply(context)
+	if !ok {
+		return
+	}
+	subscriptions, err := FetchUserSubscriptions(user.UserID)
+	if err != nil {
+		response := models.Reply{
+			Message: "could not fetch subscriptions",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "subscriptions fetched",
+		Data:    subscriptions,
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+// what the user's current package allows
+func GetEntitlements(context *gin.Context) {
+	user, ok := users.CurrentUserOrReply(context)
+	if !ok {
+		return
+	}
+	entitlements, packageName, err := UserEntitlements(database.Database, user.UserID)
+	if err != nil {
+		response := models.Reply{
+			Message: "could not fetch the package limits",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "package limits fetched",
+		Data:    gin.H{"package": packageName, "entitlements": entitlements},
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,174 @@
+package packages
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"eleliafrika.com/backend/database"
+	"gorm.io/gorm"
+)
+
+// what a package lets a seller do. Zero max active ads, images per ad or
+// ad duration means no limit, zero featured slots or bumps means none.
+// Featured slots are ads featured at once, free bumps are per month
+type Entitlements struct {
+	MaxActiveAds   int `gorm:"column:max_active_ads;not null;default:0" json:"maxactiveads"`
+	MaxImagesPerAd int `gorm:"column:max_images_per_ad;not null;default:0" json:"maximagesperad"`
+	AdDurationDays int `gorm:"column:ad_duration_days;not null;default:0" json:"addurationdays"`
+	FeaturedSlots  int `gorm:"column:featured_slots;not null;default:0" json:"featuredslots"`
+	FreeBumps      int `gorm:"column:free_bumps;not null;default:0" json:"freebumps"`
+}
+
+// limits sent when editing a package, the ones left out are kept
+type EntitlementsUpdate struct {
+	MaxActiveAds   *int `json:"maxactiveads"`
+	MaxImagesPerAd *int `json:"maximagesperad"`
+	AdDurationDays *int `json:"addurationdays"`
+	FeaturedSlots  *int `json:"featuredslots"`
+	FreeBumps      *int `json:"freebumps"`
+}
+
+// sellers without a subscription
+var BasicEntitlements = Entitlements{
+	MaxActiveAds:   3,
+	MaxImagesPerAd: 5,
+	AdDurationDays: 30,
+}
+
+var entitlementFields = []string{"MaxActiveAds", "MaxImagesPerAd", "AdDurationDays", "FeaturedSlots", "FreeBumps"}
+
+func MigrateEntitlements() error {
+	migrator := database.Database.Migrator()
+	for _, field := range entitlementFields {
+		if !migrator.HasColumn(&PackageModel{}, field) {
+			if err := migrator.AddColumn(&PackageModel{}, field); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func ValidateEntitlements(entitlements Entitlements) error {
+	values := []int{entitlements.MaxActiveAds, entitlements.MaxImagesPerAd, entitlements.AdDurationDays, entitlements.FeaturedSlots, entitlements.FreeBumps}
+	for _, value := range values {
+		if value < 0 {
+			return errors.New("package limits cannot be negative")
+		}
+	}
+	return nil
+}
+
+// the columns to write for the limits that were sent. A map so that a
+// limit can be set back to zero
+func (update EntitlementsUpdate) changes() (map[string]interface{}, error) {
+	changes := map[string]interface{}{}
+	fields := []struct {
+		column string
+		value  *int
+	}{
+		{"max_active_ads", update.MaxActiveAds},
+		{"max_images_per_ad", update.MaxImagesPerAd},
+		{"ad_duration_days", update.AdDurationDays},
+		{"featured_slots", update.FeaturedSlots},
+		{"free_bumps", update.FreeBumps},
+	}
+	for _, field := range fields {
+		if field.value == nil {
+			continue
+		} else if *field.value < 0 {
+			return nil, errors.New("package limits cannot be negative")
+		}
+		changes[field.column] = *field.value
+	}
+	return changes, nil
+}
+
+func ValidateEntitlementsUpdate(update EntitlementsUpdate) error {
+	_, err := update.changes()
+	return err
+}
+
+func UpdateEntitlementsUtil(id string, update EntitlementsUpdate) error {
+	changes, err := update.changes()
+	if err != nil {
+		return err
+	} else if len(changes) == 0 {
+		return nil
+	}
+	return database.Database.Model(&PackageModel{}).Where("package_id=?", id).Updates(changes).Error
+}
+
+// returned when a seller has used up what their package allows
+type QuotaError struct {
+	Package string
+	Quota   string
+	Limit   int
+}
+
+func (err *QuotaError) Error() string {
+	return fmt.Sprintf("the %s package allows %d %s, upgrade your package for more", err.Package, err.Limit, err.Quota)
+}
+
+func IsQuotaError(err error) bool {
+	var quota *QuotaError
+	return errors.As(err, &quota)
+}
+
+// entitlements of the user's active subscription, or the basic ones
+func UserEntitlements(tx *gorm.DB, userId string) (Entitlements, string, error) {
+	var subscription Subscription
+	err := tx.Where("user_id=? AND status=?", userId, SubscriptionActive).Find(&subscription).Error
+	if err != nil {
+		return Entitlements{}, "", err
+	} else if subscription.SubscriptionID == "" {
+		return BasicEntitlements, BasicPackage, nil
+	}
+
+	var packageModel PackageModel
+	err = tx.Where("package_id=?", subscription.PackageId).Find(&packageModel).Error
+	if err != nil {
+		return Entitlements{}, "", err
+	} else if packageModel.PackageId == "" {
+		return BasicEntitlements, BasicPackage, nil
+	}
+	return packageModel.Entitlements, packageModel.PackageName, nil
+}
+
+func (entitlements Entitlements) CheckActiveAds(packageName string, active int64) error {
+	if entitlements.MaxActiveAds > 0 && active >= int64(entitlements.MaxActiveAds) {
+		return &QuotaError{Package: packageName, Quota: "active ads", Limit: entitlements.MaxActiveAds}
+	}
+	return nil
+}
+
+func (entitlements Entitlements) CheckImages(packageName string, images int) error {
+	if entitlements.MaxImagesPerAd > 0 && images > entitlements.MaxImagesPerAd {
+		return &QuotaError{Package: packageName, Quota: "images per ad", Limit: entitlements.MaxImagesPerAd}
+	}
+	return nil
+}
+
+func (entitlements Entitlements) CheckFeatured(packageName string, featured int64) error {
+	if featured >= int64(entitlements.FeaturedSlots) {
+		return &QuotaError{Package: packageName, Quota: "featured ads", Limit: entitlements.FeaturedSlots}
+	}
+	return nil
+}
+
+// used is the number of bumps made this month
+func (entitlements Entitlements) CheckBumps(packageName string, used int64) error {
+	if used >= int64(entitlements.FreeBumps) {
+		return &QuotaError{Package: packageName, Quota: "bumps a month", Limit: entitlements.FreeBumps}
+	}
+	return nil
+}
+
+// when an ad activated now stops showing, empty when it does not expire
+func (entitlements Entitlements) AdExpiry(now time.Time) string {
+	if entitlements.AdDurationDays <= 0 {
+		return ""
+	}
+	return now.AddDate(0, 0, entitlements.AdDurationDays).Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,98 @@
+package packages
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEntitlementQuotas(t *testing.T) {
+	limited := Entitlements{MaxActiveAds: 2, MaxImagesPerAd: 3, AdDurationDays: 7}
+	unlimited := Entitlements{}
+
+	if err := limited.CheckActiveAds("basic", 1); err != nil {
+		t.Errorf("expected room for another ad got %v", err)
+	}
+	if err := limited.CheckActiveAds("basic", 2); !IsQuotaError(err) {
+		t.Errorf("expected a quota error got %v", err)
+	}
+	if err := limited.CheckImages("basic", 3); err != nil {
+		t.Errorf("expected three images to be allowed got %v", err)
+	}
+	if err := limited.CheckImages("basic", 4); !IsQuotaError(err) {
+		t.Errorf("expected a quota error got %v", err)
+	}
+	if err := unlimited.CheckFeatured("basic", 0); !IsQuotaError(err) {
+		t.Errorf("expected no featured slots got %v", err)
+	}
+	if err := unlimited.CheckBumps("basic", 0); !IsQuotaError(err) {
+		t.Errorf("expected no free bumps got %v", err)
+	}
+	promoted := Entitlements{FeaturedSlots: 2, FreeBumps: 5}
+	if err := promoted.CheckFeatured("gold", 1); err != nil {
+		t.Errorf("expected a free featured slot got %v", err)
+	}
+	if err := promoted.CheckFeatured("gold", 2); !IsQuotaError(err) {
+		t.Errorf("expected a quota error got %v", err)
+	}
+	if err := promoted.CheckBumps("gold", 4); err != nil {
+		t.Errorf("expected a bump left got %v", err)
+	}
+	if err := promoted.CheckBumps("gold", 5); !IsQuotaError(err) {
+		t.Errorf("expected a quota error got %v", err)
+	}
+	if err := unlimited.CheckActiveAds("gold", 1000); err != nil {
+		t.Errorf("expected no limit got %v", err)
+	}
+	if err := unlimited.CheckImages("gold", 1000); err != nil {
+		t.Errorf("expected no limit got %v", err)
+	}
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if got := limited.AdExpiry(now); got != "2024-03-08 12:00:00" {
+		t.Errorf("unexpected expiry %s", got)
+	}
+	if got := unlimited.AdExpiry(now); got != "" {
+		t.Errorf("expected no expiry got %s", got)
+	}
+
+	wrapped := fmt.Errorf("could not add: %w", &QuotaError{Package: "basic", Quota: "active ads", Limit: 2})
+	if !IsQuotaError(wrapped) || IsQuotaError(errors.New("other")) {
+		t.Error("quota errors should be recognised when wrapped and only then")
+	}
+	if err := ValidateEntitlements(Entitlements{FreeBumps: -1}); err == nil {
+		t.Error("expected negative limits to be refused")
+	}
+}
+
+func TestEntitlementsUpdateChanges(t *testing.T) {
+	zero, five, negative := 0, 5, -1
+
+	type testCase struct {
+		name    string
+		update  EntitlementsUpdate
+		want    map[string]interface{}
+		wantErr bool
+	}
+	cases := []testCase{
+		{"nothing sent", EntitlementsUpdate{}, map[string]interface{}{}, false},
+		{"one limit", EntitlementsUpdate{MaxActiveAds: &five}, map[string]interface{}{"max_active_ads": 5}, false},
+		{"promotions", EntitlementsUpdate{FeaturedSlots: &five, FreeBumps: &zero}, map[string]interface{}{"featured_slots": 5, "free_bumps": 0}, false},
+		{"back to no limit", EntitlementsUpdate{AdDurationDays: &zero}, map[string]interface{}{"ad_duration_days": 0}, false},
+		{"negative", EntitlementsUpdate{MaxImagesPerAd: &negative}, nil, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := c.update.changes()
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error=%v got %v", c.wantErr, err)
+			}
+			if err == nil && !reflect.DeepEqual(got, c.want) {
+				t.Errorf("expected %v got %v", c.want, got)
+			}
+		})
+	}
+}
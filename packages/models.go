@@ -7,19 +7,28 @@ import (
 
 type PackageModel struct {
 	gorm.Model
-	PackageId   string `gorm:"column:package_id;not null;" json:"package_id"`
-	PackageName string `gorm:"column:package_name;not null;" json:"package_name"`
-	UsersNumber uint   `gorm:"column:users_number;not null;" json:"users_number"`
-	Price       uint   `gorm:"column:price;not null;" json:"price"`
-	Duration    int    `gorm:"column:duration;not null;" json:"duration"`
-	DateCreated string `gorm:"column:date_created;not null;" json:"date_created"`
-	DateUpdated string `gorm:"column:date_updated;not null;" json:"date_updated"`
+	PackageId    string `gorm:"column:package_id;not null;" json:"package_id"`
+	PackageName  string `gorm:"column:package_name;not null;" json:"package_name"`
+	UsersNumber  uint   `gorm:"column:users_number;not null;" json:"users_number"` // worked out from the active subscriptions
+	Price        uint   `gorm:"column:price;not null;" json:"price"`
+	Duration     int    `gorm:"column:duration;not null;" json:"duration"` // days
+	DateCreated  string `gorm:"column:date_created;not null;" json:"date_created"`
+	DateUpdated  string `gorm:"column:date_updated;not null;" json:"date_updated"`
+	Entitlements `gorm:"embedded"`
 }
 
 type AddPackage struct {
 	PackageName string `gorm:"column:package_name;not null;" json:"package_name"`
 	Price       uint   `gorm:"column:price;not null;" json:"price"`
 	Duration    int    `gorm:"column:duration;not null;" json:"duration"`
+	Entitlements
+}
+
+type EditPackage struct {
+	PackageName string `json:"package_name"`
+	Price       uint   `json:"price"`
+	Duration    int    `json:"duration"`
+	EntitlementsUpdate
 }
 
 func (packagemodel *PackageModel) Save() (*PackageModel, error) {
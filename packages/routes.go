@@ -1,17 +1,22 @@
 package packages
 
 import (
-	"eleliafrika.com/backend/users"
+	"eleliafrika.com/backend/auth"
 	"github.com/gin-gonic/gin"
 )
 
 func PackagesRoutes(router *gin.Engine) {
-	packagesRoutes := router.Group("/packages", users.JWTAuthMiddleWare())
+	packagesRoutes := router.Group("/packages")
 	{
-		packagesRoutes.POST("/create", CreatePackage)
-		packagesRoutes.POST("/update", UpdatePackage)
-		packagesRoutes.GET("/getsinglepackage", FetchSinglePackage)
-		packagesRoutes.GET("/getallpackages", FetchAllPackages)
-
+		packagesRoutes.POST("/create", auth.RequireAdmin(auth.PermissionManagePackages), CreatePackage)
+		packagesRoutes.POST("/update", auth.RequireAdmin(auth.PermissionManagePackages), UpdatePackage)
+		packagesRoutes.GET("/getsinglepackage", auth.JWTAuthMiddleWare(), FetchSinglePackage)
+		packagesRoutes.GET("/getallpackages", auth.JWTAuthMiddleWare(), FetchAllPackages)
+		packagesRoutes.POST("/subscribe", auth.RequireUser(), SubscribeToPackage)
+		packagesRoutes.POST("/renew", auth.RequireUser(), RenewSubscription)
+		packagesRoutes.POST("/upgrade", auth.RequireUser(), UpgradeSubscription)
+		packagesRoutes.POST("/cancel", auth.RequireUser(), CancelSubscription)
+		packagesRoutes.GET("/subscriptions", auth.RequireUser(), GetSubscriptions)
+		packagesRoutes.GET("/entitlements", auth.RequireUser(), GetEntitlements)
 	}
 }
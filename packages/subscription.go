@@ -0,0 +1,320 @@
+package packages
+
+import (
+	"errors"
+	"time"
+
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/users"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	BasicPackage          = "basic"
+	SubscriptionActive    = "active"
+	SubscriptionCancelled = "cancelled"
+	SubscriptionExpired   = "expired"
+	SubscriptionReplaced  = "replaced"
+)
+
+// a user's paid package. A user has at most one active subscription, when
+// it ends they are back on the basic package
+type Subscription struct {
+	gorm.Model
+	SubscriptionID string     `gorm:"column:subscription_id;not null;unique" json:"subscriptionid"`
+	UserID         string     `gorm:"column:user_id;not null;index" json:"userid"`
+	PackageId      string     `gorm:"column:package_id;not null;index" json:"package_id"`
+	PackageName    string     `gorm:"column:package_name;not null" json:"package_name"`
+	Price          uint       `gorm:"column:price;not null" json:"price"`
+	StartsAt       time.Time  `gorm:"column:starts_at;not null" json:"startsat"`
+	ExpiresAt      time.Time  `gorm:"column:expires_at;not null;index" json:"expiresat"`
+	Status         string     `gorm:"column:status;not null;index" json:"status"`
+	CancelledAt    *time.Time `gorm:"column:cancelled_at" json:"cancelledat"`
+}
+
+const activeSubscriptionIndexSQL = `CREATE UNIQUE INDEX IF NOT EXISTS idx_subscriptions_active_user
+	ON subscriptions (user_id) WHERE status = 'active' AND deleted_at IS NULL`
+
+func MigrateSubscriptions() error {
+	if err := database.Database.AutoMigrate(&Subscription{}); err != nil {
+		return err
+	}
+	return database.Database.Exec(activeSubscriptionIndexSQL).Error
+}
+
+// package durations are in days
+func packagePeriod(packageModel PackageModel) time.Duration {
+	return time.Duration(packageModel.Duration) * 24 * time.Hour
+}
+
+// a renewal is added to the end of the current period, or starts now when
+// the period is already over
+func renewedExpiry(expiresAt time.Time, now time.Time, period time.Duration) time.Time {
+	if expiresAt.Before(now) {
+		return now.Add(period)
+	}
+	return expiresAt.Add(period)
+}
+
+func findPackage(tx *gorm.DB, packageId string) (PackageModel, error) {
+	var packageModel PackageModel
+	err := tx.Where("package_id=?", packageId).Find(&packageModel).Error
+	if err != nil {
+		return PackageModel{}, err
+	} else if packageModel.PackageId == "" {
+		return PackageModel{}, errors.New("package does not exist")
+	} else if packageModel.Duration <= 0 {
+		return PackageModel{}, errors.New("package has no duration")
+	}
+	return packageModel, nil
+}
+
+// active subscription of a user, locked when tx is inside a transaction.
+// The subscription id is empty when there is none
+func findActiveSubscription(tx *gorm.DB, userId string) (Subscription, error) {
+	var subscription Subscription
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id=? AND status=?", userId, SubscriptionActive).Find(&subscription).Error
+	return subscription, err
+}
+
+func FetchActiveSubscription(userId string) (Subscription, error) {
+	var subscription Subscription
+	err := database.Database.Where("user_id=? AND status=?", userId, SubscriptionActive).Find(&subscription).Error
+	return subscription, err
+}
+
+func FetchUserSubscriptions(userId string) ([]Subscription, error) {
+	var subscriptions []Subscription
+	err := database.Database.Where("user_id=?", userId).Order("created_at DESC").Find(&subscriptions).Error
+	if err != nil {
+		return []Subscription{}, err
+	}
+	return subscriptions, nil
+}
+
+func setUserPackage(tx *gorm.DB, userId string, packageName string) error {
+	// update column skips the user hooks which would hash the password
+	return tx.Model(&users.User{}).Where("user_id=?", userId).UpdateColumn("package_type", packageName).Error
+}
+
+func startSubscription(tx *gorm.DB, userId string, packageModel PackageModel, now time.Time) (Subscription, error) {
+	subscription := Subscription{
+		SubscriptionID: uuid.New().String(),
+		UserID:         userId,
+		PackageId:      packageModel.PackageId,
+		PackageName:    packageModel.PackageName,
+		Price:          packageModel.Price,
+		StartsAt:       now,
+		ExpiresAt:      now.Add(packagePeriod(packageModel)),
+		Status:         SubscriptionActive,
+	}
+	if err := tx.Create(&subscription).Error; err != nil {
+		return Subscription{}, err
+	}
+	return subscription, setUserPackage(tx, userId, packageModel.PackageName)
+}
+
+func endSubscription(tx *gorm.DB, subscription Subscription, status string, now time.Time) error {
+	updates := map[string]interface{}{"status": status}
+	if status == SubscriptionCancelled {
+		updates["cancelled_at"] = now
+	}
+	return tx.Model(&Subscription{}).Where("subscription_id=?", subscription.SubscriptionID).Updates(updates).Error
+}
+
+func subscribe(tx *gorm.DB, userId string, packageId string, now time.Time) (Subscription, error) {
+	packageModel, err := findPackage(tx, packageId)
+	if err != nil {
+		return Subscription{}, err
+	}
+	current, err := findActiveSubscription(tx, userId)
+	if err != nil {
+		return Subscription{}, err
+	} else if current.SubscriptionID != "" {
+		return Subscription{}, errors.New("user already has an active subscription, renew or upgrade it instead")
+	}
+	return startSubscription(tx, userId, packageModel, now)
+}
+
+func renew(tx *gorm.DB, current Subscription, now time.Time) (Subscription, error) {
+	packageModel, err := findPackage(tx, current.PackageId)
+	if err != nil {
+		return Subscription{}, err
+	}
+	current.ExpiresAt = renewedExpiry(current.ExpiresAt, now, packagePeriod(packageModel))
+	current.Price += packageModel.Price
+	err = tx.Model(&Subscription{}).Where("subscription_id=?", current.SubscriptionID).
+		Updates(map[string]interface{}{"expires_at": current.ExpiresAt, "price": current.Price}).Error
+	if err != nil {
+		return Subscription{}, err
+	}
+	return current, nil
+}
+
+func upgrade(tx *gorm.DB, current Subscription, packageId string, now time.Time) (Subscription, error) {
+	packageModel, err := findPackage(tx, packageId)
+	if err != nil {
+		return Subscription{}, err
+	}
+	if err := endSubscription(tx, current, SubscriptionReplaced, now); err != nil {
+		return Subscription{}, err
+	}
+	return startSubscription(tx, current.UserID, packageModel, now)
+}
+
+// paid packages are only given out once the payment has gone through
+func requireFree(tx *gorm.DB, packageId string) error {
+	packageModel, err := findPackage(tx, packageId)
+	if err != nil {
+		return err
+	} else if packageModel.Price > 0 {
+		return errors.New("this package has to be paid for, start a payment for it instead")
+	}
+	return nil
+}
+
+// subscribe a user on the basic package to a free package
+func Subscribe(userId string, packageId string) (Subscription, error) {
+	var subscription Subscription
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		if err := requireFree(tx, packageId); err != nil {
+			return err
+		}
+		var err error
+		subscription, err = subscribe(tx, userId, packageId, time.Now())
+		return err
+	})
+	return subscription, err
+}
+
+// add another period of the same free package to the active subscription
+func Renew(userId string) (Subscription, error) {
+	var subscription Subscription
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		current, err := findActiveSubscription(tx, userId)
+		if err != nil {
+			return err
+		} else if current.SubscriptionID == "" {
+			return errors.New("user has no active subscription")
+		} else if err := requireFree(tx, current.PackageId); err != nil {
+			return err
+		}
+		subscription, err = renew(tx, current, time.Now())
+		return err
+	})
+	return subscription, err
+}
+
+// move the user to another free package straight away, the current
+// subscription is closed as replaced
+func Upgrade(userId string, packageId string) (Subscription, error) {
+	var subscription Subscription
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		current, err := findActiveSubscription(tx, userId)
+		if err != nil {
+			return err
+		} else if current.SubscriptionID == "" {
+			return errors.New("user has no active subscription, subscribe instead")
+		} else if current.PackageId == packageId {
+			return errors.New("user is already on this package, renew it instead")
+		} else if err := requireFree(tx, packageId); err != nil {
+			return err
+		}
+		subscription, err = upgrade(tx, current, packageId, time.Now())
+		return err
+	})
+	return subscription, err
+}
+
+// give a user a package they have paid for, inside the transaction that
+// records the payment. Paying for the package the user is on renews it,
+// paying for another one upgrades to it
+func ApplyPurchase(tx *gorm.DB, userId string, packageId string) (Subscription, error) {
+	now := time.Now()
+	current, err := findActiveSubscription(tx, userId)
+	if err != nil {
+		return Subscription{}, err
+	} else if current.SubscriptionID == "" {
+		return subscribe(tx, userId, packageId, now)
+	} else if current.PackageId == packageId {
+		return renew(tx, current, now)
+	}
+	return upgrade(tx, current, packageId, now)
+}
+
+// package a user can pay for, with its price
+func FindPurchasablePackage(packageId string) (PackageModel, error) {
+	packageModel, err := findPackage(database.Database, packageId)
+	if err != nil {
+		return PackageModel{}, err
+	} else if packageModel.Price == 0 {
+		return PackageModel{}, errors.New("package is free and does not need a payment")
+	}
+	return packageModel, nil
+}
+
+// end the active subscription now and put the user back on basic
+func Cancel(userId string) error {
+	return database.Database.Transaction(func(tx *gorm.DB) error {
+		current, err := findActiveSubscription(tx, userId)
+		if err != nil {
+			return err
+		} else if current.SubscriptionID == "" {
+			return errors.New("user has no active subscription")
+		}
+		if err := endSubscription(tx, current, SubscriptionCancelled, time.Now()); err != nil {
+			return err
+		}
+		return setUserPackage(tx, userId, BasicPackage)
+	})
+}
+
+// expire subscriptions whose period is over and downgrade their users
+func ExpireSubscriptions() error {
+	var expired []Subscription
+	err := database.Database.Where("status=? AND expires_at <= ?", SubscriptionActive, time.Now()).
+		Limit(100).Find(&expired).Error
+	if err != nil {
+		return err
+	}
+	for _, subscription := range expired {
+		err := database.Database.Transaction(func(tx *gorm.DB) error {
+			// a renewal may have moved the expiry since the subscription was
+			// read
+			result := tx.Model(&Subscription{}).
+				Where("subscription_id=? AND status=? AND expires_at <= ?", subscription.SubscriptionID, SubscriptionActive, time.Now()).
+				Update("status", SubscriptionExpired)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			return setUserPackage(tx, subscription.UserID, BasicPackage)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// number of active subscribers of each package
+func activeSubscriberCounts() (map[string]uint, error) {
+	var rows []struct {
+		PackageId string
+		Total     uint
+	}
+	err := database.Database.Model(&Subscription{}).
+		Select("package_id, count(*) AS total").
+		Where("status=?", SubscriptionActive).
+		Group("package_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]uint{}
+	for _, row := range rows {
+		counts[row.PackageId] = row.Total
+	}
+	return counts, nil
+}
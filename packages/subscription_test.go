@@ -0,0 +1,32 @@
+package packages
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenewedExpiry(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	period := packagePeriod(PackageModel{Duration: 30})
+	if period != 30*24*time.Hour {
+		t.Fatalf("expected 30 days got %v", period)
+	}
+
+	type testCase struct {
+		name      string
+		expiresAt time.Time
+		want      time.Time
+	}
+	cases := []testCase{
+		{"still running", now.Add(5 * 24 * time.Hour), now.Add(35 * 24 * time.Hour)},
+		{"already over", now.Add(-5 * 24 * time.Hour), now.Add(30 * 24 * time.Hour)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := renewedExpiry(c.expiresAt, now, period); !got.Equal(c.want) {
+				t.Errorf("expected %v got %v", c.want, got)
+			}
+		})
+	}
+}
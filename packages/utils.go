@@ -13,6 +13,11 @@ func QuerySinglePackageUtil(id string) (PackageModel, error) {
 		return PackageModel{}, err
 
 	}
+	counts, err := activeSubscriberCounts()
+	if err != nil {
+		return PackageModel{}, err
+	}
+	packageModel.UsersNumber = counts[packageModel.PackageId]
 	return packageModel, nil
 }
 func QueryPackageByName(name string) (PackageModel, error) {
@@ -32,6 +37,14 @@ func Fetchproducts() ([]PackageModel, error) {
 	if err != nil {
 		return []PackageModel{}, err
 	}
+	// the number of users is worked out from the active subscriptions
+	counts, err := activeSubscriberCounts()
+	if err != nil {
+		return []PackageModel{}, err
+	}
+	for i := range packagesList {
+		packagesList[i].UsersNumber = counts[packagesList[i].PackageId]
+	}
 	return packagesList, nil
 }
 func UpdatePackageUtil(id string, update PackageModel) (PackageModel, error) {
@@ -0,0 +1,201 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// a field a list can be sorted on. Column is the sql expression rows are
+// ordered by and Value reads the same value back from a fetched row so it
+// can be stored in a cursor
+type Sort[T any] struct {
+	Column string
+	Vars   []interface{}
+	Value  func(item T) interface{}
+}
+
+type Request struct {
+	Limit  int
+	Sort   string
+	Desc   bool
+	Cursor string
+}
+
+type Page struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"next_cursor"`
+	PrevCursor string      `json:"prev_cursor"`
+	Total      int64       `json:"total"`
+}
+
+// position of a row in a sorted list. Backwards cursors fetch the page
+// before the row instead of the page after it
+type Cursor struct {
+	Sort      string `json:"s"`
+	Desc      bool   `json:"d"`
+	Value     string `json:"v"`
+	ID        uint   `json:"id"`
+	Backwards bool   `json:"b,omitempty"`
+}
+
+// read limit, sort, order and cursor from the query string
+func ParseRequest(context *gin.Context, defaultSort string, defaultDesc bool) (Request, error) {
+	request := Request{
+		Limit:  DefaultLimit,
+		Sort:   defaultSort,
+		Desc:   defaultDesc,
+		Cursor: strings.TrimSpace(context.Query("cursor")),
+	}
+
+	if limit := strings.ReplaceAll(context.Query("limit"), "'", ""); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 {
+			return Request{}, errors.New("limit should be a positive number")
+		} else if value > MaxLimit {
+			value = MaxLimit
+		}
+		request.Limit = value
+	}
+	if sort := strings.ToLower(strings.ReplaceAll(context.Query("sort"), "'", "")); sort != "" {
+		request.Sort = sort
+	}
+	switch strings.ToLower(strings.ReplaceAll(context.Query("order"), "'", "")) {
+	case "":
+	case "asc":
+		request.Desc = false
+	case "desc":
+		request.Desc = true
+	default:
+		return Request{}, errors.New("order should either be asc or desc")
+	}
+	return request, nil
+}
+
+func EncodeCursor(cursor Cursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(raw string) (Cursor, error) {
+	var cursor Cursor
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return Cursor{}, errors.New("invalid cursor")
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return Cursor{}, errors.New("invalid cursor")
+	}
+	return cursor, nil
+}
+
+// fetch a single page of the query. Rows are ordered by the requested sort
+// and then by id so that rows with equal sort values keep a stable order
+func Fetch[T any](query *gorm.DB, request Request, sorts map[string]Sort[T], id func(item T) uint) ([]T, Page, error) {
+	sort, ok := sorts[request.Sort]
+	if !ok {
+		return []T{}, Page{}, fmt.Errorf("cannot sort by %s", request.Sort)
+	}
+
+	var total int64
+	err := query.Session(&gorm.Session{NewDB: true}).Table("(?) AS page_source", query).Count(&total).Error
+	if err != nil {
+		return []T{}, Page{}, err
+	}
+
+	var after Cursor
+	hasCursor := request.Cursor != ""
+	if hasCursor {
+		after, err = DecodeCursor(request.Cursor)
+		if err != nil {
+			return []T{}, Page{}, err
+		} else if after.Sort != request.Sort || after.Desc != request.Desc {
+			return []T{}, Page{}, errors.New("cursor does not match the requested sort")
+		}
+	}
+
+	// walking backwards flips the order, the page is reversed after fetching
+	desc := request.Desc != after.Backwards
+	direction, comparison := "ASC", ">"
+	if desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	pageQuery := query.Session(&gorm.Session{})
+	if hasCursor {
+		vars := append(append([]interface{}{}, sort.Vars...), after.Value, after.ID)
+		pageQuery = pageQuery.Where(fmt.Sprintf("(%s, id) %s (?, ?)", sort.Column, comparison), vars...)
+	}
+	pageQuery = pageQuery.Clauses(clause.OrderBy{Expression: clause.Expr{
+		SQL:                fmt.Sprintf("%s %s, id %s", sort.Column, direction, direction),
+		Vars:               sort.Vars,
+		WithoutParentheses: true,
+	}})
+
+	var items []T
+	err = pageQuery.Limit(request.Limit + 1).Find(&items).Error
+	if err != nil {
+		return []T{}, Page{}, err
+	}
+
+	hasMore := len(items) > request.Limit
+	if hasMore {
+		items = items[:request.Limit]
+	}
+	if after.Backwards {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	page := Page{Items: items, Total: total}
+	if len(items) == 0 {
+		return items, page, nil
+	}
+
+	cursorAt := func(item T, backwards bool) string {
+		return EncodeCursor(Cursor{
+			Sort:      request.Sort,
+			Desc:      request.Desc,
+			Value:     formatValue(sort.Value(item)),
+			ID:        id(item),
+			Backwards: backwards,
+		})
+	}
+	// there are rows after this page when more rows were found walking
+	// forwards or when the page was reached by walking backwards
+	if hasMore || after.Backwards {
+		page.NextCursor = cursorAt(items[len(items)-1], false)
+	}
+	if (hasMore && after.Backwards) || (hasCursor && !after.Backwards) {
+		page.PrevCursor = cursorAt(items[0], true)
+	}
+	return items, page, nil
+}
+
+// cursor values are kept as text and cast by postgres to the column type
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,90 @@
+package pagination
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursors := []Cursor{
+		{Sort: "date", Desc: true, Value: "2023-11-02T10:04:05.123456Z", ID: 12},
+		{Sort: "price", Desc: false, Value: "1500", ID: 1, Backwards: true},
+		{Sort: "name", Desc: false, Value: "tv's & radios", ID: 99},
+	}
+
+	for _, item := range cursors {
+		decoded, err := DecodeCursor(EncodeCursor(item))
+		if err != nil {
+			t.Errorf("test failed: could not decode cursor %v: %v", item, err)
+		} else if decoded != item {
+			t.Errorf("test failed: expected %v but found %v", item, decoded)
+		}
+	}
+
+	for _, raw := range []string{"not a cursor", "bm90IGpzb24"} {
+		if _, err := DecodeCursor(raw); err == nil {
+			t.Errorf("test failed: expected an error for cursor %q", raw)
+		}
+	}
+	t.Logf("test passed")
+}
+
+func TestFormatValue(t *testing.T) {
+	type testCase struct {
+		value interface{}
+		want  string
+	}
+
+	cases := []testCase{
+		{12, "12"},
+		{"samsung", "samsung"},
+		{float32(0.0607927), "0.0607927"},
+		{0.25, "0.25"},
+		{time.Date(2023, 11, 2, 13, 4, 5, 123456000, time.FixedZone("EAT", 3*60*60)), "2023-11-02T10:04:05.123456Z"},
+	}
+
+	for _, item := range cases {
+		if result := formatValue(item.value); result != item.want {
+			t.Errorf("test failed: expected %q but found %q", item.want, result)
+		}
+	}
+	t.Logf("test passed")
+}
+
+func TestParseRequest(t *testing.T) {
+	type testCase struct {
+		name    string
+		query   string
+		want    Request
+		wantErr bool
+	}
+
+	cases := []testCase{
+		{"defaults", "", Request{Limit: DefaultLimit, Sort: "date", Desc: true}, false},
+		{"custom sort and order", "?sort=Price&order=asc&limit=5", Request{Limit: 5, Sort: "price", Desc: false}, false},
+		{"limit is capped", "?limit=1000", Request{Limit: MaxLimit, Sort: "date", Desc: true}, false},
+		{"cursor is passed through", "?cursor=abc", Request{Limit: DefaultLimit, Sort: "date", Desc: true, Cursor: "abc"}, false},
+		{"zero limit", "?limit=0", Request{}, true},
+		{"text limit", "?limit=ten", Request{}, true},
+		{"unknown order", "?order=up", Request{}, true},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, item := range cases {
+		context, _ := gin.CreateTestContext(httptest.NewRecorder())
+		context.Request = httptest.NewRequest("GET", "/"+item.query, nil)
+
+		result, err := ParseRequest(context, "date", true)
+		if item.wantErr && err == nil {
+			t.Errorf("%s: expected an error", item.name)
+		} else if !item.wantErr && err != nil {
+			t.Errorf("%s: unexpected error %v", item.name, err)
+		} else if result != item.want {
+			t.Errorf("%s: expected %v but found %v", item.name, item.want, result)
+		}
+	}
+	t.Logf("test passed")
+}
@@ -0,0 +1,172 @@
+package payments
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/users"
+	"github.com/gin-gonic/gin"
+)
+
+// callbacks are small, anything bigger is not from daraja
+const maxCallbackBytes = 64 << 10
+
+func StkPush(context *gin.Context) {
+	var input PaymentInput
+	if err := context.ShouldBindJSON(&input); err != nil {
+		response := models.Reply{
+			Message: "could not bind the payment",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	user, ok := users.CurrentUserOrReply(context)
+	if !ok {
+		return
+	}
+
+	transaction, err := StartPayment(user.UserID, input)
+	if err != nil {
+		response := models.Reply{
+			Message: "could not start the payment",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "check your phone to complete the payment",
+		Data:    transaction,
+		Success: true,
+	}
+	context.JSON(http.StatusAccepted, response)
+}
+
+// daraja only looks at the result code of the reply, failures are logged
+// on our side
+func MpesaCallback(context *gin.Context) {
+	if err := VerifyCallbackToken(context.Param("token")); err != nil {
+		context.JSON(http.StatusUnauthorized, gin.H{"ResultCode": 1, "ResultDesc": "Rejected"})
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(context.Request.Body, maxCallbackBytes))
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"ResultCode": 1, "ResultDesc": "Rejected"})
+		return
+	}
+	if _, err := HandleCallback(body); err != nil {
+		context.Error(err)
+		context.JSON(http.StatusBadRequest, gin.H{"ResultCode": 1, "ResultDesc": "Rejected"})
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"ResultCode": 0, "ResultDesc": "Accepted"})
+}
+
+func GetPaymentStatus(context *gin.Context) {
+	user, ok := users.CurrentUserOrReply(context)
+	if !ok {
+		return
+	}
+	transaction, err := FindUserTransaction(user.UserID, strings.ReplaceAll(context.Query("id"), "'", ""))
+	if err != nil {
+		response := models.Reply{
+			Message: "could not find the payment",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusNotFound, response)
+		return
+	}
+	response := models.Reply{
+		Message: "payment is " + transaction.Status,
+		Data:    transaction,
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+func GetTransactions(context *gin.Context) {
+	user, ok := users.CurrentUserOrReply(context)
+	if !ok {
+		return
+	}
+	transactions, err := FetchUserTransactions(user.UserID)
+	if err != nil {
+		response := models.Reply{
+			Message: "could not fetch the payments",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "payments fetched",
+		Data:    transactions,
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+// answer a pending payment the way the customer would on their phone, only
+// while the fake provider is in use. The result query is the daraja result
+// code, 0 to pay and 1032 to cancel
+func CompleteSandboxPayment(context *gin.Context) {
+	paymentProvider, err := GetProvider()
+	if err != nil || paymentProvider.Name() != ProviderFake {
+		response := models.Reply{
+			Message: "the sandbox is only available with the fake payments provider",
+			Success: false,
+		}
+		context.JSON(http.StatusNotFound, response)
+		return
+	}
+	user, ok := users.CurrentUserOrReply(context)
+	if !ok {
+		return
+	}
+	transaction, err := FindUserTransaction(user.UserID, strings.ReplaceAll(context.Query("id"), "'", ""))
+	if err != nil {
+		response := models.Reply{
+			Message: "could not find the payment",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusNotFound, response)
+		return
+	}
+	resultCode, err := strconv.Atoi(context.DefaultQuery("result", "0"))
+	if err != nil {
+		response := models.Reply{
+			Message: "result should be a number",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	body := FakeCallback(transaction.MerchantRequestID, transaction.CheckoutRequestID, resultCode, transaction.Amount, transaction.Phone)
+	completed, err := HandleCallback(body)
+	if err != nil {
+		response := models.Reply{
+			Message: "could not complete the payment",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "payment is " + completed.Status,
+		Data:    completed,
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
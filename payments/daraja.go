@@ -0,0 +1,267 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	darajaSandboxURL    = "https://sandbox.safaricom.co.ke"
+	darajaProductionURL = "https://api.safaricom.co.ke"
+	darajaTimeout       = 30 * time.Second
+	// daraja timestamps are in Nairobi time
+	darajaTimestampFormat = "20060102150405"
+)
+
+var nairobi = time.FixedZone("EAT", 3*60*60)
+
+// M-Pesa express (STK push) through the Safaricom Daraja API
+type DarajaProvider struct {
+	baseURL         string
+	consumerKey     string
+	consumerSecret  string
+	shortCode       string
+	passKey         string
+	transactionType string
+	client          *http.Client
+
+	tokenMutex     sync.Mutex
+	token          string
+	tokenExpiresAt time.Time
+}
+
+// configured with MPESA_ENV (sandbox or production), MPESA_CONSUMER_KEY,
+// MPESA_CONSUMER_SECRET, MPESA_SHORTCODE, MPESA_PASSKEY and
+// MPESA_TRANSACTION_TYPE, CustomerBuyGoodsOnline for till numbers
+func NewDarajaProvider() (*DarajaProvider, error) {
+	baseURL := darajaSandboxURL
+	switch os.Getenv("MPESA_ENV") {
+	case "", "sandbox":
+	case "production":
+		baseURL = darajaProductionURL
+	default:
+		return nil, errors.New("MPESA_ENV should be sandbox or production")
+	}
+
+	provider := &DarajaProvider{
+		baseURL:         baseURL,
+		consumerKey:     os.Getenv("MPESA_CONSUMER_KEY"),
+		consumerSecret:  os.Getenv("MPESA_CONSUMER_SECRET"),
+		shortCode:       os.Getenv("MPESA_SHORTCODE"),
+		passKey:         os.Getenv("MPESA_PASSKEY"),
+		transactionType: os.Getenv("MPESA_TRANSACTION_TYPE"),
+		client:          &http.Client{Timeout: darajaTimeout},
+	}
+	if provider.transactionType == "" {
+		provider.transactionType = "CustomerPayBillOnline"
+	}
+	if provider.consumerKey == "" || provider.consumerSecret == "" || provider.shortCode == "" || provider.passKey == "" {
+		return nil, errors.New("MPESA_CONSUMER_KEY, MPESA_CONSUMER_SECRET, MPESA_SHORTCODE and MPESA_PASSKEY have to be set")
+	}
+	return provider, nil
+}
+
+func (provider *DarajaProvider) Name() string {
+	return ProviderDaraja
+}
+
+// the password of an STK push is the short code, pass key and timestamp
+// base64 encoded
+func darajaPassword(shortCode string, passKey string, timestamp string) string {
+	return base64.StdEncoding.EncodeToString([]byte(shortCode + passKey + timestamp))
+}
+
+// daraja refuses references longer than 12 characters and descriptions
+// longer than 13
+func truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) > length {
+		return string(runes[:length])
+	}
+	return text
+}
+
+// daraja error replies carry an error message instead of a response code
+type darajaError struct {
+	RequestID    string `json:"requestId"`
+	ErrorCode    string `json:"errorCode"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+// oauth access token, reused until shortly before it expires
+func (provider *DarajaProvider) accessToken(ctx context.Context) (string, error) {
+	provider.tokenMutex.Lock()
+	defer provider.tokenMutex.Unlock()
+	if provider.token != "" && time.Now().Before(provider.tokenExpiresAt) {
+		return provider.token, nil
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, provider.baseURL+"/oauth/v1/generate?grant_type=client_credentials", nil)
+	if err != nil {
+		return "", err
+	}
+	request.SetBasicAuth(provider.consumerKey, provider.consumerSecret)
+	response, err := provider.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("daraja refused the credentials with status %d", response.StatusCode)
+	}
+
+	var reply struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   string `json:"expires_in"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&reply); err != nil {
+		return "", err
+	} else if reply.AccessToken == "" {
+		return "", errors.New("daraja did not return an access token")
+	}
+	seconds, err := strconv.Atoi(reply.ExpiresIn)
+	if err != nil || seconds <= 0 {
+		seconds = 3599
+	}
+	provider.token = reply.AccessToken
+	provider.tokenExpiresAt = time.Now().Add(time.Duration(seconds)*time.Second - time.Minute)
+	return provider.token, nil
+}
+
+func (provider *DarajaProvider) StkPush(ctx context.Context, push StkPushRequest) (StkPushResponse, error) {
+	token, err := provider.accessToken(ctx)
+	if err != nil {
+		return StkPushResponse{}, err
+	}
+
+	timestamp := time.Now().In(nairobi).Format(darajaTimestampFormat)
+	body, err := json.Marshal(map[string]interface{}{
+		"BusinessShortCode": provider.shortCode,
+		"Password":          darajaPassword(provider.shortCode, provider.passKey, timestamp),
+		"Timestamp":         timestamp,
+		"TransactionType":   provider.transactionType,
+		"Amount":            push.Amount,
+		"PartyA":            push.Phone,
+		"PartyB":            provider.shortCode,
+		"PhoneNumber":       push.Phone,
+		"CallBackURL":       push.CallbackURL,
+		"AccountReference":  truncate(push.Reference, 12),
+		"TransactionDesc":   truncate(push.Description, 13),
+	})
+	if err != nil {
+		return StkPushResponse{}, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.baseURL+"/mpesa/stkpush/v1/processrequest", bytes.NewReader(body))
+	if err != nil {
+		return StkPushResponse{}, err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Set("Content-Type", "application/json")
+	response, err := provider.client.Do(request)
+	if err != nil {
+		return StkPushResponse{}, err
+	}
+	defer response.Body.Close()
+
+	var reply struct {
+		MerchantRequestID   string `json:"MerchantRequestID"`
+		CheckoutRequestID   string `json:"CheckoutRequestID"`
+		ResponseCode        string `json:"ResponseCode"`
+		ResponseDescription string `json:"ResponseDescription"`
+		CustomerMessage     string `json:"CustomerMessage"`
+		darajaError
+	}
+	if err := json.NewDecoder(response.Body).Decode(&reply); err != nil {
+		return StkPushResponse{}, fmt.Errorf("could not read the daraja reply: %v", err)
+	}
+	if reply.ErrorMessage != "" {
+		return StkPushResponse{}, errors.New("daraja refused the payment request: " + reply.ErrorMessage)
+	} else if response.StatusCode != http.StatusOK || reply.ResponseCode != "0" || reply.CheckoutRequestID == "" {
+		return StkPushResponse{}, fmt.Errorf("daraja refused the payment request: %s", reply.ResponseDescription)
+	}
+	return StkPushResponse{
+		MerchantRequestID: reply.MerchantRequestID,
+		CheckoutRequestID: reply.CheckoutRequestID,
+		CustomerMessage:   reply.CustomerMessage,
+	}, nil
+}
+
+func (provider *DarajaProvider) ParseCallback(body []byte) (CallbackResult, error) {
+	return parseDarajaCallback(body)
+}
+
+// the body daraja posts to the callback url
+type darajaCallback struct {
+	Body struct {
+		StkCallback struct {
+			MerchantRequestID string `json:"MerchantRequestID"`
+			CheckoutRequestID string `json:"CheckoutRequestID"`
+			ResultCode        int    `json:"ResultCode"`
+			ResultDesc        string `json:"ResultDesc"`
+			CallbackMetadata  struct {
+				Item []darajaItem `json:"Item"`
+			} `json:"CallbackMetadata"`
+		} `json:"stkCallback"`
+	} `json:"Body"`
+}
+
+// item values are numbers or strings, the receipt is a string and the
+// amount and phone number are numbers
+type darajaItem struct {
+	Name  string          `json:"Name"`
+	Value json.RawMessage `json:"Value,omitempty"`
+}
+
+func (item darajaItem) text() string {
+	var value string
+	if err := json.Unmarshal(item.Value, &value); err == nil {
+		return value
+	}
+	return string(item.Value)
+}
+
+// the metadata items are only sent for successful payments
+func parseDarajaCallback(body []byte) (CallbackResult, error) {
+	var callback darajaCallback
+	if err := json.Unmarshal(body, &callback); err != nil {
+		return CallbackResult{}, fmt.Errorf("could not read the callback: %v", err)
+	}
+	stk := callback.Body.StkCallback
+	if stk.CheckoutRequestID == "" {
+		return CallbackResult{}, errors.New("callback has no checkout request id")
+	}
+
+	result := CallbackResult{
+		MerchantRequestID: stk.MerchantRequestID,
+		CheckoutRequestID: stk.CheckoutRequestID,
+		ResultCode:        stk.ResultCode,
+		ResultDesc:        stk.ResultDesc,
+	}
+	for _, item := range stk.CallbackMetadata.Item {
+		switch item.Name {
+		case "Amount":
+			amount, err := strconv.ParseFloat(item.text(), 64)
+			if err != nil || amount < 0 {
+				return CallbackResult{}, errors.New("callback has an invalid amount")
+			}
+			result.Amount = uint(amount)
+		case "MpesaReceiptNumber":
+			result.Receipt = item.text()
+		case "PhoneNumber":
+			result.Phone = item.text()
+		}
+	}
+	if result.ResultCode == 0 && result.Receipt == "" {
+		return CallbackResult{}, errors.New("successful callback has no receipt number")
+	}
+	return result, nil
+}
@@ -0,0 +1,113 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDarajaStkPush(t *testing.T) {
+	tokenRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth/v1/generate":
+			tokenRequests++
+			if key, secret, ok := r.BasicAuth(); !ok || key != "key" || secret != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"access_token":"token","expires_in":"3599"}`))
+		case "/mpesa/stkpush/v1/processrequest":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			timestamp, _ := body["Timestamp"].(string)
+			if body["Password"] != darajaPassword("174379", "passkey", timestamp) || body["PhoneNumber"] != "254712345678" {
+				w.Write([]byte(`{"requestId":"1","errorCode":"400.002.02","errorMessage":"Bad Request - Invalid Password"}`))
+				return
+			}
+			w.Write([]byte(`{"MerchantRequestID":"m-1","CheckoutRequestID":"ws_CO_1","ResponseCode":"0","ResponseDescription":"Success","CustomerMessage":"Success"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider := &DarajaProvider{
+		baseURL:         server.URL,
+		consumerKey:     "key",
+		consumerSecret:  "secret",
+		shortCode:       "174379",
+		passKey:         "passkey",
+		transactionType: "CustomerPayBillOnline",
+		client:          server.Client(),
+	}
+	for i := 0; i < 2; i++ {
+		response, err := provider.StkPush(context.Background(), StkPushRequest{Phone: "254712345678", Amount: 100, CallbackURL: "https://example.com/cb"})
+		if err != nil {
+			t.Fatalf("push failed: %v", err)
+		} else if response.CheckoutRequestID != "ws_CO_1" || response.MerchantRequestID != "m-1" {
+			t.Fatalf("unexpected response %+v", response)
+		}
+	}
+	if tokenRequests != 1 {
+		t.Errorf("expected the access token to be reused, it was requested %d times", tokenRequests)
+	}
+
+	if _, err := provider.StkPush(context.Background(), StkPushRequest{Phone: "254700000000", Amount: 100}); err == nil {
+		t.Error("expected a daraja error to be returned")
+	}
+}
+
+func TestParseDarajaCallback(t *testing.T) {
+	paid, err := parseDarajaCallback([]byte(`{"Body":{"stkCallback":{"MerchantRequestID":"m-1","CheckoutRequestID":"ws_CO_1","ResultCode":0,"ResultDesc":"The service request is processed successfully.","CallbackMetadata":{"Item":[{"Name":"Amount","Value":1.00},{"Name":"MpesaReceiptNumber","Value":"NLJ7RT61SV"},{"Name":"TransactionDate","Value":20191219102115},{"Name":"PhoneNumber","Value":254708374149}]}}}}`))
+	if err != nil {
+		t.Fatalf("could not parse a payment: %v", err)
+	}
+	want := CallbackResult{MerchantRequestID: "m-1", CheckoutRequestID: "ws_CO_1", ResultDesc: "The service request is processed successfully.", Amount: 1, Receipt: "NLJ7RT61SV", Phone: "254708374149"}
+	if paid != want {
+		t.Errorf("expected %+v got %+v", want, paid)
+	}
+
+	cancelled, err := parseDarajaCallback([]byte(`{"Body":{"stkCallback":{"MerchantRequestID":"m-1","CheckoutRequestID":"ws_CO_1","ResultCode":1032,"ResultDesc":"Request cancelled by user"}}}`))
+	if err != nil {
+		t.Fatalf("could not parse a cancellation: %v", err)
+	} else if cancelled.ResultCode != 1032 || cancelled.Receipt != "" {
+		t.Errorf("unexpected cancellation %+v", cancelled)
+	}
+
+	for _, body := range []string{`{}`, `not json`, `{"Body":{"stkCallback":{"CheckoutRequestID":"ws_CO_1","ResultCode":0}}}`} {
+		if _, err := parseDarajaCallback([]byte(body)); err == nil {
+			t.Errorf("expected %s to be refused", body)
+		}
+	}
+}
+
+func TestFakeProviderRoundTrip(t *testing.T) {
+	provider := NewFakeProvider()
+	response, err := provider.StkPush(context.Background(), StkPushRequest{Phone: "254712345678", Amount: 500})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(provider.Pushes()) != 1 {
+		t.Fatalf("expected the push to be recorded")
+	}
+
+	result, err := provider.ParseCallback(FakeCallback(response.MerchantRequestID, response.CheckoutRequestID, 0, 500, "254712345678"))
+	if err != nil {
+		t.Fatal(err)
+	} else if result.CheckoutRequestID != response.CheckoutRequestID || result.Amount != 500 || result.Receipt == "" || result.Phone != "254712345678" {
+		t.Errorf("unexpected callback %+v", result)
+	}
+
+	result, err = provider.ParseCallback(FakeCallback(response.MerchantRequestID, response.CheckoutRequestID, 1032, 500, ""))
+	if err != nil {
+		t.Fatal(err)
+	} else if result.ResultCode != 1032 {
+		t.Errorf("expected a cancelled callback got %+v", result)
+	}
+}
@@ -0,0 +1,81 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// stands in for daraja when there is no sandbox to talk to. Requests are
+// only recorded and the callback daraja would send is made with
+// FakeCallback
+type FakeProvider struct {
+	mutex  sync.Mutex
+	pushes []StkPushRequest
+	// returned by the next push when set
+	Err error
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{}
+}
+
+func (provider *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+func (provider *FakeProvider) StkPush(ctx context.Context, push StkPushRequest) (StkPushResponse, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	if provider.Err != nil {
+		err := provider.Err
+		provider.Err = nil
+		return StkPushResponse{}, err
+	}
+	provider.pushes = append(provider.pushes, push)
+	n := len(provider.pushes)
+	return StkPushResponse{
+		MerchantRequestID: fmt.Sprintf("fake-merchant-%d", n),
+		CheckoutRequestID: fmt.Sprintf("ws_CO_fake_%d", n),
+		CustomerMessage:   "Success. Request accepted for processing",
+	}, nil
+}
+
+// callbacks have the same shape as the ones daraja sends
+func (provider *FakeProvider) ParseCallback(body []byte) (CallbackResult, error) {
+	return parseDarajaCallback(body)
+}
+
+// requests pushed so far
+func (provider *FakeProvider) Pushes() []StkPushRequest {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	return append([]StkPushRequest{}, provider.pushes...)
+}
+
+// the callback daraja would send for a request, a zero result code for a
+// payment and anything else for a failure such as 1032 when the customer
+// cancels
+func FakeCallback(merchantRequestId string, checkoutRequestId string, resultCode int, amount uint, phone string) []byte {
+	stk := map[string]interface{}{
+		"MerchantRequestID": merchantRequestId,
+		"CheckoutRequestID": checkoutRequestId,
+		"ResultCode":        resultCode,
+		"ResultDesc":        "Request cancelled by user",
+	}
+	if resultCode == 0 {
+		stk["ResultDesc"] = "The service request is processed successfully."
+		stk["CallbackMetadata"] = map[string]interface{}{
+			"Item": []map[string]interface{}{
+				{"Name": "Amount", "Value": amount},
+				{"Name": "MpesaReceiptNumber", "Value": "FAKE" + checkoutRequestId},
+				{"Name": "Balance"},
+				{"Name": "TransactionDate", "Value": 20240301120000},
+				{"Name": "PhoneNumber", "Value": json.Number(phone)},
+			},
+		}
+	}
+	body, _ := json.Marshal(map[string]interface{}{"Body": map[string]interface{}{"stkCallback": stk}})
+	return body
+}
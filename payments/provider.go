@@ -0,0 +1,90 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	ProviderDaraja = "daraja"
+	ProviderFake   = "fake"
+)
+
+// a payment request sent to the customer's phone
+type StkPushRequest struct {
+	Phone       string
+	Amount      uint
+	Reference   string
+	Description string
+	CallbackURL string
+}
+
+type StkPushResponse struct {
+	MerchantRequestID string
+	CheckoutRequestID string
+	CustomerMessage   string
+}
+
+// what the provider reported back once the customer answered the request.
+// A zero result code means the customer paid
+type CallbackResult struct {
+	MerchantRequestID string
+	CheckoutRequestID string
+	ResultCode        int
+	ResultDesc        string
+	Amount            uint
+	Receipt           string
+	Phone             string
+}
+
+// something that can ask a customer to pay and read the callbacks it sends
+// once they have
+type Provider interface {
+	Name() string
+	StkPush(ctx context.Context, request StkPushRequest) (StkPushResponse, error)
+	ParseCallback(body []byte) (CallbackResult, error)
+}
+
+var (
+	providerMutex sync.Mutex
+	provider      Provider
+)
+
+// the provider is picked with PAYMENTS_PROVIDER, daraja when it is not set.
+// It is created on first use since the env file is loaded after package init
+func GetProvider() (Provider, error) {
+	providerMutex.Lock()
+	defer providerMutex.Unlock()
+	if provider != nil {
+		return provider, nil
+	}
+
+	configured, err := newProvider(strings.ToLower(strings.TrimSpace(os.Getenv("PAYMENTS_PROVIDER"))))
+	if err != nil {
+		return nil, err
+	}
+	provider = configured
+	return provider, nil
+}
+
+// replace the provider, used by tests and tools. Setting nil goes back to
+// the configured provider
+func SetProvider(replacement Provider) {
+	providerMutex.Lock()
+	defer providerMutex.Unlock()
+	provider = replacement
+}
+
+func newProvider(name string) (Provider, error) {
+	switch name {
+	case "", ProviderDaraja:
+		return NewDarajaProvider()
+	case ProviderFake:
+		return NewFakeProvider(), nil
+	default:
+		return nil, errors.New("unknown payments provider " + name)
+	}
+}
@@ -0,0 +1,17 @@
+package payments
+
+import (
+	"eleliafrika.com/backend/auth"
+	"github.com/gin-gonic/gin"
+)
+
+func PaymentsRoutes(router *gin.Engine) {
+	paymentsRoutes := router.Group("/payments")
+	{
+		paymentsRoutes.POST("/stkpush", auth.RequireUser(), StkPush)
+		paymentsRoutes.POST("/mpesa/callback/:token", MpesaCallback)
+		paymentsRoutes.GET("/status", auth.RequireUser(), GetPaymentStatus)
+		paymentsRoutes.GET("/transactions", auth.RequireUser(), GetTransactions)
+		paymentsRoutes.POST("/sandbox/complete", auth.RequireUser(), CompleteSandboxPayment)
+	}
+}
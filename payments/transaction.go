@@ -0,0 +1,398 @@
+package payments
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/invoices"
+	"eleliafrika.com/backend/mainad"
+	"eleliafrika.com/backend/packages"
+	"eleliafrika.com/backend/users"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	PurposePackage = "package"
+	// main ads are paid for through their approved booking
+	PurposeBooking = "booking"
+
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	// the customer paid but the package or ad could not be given to them,
+	// these need someone to look at them
+	StatusUnfulfilled = "unfulfilled"
+
+	defaultPendingWindow = 2 * time.Minute
+	pushTimeout          = 40 * time.Second
+)
+
+// a payment a user started for a package or a main ad. The checkout request
+// id is what the provider sends back in its callback
+type Transaction struct {
+	gorm.Model
+	TransactionID     string     `gorm:"column:transaction_id;not null;unique" json:"transactionid"`
+	UserID            string     `gorm:"column:user_id;not null;index" json:"userid"`
+	Purpose           string     `gorm:"column:purpose;not null" json:"purpose"`
+	ItemID            string     `gorm:"column:item_id;not null;index" json:"itemid"`
+	Days              int        `gorm:"column:days;not null;default:0" json:"days"`
+	Amount            uint       `gorm:"column:amount;not null" json:"amount"`
+	Phone             string     `gorm:"column:phone;not null" json:"phone"`
+	Provider          string     `gorm:"column:provider;not null" json:"provider"`
+	MerchantRequestID string     `gorm:"column:merchant_request_id;not null;default:''" json:"merchantrequestid"`
+	CheckoutRequestID string     `gorm:"column:checkout_request_id;not null;default:''" json:"checkoutrequestid"`
+	Status            string     `gorm:"column:status;not null;index" json:"status"`
+	ResultCode        int        `gorm:"column:result_code;not null;default:0" json:"resultcode"`
+	ResultDesc        string     `gorm:"column:result_desc;not null;default:''" json:"resultdesc"`
+	Receipt           string     `gorm:"column:receipt;not null;default:''" json:"receipt"`
+	CompletedAt       *time.Time `gorm:"column:completed_at" json:"completedat"`
+}
+
+// checkout ids and receipts are only set once the provider has answered, so
+// they are unique among the rows that have one
+var transactionIndexesSQL = []string{
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_checkout
+	ON transactions (checkout_request_id) WHERE checkout_request_id <> ''`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_receipt
+	ON transactions (receipt) WHERE receipt <> ''`,
+}
+
+func MigrateTransactions() error {
+	if err := database.Database.AutoMigrate(&Transaction{}); err != nil {
+		return err
+	}
+	for _, statement := range transactionIndexesSQL {
+		if err := database.Database.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type PaymentInput struct {
+	Purpose string `json:"purpose"`
+	ItemID  string `json:"itemid"`
+	Phone   string `json:"phone"`
+	// days a booking runs for, taken from the booking itself
+	Days int `json:"-"`
+}
+
+var kenyanPhone = regexp.MustCompile(`^254[17][0-9]{8}$`)
+
+// phone numbers in the 2547XXXXXXXX form daraja expects. Numbers starting
+// with 0, +254 or 254 and without a prefix are accepted
+func NormalizePhone(phone string) (string, error) {
+	phone = strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(phone))
+	phone = strings.TrimPrefix(phone, "+")
+	if strings.HasPrefix(phone, "0") {
+		phone = "254" + phone[1:]
+	} else if len(phone) == 9 {
+		phone = "254" + phone
+	}
+	if !kenyanPhone.MatchString(phone) {
+		return "", errors.New("phone should be a safaricom number such as 0712345678")
+	}
+	return phone, nil
+}
+
+// how long a push waits on the customer's phone before another one may be
+// sent for the same item, PAYMENT_PENDING_WINDOW in seconds
+func pendingWindow() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("PAYMENT_PENDING_WINDOW"))
+	if err != nil || seconds <= 0 {
+		return defaultPendingWindow
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// the callback url with the secret that proves a callback came back through
+// the url we gave the provider. MPESA_CALLBACK_URL is the public address of
+// the callback route and MPESA_CALLBACK_TOKEN the secret
+func callbackURL() (string, error) {
+	base := strings.TrimRight(os.Getenv("MPESA_CALLBACK_URL"), "/")
+	token := os.Getenv("MPESA_CALLBACK_TOKEN")
+	if base == "" || token == "" {
+		return "", errors.New("MPESA_CALLBACK_URL and MPESA_CALLBACK_TOKEN have to be set")
+	}
+	return base + "/" + token, nil
+}
+
+func VerifyCallbackToken(token string) error {
+	expected := os.Getenv("MPESA_CALLBACK_TOKEN")
+	if expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		return errors.New("callback token is not valid")
+	}
+	return nil
+}
+
+// what is being paid for as invoice lines, priced on the server
+func linesOf(userId string, input *PaymentInput) ([]invoices.Line, error) {
+	switch input.Purpose {
+	case PurposePackage:
+		input.Days = 0
+		packageModel, err := packages.FindPurchasablePackage(input.ItemID)
+		if err != nil {
+			return nil, err
+		}
+		return []invoices.Line{{
+			Kind:        PurposePackage,
+			ItemID:      packageModel.PackageId,
+			Description: packageModel.PackageName + " package",
+			Quantity:    1,
+			UnitPrice:   packageModel.Price,
+		}}, nil
+	case PurposeBooking:
+		booking, err := mainad.FindPayableBooking(userId, input.ItemID)
+		if err != nil {
+			return nil, err
+		}
+		input.Days = booking.Days
+		return []invoices.Line{{
+			Kind:   PurposeBooking,
+			ItemID: booking.BookingID,
+			Description: fmt.Sprintf("%s banner on %s, %s to %s", booking.AdName, booking.AdCategory,
+				booking.StartDate.Format("2 Jan 2006"), booking.EndDate.AddDate(0, 0, -1).Format("2 Jan 2006")),
+			Quantity:  booking.Days,
+			UnitPrice: booking.Amount / uint(booking.Days),
+		}}, nil
+	default:
+		return nil, errors.New("purpose should be package or booking")
+	}
+}
+
+func totalOf(lines []invoices.Line) uint {
+	var total uint
+	for _, line := range lines {
+		total += line.UnitPrice * uint(line.Quantity)
+	}
+	return total
+}
+
+// ask the user to pay on their phone. While an earlier request for the same
+// item is still waiting on the phone it is returned instead of sending
+// another one
+func StartPayment(userId string, input PaymentInput) (Transaction, error) {
+	paymentProvider, err := GetProvider()
+	if err != nil {
+		return Transaction{}, err
+	}
+	callback, err := callbackURL()
+	if err != nil {
+		return Transaction{}, err
+	}
+	lines, err := linesOf(userId, &input)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	var transaction Transaction
+	reused := false
+	err = database.Database.Transaction(func(tx *gorm.DB) error {
+		// one payment is started at a time for each user
+		var payer users.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id=?", userId).Find(&payer).Error
+		if err != nil {
+			return err
+		} else if payer.UserID == "" {
+			return errors.New("user does not exist")
+		}
+		if input.Phone == "" {
+			input.Phone = payer.Phone
+		}
+		phone, err := NormalizePhone(input.Phone)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Where("user_id=? AND purpose=? AND item_id=? AND status=? AND created_at > ?",
+			userId, input.Purpose, input.ItemID, StatusPending, time.Now().Add(-pendingWindow())).
+			Order("created_at DESC").Find(&transaction).Error
+		if err != nil {
+			return err
+		} else if transaction.TransactionID != "" {
+			reused = true
+			return nil
+		}
+
+		transaction = Transaction{
+			TransactionID: uuid.New().String(),
+			UserID:        userId,
+			Purpose:       input.Purpose,
+			ItemID:        input.ItemID,
+			Days:          input.Days,
+			Amount:        totalOf(lines),
+			Phone:         phone,
+			Provider:      paymentProvider.Name(),
+			Status:        StatusPending,
+		}
+		if err := tx.Create(&transaction).Error; err != nil {
+			return err
+		}
+		_, err = invoices.CreateDraft(tx, userId, transaction.TransactionID, lines)
+		return err
+	})
+	if err != nil || reused {
+		return transaction, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+	response, err := paymentProvider.StkPush(ctx, StkPushRequest{
+		Phone:       transaction.Phone,
+		Amount:      transaction.Amount,
+		Reference:   transaction.TransactionID[:8],
+		Description: lines[0].Description,
+		CallbackURL: callback,
+	})
+	if err != nil {
+		updateErr := database.Database.Transaction(func(tx *gorm.DB) error {
+			err := tx.Model(&Transaction{}).Where("transaction_id=?", transaction.TransactionID).
+				Updates(map[string]interface{}{"status": StatusFailed, "result_desc": err.Error()}).Error
+			if err != nil {
+				return err
+			}
+			return invoices.Void(tx, transaction.TransactionID, "the payment request could not be sent", time.Now())
+		})
+		if updateErr != nil {
+			log.Printf("could not mark the payment %s as failed: %v", transaction.TransactionID, updateErr)
+		}
+		return Transaction{}, err
+	}
+
+	transaction.MerchantRequestID = response.MerchantRequestID
+	transaction.CheckoutRequestID = response.CheckoutRequestID
+	err = database.Database.Model(&Transaction{}).Where("transaction_id=?", transaction.TransactionID).
+		Updates(map[string]interface{}{
+			"merchant_request_id": response.MerchantRequestID,
+			"checkout_request_id": response.CheckoutRequestID,
+		}).Error
+	if err != nil {
+		return Transaction{}, err
+	}
+	return transaction, nil
+}
+
+// the status a pending transaction moves to for a callback, and why
+func settle(transaction Transaction, result CallbackResult) (string, string) {
+	if result.ResultCode != 0 {
+		return StatusFailed, result.ResultDesc
+	} else if result.Amount != transaction.Amount {
+		return StatusUnfulfilled, fmt.Sprintf("paid %d but the price was %d", result.Amount, transaction.Amount)
+	}
+	return StatusSucceeded, result.ResultDesc
+}
+
+// give the user what they paid for
+func fulfil(tx *gorm.DB, transaction Transaction) error {
+	switch transaction.Purpose {
+	case PurposePackage:
+		_, err := packages.ApplyPurchase(tx, transaction.UserID, transaction.ItemID)
+		return err
+	case PurposeBooking:
+		_, err := mainad.FulfilBooking(tx, transaction.ItemID, transaction.Amount)
+		return err
+	default:
+		return errors.New("unknown payment purpose " + transaction.Purpose)
+	}
+}
+
+// record what the provider reported for a payment and activate the package
+// or main ad once it is paid. Callbacks can arrive more than once, a
+// transaction that is no longer pending is returned unchanged
+func CompletePayment(result CallbackResult) (Transaction, error) {
+	var transaction Transaction
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("checkout_request_id=?", result.CheckoutRequestID).Find(&transaction).Error
+		if err != nil {
+			return err
+		} else if transaction.TransactionID == "" {
+			return errors.New("no payment was started with this checkout request")
+		} else if transaction.MerchantRequestID != result.MerchantRequestID {
+			return errors.New("callback does not match the payment")
+		} else if transaction.Status != StatusPending {
+			return nil
+		}
+
+		status, description := settle(transaction, result)
+		if status == StatusSucceeded {
+			// a savepoint, so a payment that could not be fulfilled is still
+			// recorded
+			if err := tx.Transaction(func(inner *gorm.DB) error { return fulfil(inner, transaction) }); err != nil {
+				log.Printf("payment %s could not be fulfilled: %v", transaction.TransactionID, err)
+				status, description = StatusUnfulfilled, err.Error()
+			}
+		}
+
+		// the invoice is paid whenever the money came in, even if the package
+		// or ad still has to be sorted out by hand
+		now := time.Now()
+		if result.ResultCode != 0 {
+			if err := invoices.Void(tx, transaction.TransactionID, result.ResultDesc, now); err != nil {
+				return err
+			}
+		} else if result.Amount == transaction.Amount {
+			if _, err := invoices.MarkPaid(tx, transaction.TransactionID, result.Receipt, now); err != nil {
+				return err
+			}
+		}
+		transaction.Status = status
+		transaction.ResultCode = result.ResultCode
+		transaction.ResultDesc = description
+		transaction.Receipt = result.Receipt
+		transaction.CompletedAt = &now
+		return tx.Model(&Transaction{}).Where("transaction_id=?", transaction.TransactionID).
+			Updates(map[string]interface{}{
+				"status":       transaction.Status,
+				"result_code":  transaction.ResultCode,
+				"result_desc":  transaction.ResultDesc,
+				"receipt":      transaction.Receipt,
+				"completed_at": now,
+			}).Error
+	})
+	return transaction, err
+}
+
+// read a callback body with the configured provider and record it
+func HandleCallback(body []byte) (Transaction, error) {
+	paymentProvider, err := GetProvider()
+	if err != nil {
+		return Transaction{}, err
+	}
+	result, err := paymentProvider.ParseCallback(body)
+	if err != nil {
+		return Transaction{}, err
+	}
+	return CompletePayment(result)
+}
+
+func FindUserTransaction(userId string, transactionId string) (Transaction, error) {
+	var transaction Transaction
+	err := database.Database.Where("transaction_id=? AND user_id=?", transactionId, userId).Find(&transaction).Error
+	if err != nil {
+		return Transaction{}, err
+	} else if transaction.TransactionID == "" {
+		return Transaction{}, errors.New("payment not found")
+	}
+	return transaction, nil
+}
+
+func FetchUserTransactions(userId string) ([]Transaction, error) {
+	var transactions []Transaction
+	err := database.Database.Where("user_id=?", userId).Order("created_at DESC").Find(&transactions).Error
+	if err != nil {
+		return []Transaction{}, err
+	}
+	return transactions, nil
+}
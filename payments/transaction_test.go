@@ -0,0 +1,68 @@
+package payments
+
+import "testing"
+
+func TestNormalizePhone(t *testing.T) {
+	type testCase struct {
+		phone string
+		want  string
+		valid bool
+	}
+	cases := []testCase{
+		{"0712345678", "254712345678", true},
+		{"+254 712 345 678", "254712345678", true},
+		{"254112345678", "254112345678", true},
+		{"712345678", "254712345678", true},
+		{"0812345678", "", false},
+		{"07123", "", false},
+		{"", "", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.phone, func(t *testing.T) {
+			got, err := NormalizePhone(c.phone)
+			if (err == nil) != c.valid {
+				t.Fatalf("expected valid=%v got error %v", c.valid, err)
+			} else if got != c.want {
+				t.Errorf("expected %s got %s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestSettle(t *testing.T) {
+	transaction := Transaction{Amount: 500, Status: StatusPending}
+	type testCase struct {
+		name   string
+		result CallbackResult
+		want   string
+	}
+	cases := []testCase{
+		{"paid", CallbackResult{Amount: 500, Receipt: "R1"}, StatusSucceeded},
+		{"cancelled", CallbackResult{ResultCode: 1032}, StatusFailed},
+		{"paid too little", CallbackResult{Amount: 1, Receipt: "R1"}, StatusUnfulfilled},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got, _ := settle(transaction, c.result); got != c.want {
+				t.Errorf("expected %s got %s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestVerifyCallbackToken(t *testing.T) {
+	t.Setenv("MPESA_CALLBACK_TOKEN", "")
+	if err := VerifyCallbackToken(""); err == nil {
+		t.Error("expected callbacks to be refused without a token configured")
+	}
+
+	t.Setenv("MPESA_CALLBACK_TOKEN", "secret")
+	if err := VerifyCallbackToken("secret"); err != nil {
+		t.Errorf("expected the token to be accepted: %v", err)
+	}
+	if err := VerifyCallbackToken("guess"); err == nil {
+		t.Error("expected a wrong token to be refused")
+	}
+}
@@ -14,7 +14,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return empty product name",
 		data: AddProductInput{
 			"",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"image string",
 			[]string{"image", "image2"},
@@ -23,6 +23,8 @@ func TestAddproduct(t *testing.T) {
 			"brand",
 			"category",
 			"subcategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -30,7 +32,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return empty product name",
 		data: AddProductInput{
 			"   ",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"image string",
 			[]string{"image", "image2"},
@@ -39,6 +41,8 @@ func TestAddproduct(t *testing.T) {
 			"brand",
 			"category",
 			"subcategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -46,7 +50,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return invalida characters in name",
 		data: AddProductInput{
 			"productname@@%$@%$#",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"image string",
 			[]string{"image", "image2"},
@@ -55,6 +59,8 @@ func TestAddproduct(t *testing.T) {
 			"brand",
 			"category",
 			"subcategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -64,7 +70,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return empty product description",
 		data: AddProductInput{
 			"this is product name",
-			"200",
+			PriceInput{Amount: "200"},
 			"",
 			"image string",
 			[]string{"image", "image2"},
@@ -73,6 +79,8 @@ func TestAddproduct(t *testing.T) {
 			"brand",
 			"category",
 			"subcategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -80,7 +88,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return empty product description",
 		data: AddProductInput{
 			"product name",
-			"200",
+			PriceInput{Amount: "200"},
 			" ",
 			"image string",
 			[]string{"image", "image2"},
@@ -89,6 +97,8 @@ func TestAddproduct(t *testing.T) {
 			"brand",
 			"category",
 			"subcategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -96,7 +106,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return invalida characters in name",
 		data: AddProductInput{
 			"productname",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text )*&(&^*%*^%&*of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"image string",
 			[]string{"image", "image2"},
@@ -105,6 +115,8 @@ func TestAddproduct(t *testing.T) {
 			"brand",
 			"category",
 			"subcategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -114,7 +126,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return empty image ",
 		data: AddProductInput{
 			"productname",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"",
 			[]string{"image", "image2"},
@@ -123,6 +135,8 @@ func TestAddproduct(t *testing.T) {
 			"brand",
 			"category",
 			"subcategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -130,7 +144,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return empty image 2",
 		data: AddProductInput{
 			"productname",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"   ",
 			[]string{"image", "image2"},
@@ -139,6 +153,8 @@ func TestAddproduct(t *testing.T) {
 			"brand",
 			"category",
 			"subcategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -148,7 +164,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return empty image string",
 		data: AddProductInput{
 			"productname",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"image string",
 			[]string{"image", "image2"},
@@ -157,6 +173,8 @@ func TestAddproduct(t *testing.T) {
 			"brand",
 			"category",
 			"subcategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -164,7 +182,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return empty product type",
 		data: AddProductInput{
 			"name",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"image string",
 			[]string{"image", "image2"},
@@ -173,6 +191,8 @@ func TestAddproduct(t *testing.T) {
 			"brand",
 			"category",
 			"subcategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -180,7 +200,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return invalida characters in name",
 		data: AddProductInput{
 			"productname",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"image string",
 			[]string{"image", "image2"},
@@ -189,6 +209,8 @@ func TestAddproduct(t *testing.T) {
 			"brand",
 			"category",
 			"subcategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -198,7 +220,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return empty brand name",
 		data: AddProductInput{
 			"productname",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"image string",
 			[]string{"image", "image2"},
@@ -207,6 +229,8 @@ func TestAddproduct(t *testing.T) {
 			"",
 			"category",
 			"subcategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -214,7 +238,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return empty brand name",
 		data: AddProductInput{
 			"productname",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"image string",
 			[]string{"image", "image2"},
@@ -223,6 +247,8 @@ func TestAddproduct(t *testing.T) {
 			"    ",
 			"category",
 			"subcategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -230,7 +256,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return invalid characters in brand",
 		data: AddProductInput{
 			"productname",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"image string",
 			[]string{"image", "image2"},
@@ -239,6 +265,8 @@ func TestAddproduct(t *testing.T) {
 			"br#$^%*&%(&*and",
 			"category",
 			"subcategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -248,7 +276,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return empty category name",
 		data: AddProductInput{
 			"productname",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"image string",
 			[]string{"image", "image2"},
@@ -257,6 +285,8 @@ func TestAddproduct(t *testing.T) {
 			"brand",
 			"",
 			"subcategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -264,7 +294,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return invalid empty category name",
 		data: AddProductInput{
 			"productname",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"image string",
 			[]string{"image", "image2"},
@@ -273,6 +303,8 @@ func TestAddproduct(t *testing.T) {
 			"brand",
 			"    ",
 			"subcategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -280,7 +312,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return invalid char  in category",
 		data: AddProductInput{
 			"productname",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"image string",
 			[]string{"image", "image2"},
@@ -289,6 +321,8 @@ func TestAddproduct(t *testing.T) {
 			"brand",
 			"cateE%()@_)*&!@#gory",
 			"subcategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -298,7 +332,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return empty category name",
 		data: AddProductInput{
 			"productname",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"image string",
 			[]string{"image", "image2"},
@@ -307,6 +341,8 @@ func TestAddproduct(t *testing.T) {
 			"brand",
 			"category",
 			"",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -314,7 +350,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return invalid empty category name",
 		data: AddProductInput{
 			"productname",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"image string",
 			[]string{"image", "image2"},
@@ -323,6 +359,8 @@ func TestAddproduct(t *testing.T) {
 			"brand",
 			"brand",
 			"    ",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -330,7 +368,7 @@ func TestAddproduct(t *testing.T) {
 		name: "should return invalid char  in category",
 		data: AddProductInput{
 			"productname",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"image string",
 			[]string{"image", "image2"},
@@ -339,16 +377,18 @@ func TestAddproduct(t *testing.T) {
 			"brand",
 			"cateE%()@_)*&!@#gory",
 			"subc@@$(*%(ategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
 
 	// valid data
 	invalidMainImage := testCase{
-		name: "should return true",
+		name: "should return invalid main image",
 		data: AddProductInput{
 			"productname",
-			"200",
+			PriceInput{Amount: "200"},
 			"is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, when an unknown printer took a galley of type and scrambled it to make a type specimen book. It has survived not only five centuries, but also the leap into electronic typesetting, remaining essentially unchanged.",
 			"image string",
 			[]string{"image", "image2"},
@@ -357,6 +397,8 @@ func TestAddproduct(t *testing.T) {
 			"brand",
 			"category",
 			"subcategory",
+			"",
+			nil,
 		},
 		want: false,
 	}
@@ -386,11 +428,57 @@ func TestAddproduct(t *testing.T) {
 	}
 
 	for _, item := range cases {
-		result, _ := ValidateProductInput(&item.data)
+		result, err := ValidateProductInput(&item.data)
 
 		if result != item.want {
-			t.Errorf("test failed!!")
+			t.Errorf("test failed!! %s: %v", item.name, err)
 		}
 	}
 	t.Logf("test passed")
 }
+
+func TestUpdateProductInput(t *testing.T) {
+	type testCase struct {
+		name string
+		data AddProductInput
+		want bool
+	}
+
+	valid := AddProductInput{
+		ProductName:        "product",
+		Price:              PriceInput{Amount: "200"},
+		ProductDescription: "is simply dummy text of the printing and typesetting industry. Lorem Ipsum has been the industry's standard dummy text ever since the 1500s.",
+		Quantity:           20,
+		ProductType:        "new",
+		Brand:              "brand",
+		Category:           "category",
+		SubCategory:        "subcategory",
+	}
+	withUploadID := valid
+	withUploadID.MainImageID = "image-id"
+	withBoth := withUploadID
+	withBoth.MainImage = "image string"
+	invalidImage := valid
+	invalidImage.MainImage = "image string"
+	withGallery := valid
+	withGallery.ImageIDs = []string{"image-id"}
+	withBase64Gallery := valid
+	withBase64Gallery.ProductImages = []string{"image string"}
+
+	cases := []testCase{
+		{name: "should keep the main image", data: valid, want: true},
+		{name: "should take an uploaded main image", data: withUploadID, want: true},
+		{name: "should return both main images sent", data: withBoth, want: false},
+		{name: "should return invalid main image", data: invalidImage, want: false},
+		{name: "should return gallery ids sent", data: withGallery, want: false},
+		{name: "should return gallery images sent", data: withBase64Gallery, want: false},
+	}
+
+	for _, item := range cases {
+		result, err := ValidateProductUpdate(&item.data)
+
+		if result != item.want {
+			t.Errorf("test failed!! %s: %v", item.name, err)
+		}
+	}
+}
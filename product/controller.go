@@ -10,6 +10,8 @@ import (
 	"eleliafrika.com/backend/category"
 	"eleliafrika.com/backend/images"
 	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/packages"
+	"eleliafrika.com/backend/pagination"
 	subcategory "eleliafrika.com/backend/subcategories"
 	"eleliafrika.com/backend/users"
 	"github.com/gin-gonic/gin"
@@ -117,10 +119,10 @@ func AddProduct(context *gin.Context) {
 				return
 			} else {
 
-				imageUrl, err := images.UploadHandler(productInput.ProductName, productInput.MainImage, context)
+				price, err := ParsePriceInput(productInput.Price)
 				if err != nil {
 					response := models.Reply{
-						Message: "main image not saved",
+						Message: err.Error(),
 						Success: false,
 						Error:   err.Error(),
 					}
@@ -128,77 +130,41 @@ func AddProduct(context *gin.Context) {
 					return
 				}
 				product := Product{
-					ProductID:          productuuid.String(),
-					ProductName:        productInput.ProductName,
-					ProductPrice:       productInput.ProductPrice,
-					ProductDescription: productInput.ProductDescription,
-					UserID:             user.UserID,
-					MainImage:          imageUrl,
-					Quantity:           productInput.Quantity,
-					ProductType:        productInput.ProductType,
-					TotalLikes:         0,
-					TotalComments:      0,
-					DateAdded:          formattedTime,
-					LastUpdated:        formattedTime,
-					LatestInteractions: formattedTime,
-					TotalInteractions:  0,
-					TotalBookmarks:     0,
-					Brand:              productInput.Brand,
-					Category:           productInput.Category,
-					SubCategory:        productInput.SubCategory,
+					ProductID:           productuuid.String(),
+					ProductName:         productInput.ProductName,
+					PriceMinor:          price.Minor,
+					Currency:            price.Currency,
+					IsNegotiable:        price.IsNegotiable,
+					PriceOnRequest:      price.PriceOnRequest,
+					CompareAtPriceMinor: price.CompareAtMinor,
+					ProductDescription:  productInput.ProductDescription,
+					UserID:              user.UserID,
+					Quantity:            productInput.Quantity,
+					ProductType:         productInput.ProductType,
+					TotalLikes:          0,
+					TotalComments:       0,
+					DateAdded:           formattedTime,
+					LastUpdated:         formattedTime,
+					LatestInteractions:  formattedTime,
+					TotalInteractions:   0,
+					TotalBookmarks:      0,
+					Brand:               productInput.Brand,
+					Category:            productInput.Category,
+					SubCategory:         productInput.SubCategory,
 				}
 
-				savedProduct, err := product.Save()
-
+				savedProduct, err := CreateProduct(product, productInput)
 				if err != nil {
-					response := models.Reply{
-						Message: "error uploading product images",
-						Success: false,
-						Error:   err.Error(),
-					}
-					context.JSON(http.StatusBadRequest, response)
-					return
-				} else {
-					for _, i := range productInput.ProductImages {
-						imageUrl, err := images.UploadHandler(productInput.ProductName, i, context)
-						if err != nil {
-							response := models.Reply{
-								Message: "error with saving image",
-								Success: false,
-								Error:   err.Error(),
-							}
-							context.JSON(http.StatusBadRequest, response)
-							return
-						}
-						imageuuid := uuid.New()
-						image := models.ProductImage{
-							ImageID:   imageuuid.String(),
-							ProductID: productuuid.String(),
-							ImageUrl:  imageUrl,
-						}
-						_, err = image.Save()
-						if err != nil {
-							response := models.Reply{
-								Message: "error with saving image",
-								Success: false,
-								Error:   err.Error(),
-							}
-							context.JSON(http.StatusBadRequest, response)
-							return
-						}
+					status := http.StatusBadRequest
+					if packages.IsQuotaError(err) {
+						status = http.StatusForbidden
 					}
-				}
-
-				_, err = users.UpdateUserUtil(user.UserID, users.User{
-					NoOfProducts: user.NoOfProducts + 1,
-				})
-				if err != nil {
 					response := models.Reply{
-						Message: "error login out user",
-						Error:   err.Error(),
+						Message: "product could not be added",
 						Success: false,
+						Error:   err.Error(),
 					}
-					context.JSON(http.StatusBadRequest, response)
+					context.JSON(status, response)
 					return
 				}
 
@@ -214,8 +180,18 @@ func AddProduct(context *gin.Context) {
 	}
 }
 func GetAllProducts(context *gin.Context) {
+	request, err := pagination.ParseRequest(context, "date", true)
+	if err != nil {
+		response := models.Reply{
+			Message: "invalid pagination parameters",
+			Success: false,
+			Error:   err.Error(),
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
 
-	products, err := Fetchproducts()
+	_, page, err := FetchProductsPage(request)
 	if err != nil {
 		response := models.Reply{
 			Message: "error fetching products",
@@ -228,112 +204,60 @@ func GetAllProducts(context *gin.Context) {
 		response := models.Reply{
 			Message: "all products fetched",
 			Success: true,
-			Data:    products,
+			Data:    page,
 		}
 		context.JSON(http.StatusOK, response)
 		return
 	}
 }
 func GetAllAds(context *gin.Context) {
-	var err error
 	query := context.Query("search")
 	query = strings.ReplaceAll(strings.ToLower(query), "'", "")
 
-	products, err := FetchAds()
+	ads := AdsQuery()
+	sorts := adSorts
+	defaultSort := "date"
+	if query == "top" {
+		ads = ads.Scopes(TopSellersScope)
+	} else if BuildSearchQuery(query) != "" {
+		ads = ads.Scopes(SearchScope(query))
+		sorts = SearchSorts(query)
+		defaultSort = "relevance"
+	}
 
+	filter, err := ParseAdFilter(context)
 	if err != nil {
 		response := models.Reply{
-			Message: "error fetching ads",
+			Message: "invalid filter parameters",
 			Success: false,
 			Error:   err.Error(),
 		}
 		context.JSON(http.StatusBadRequest, response)
 		return
-	} else {
-		var productList []Product
-		addedProducts := make(map[uint]bool)
-
-		if query != "" {
-			if query == "top" {
-				for _, item := range products {
-					user, err := users.FindUserById(item.UserID)
-					if err != nil {
-						response := models.Reply{
-							Message: "error fetching user",
-							Success: false,
-							Error:   err.Error(),
-						}
-						context.JSON(http.StatusBadRequest, response)
-						return
-					}
-					if strings.ToLower(user.PackageType) != "basic" {
-						productList = append(productList, item)
-					}
-				}
-			} else {
+	}
+	ads = filter.Scope(ads)
 
-				for _, item := range products {
-					splitName := strings.ReplaceAll(query, "and", "")
-					fmt.Println(splitName)
-					newQ := strings.Split(splitName, " ")
-					for _, segment := range newQ {
-						if segment != "" {
-							if strings.ToLower(item.ProductName) == segment || strings.Contains(strings.ToLower(item.ProductName), segment) {
-								if _, exists := addedProducts[item.ID]; !exists {
-									productList = append(productList, item)
-									addedProducts[item.ID] = true
-								}
-							} else if strings.ToLower(item.Category) == segment || strings.Contains(strings.ToLower(item.Category), segment) {
-								if _, exists := addedProducts[item.ID]; !exists {
-									productList = append(productList, item)
-									addedProducts[item.ID] = true
-								}
-							} else if strings.ToLower(item.SubCategory) == segment || strings.Contains(strings.ToLower(item.SubCategory), segment) {
-								if _, exists := addedProducts[item.ID]; !exists {
-									productList = append(productList, item)
-									addedProducts[item.ID] = true
-								}
-							} else if strings.ToLower(item.Brand) == segment || strings.Contains(strings.ToLower(item.Brand), segment) {
-								if _, exists := addedProducts[item.ID]; !exists {
-									productList = append(productList, item)
-									addedProducts[item.ID] = true
-								}
-							} else if strings.Contains(strings.ToLower(item.ProductDescription), segment) {
-								if _, exists := addedProducts[item.ID]; !exists {
-									productList = append(productList, item)
-									addedProducts[item.ID] = true
-								}
-							}
-						}
-					}
+	request, err := pagination.ParseRequest(context, defaultSort, true)
+	if err != nil {
+		response := models.Reply{
+			Message: "invalid pagination parameters",
+			Success: false,
+			Error:   err.Error(),
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
 
-					currentuser, err := users.FindUserById(string(item.UserID))
-					if err != nil {
-						response := models.Reply{
-							Error:   err.Error(),
-							Message: "error finding the seller",
-							Success: false,
-						}
-						context.JSON(http.StatusBadRequest, response)
-						return
-					} else {
-						fname := currentuser.Firstname
-						mName := currentuser.Middlename
-						lname := currentuser.Lastname
-
-						if strings.ToLower(fname) == query || strings.Contains(fname, query) {
-							productList = append(productList, item)
-						} else if strings.ToLower(lname) == query || strings.Contains(lname, query) {
-							productList = append(productList, item)
-						} else if strings.ToLower(mName) == query || strings.Contains(mName, query) {
-							productList = append(productList, item)
-						}
-					}
-				}
-			}
-		} else {
-			productList = products
+	productList, page, err := FetchAdsPage(ads, request, sorts)
+	if err != nil {
+		response := models.Reply{
+			Message: "error fetching ads",
+			Success: false,
+			Error:   err.Error(),
 		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	} else {
 		var data []interface{}
 		for _, product := range productList {
 
@@ -356,10 +280,25 @@ func GetAllAds(context *gin.Context) {
 			}
 
 		}
+		page.Items = data
+
+		facets, err := FetchFacets(ads)
+		if err != nil {
+			response := models.Reply{
+				Error:   err.Error(),
+				Message: "error counting the ads",
+				Success: false,
+			}
+			context.JSON(http.StatusBadRequest, response)
+			return
+		}
 		response := models.Reply{
 			Message: "all ads fetched",
 			Success: true,
-			Data:    data,
+			Data: gin.H{
+				"ads":    page,
+				"facets": facets,
+			},
 		}
 		context.JSON(http.StatusOK, response)
 		return
@@ -557,7 +496,7 @@ func UpdateProduct(context *gin.Context) {
 		return
 	}
 
-	success, err := ValidateProductInput(&productUpdate)
+	success, err := ValidateProductUpdate(&productUpdate)
 
 	if err != nil {
 		response := models.Reply{
@@ -597,7 +536,6 @@ func UpdateProduct(context *gin.Context) {
 			productid := context.Query("id")
 			if productid != "" {
 
-				query := "product_id=" + productid
 				id := strings.ReplaceAll(productid, "'", "")
 
 				productExist, err := FindSingleProduct(id)
@@ -636,9 +574,23 @@ func UpdateProduct(context *gin.Context) {
 					context.JSON(http.StatusBadRequest, response)
 					return
 				} else {
-					var imageUrl = ""
+					edit := ProductEdit{MainImageID: productUpdate.MainImageID}
+					if !productUpdate.Price.IsEmpty() {
+						parsed, err := ParsePriceInput(productUpdate.Price)
+						if err != nil {
+							response := models.Reply{
+								Message: "could not update product",
+								Error:   err.Error(),
+								Success: false,
+							}
+							context.JSON(http.StatusBadRequest, response)
+							return
+						}
+						edit.Price = &parsed
+					}
+
 					if productUpdate.MainImage != "" {
-						imageUrl, err = images.UploadHandler(productUpdate.ProductName, productUpdate.MainImage, context)
+						mainImage, err := images.UploadBase64Image(productUpdate.ProductName, productUpdate.MainImage)
 						if err != nil {
 							response := models.Reply{
 								Message: "could not update product",
@@ -648,22 +600,19 @@ func UpdateProduct(context *gin.Context) {
 							context.JSON(http.StatusBadRequest, response)
 							return
 						}
-					} else {
-						imageUrl = productExist.MainImage
+						edit.MainUpload = &mainImage
 					}
 
-					newproduct := Product{
+					edit.Fields = Product{
 						ProductName:        productUpdate.ProductName,
-						ProductPrice:       productUpdate.ProductPrice,
 						ProductDescription: productUpdate.ProductDescription,
-						MainImage:          imageUrl,
 						Quantity:           productUpdate.Quantity,
 						ProductType:        productUpdate.ProductType,
 						Brand:              productUpdate.Brand,
 						Category:           productUpdate.Category,
 						SubCategory:        productUpdate.SubCategory,
 					}
-					productUpdated, err := UpdateProductUtil(query, newproduct)
+					productUpdated, err := UpdateProductDetails(id, user.UserID, edit)
 
 					if err != nil {
 						response := models.Reply{
@@ -703,78 +652,142 @@ func UpdateProduct(context *gin.Context) {
 	}
 }
 func ActivateProduct(context *gin.Context) {
-	productid := context.Query("id")
-	query := "product_id=" + productid
+	user, err := users.CurrentUser(context)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "error authorizing user",
+			Success: false,
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return
+	}
 
-	id := strings.ReplaceAll(productid, "'", "")
+	product, err := ActivateOwnedProduct(user.UserID, strings.ReplaceAll(context.Query("id"), "'", ""))
+	if err != nil {
+		status := http.StatusBadRequest
+		if packages.IsQuotaError(err) {
+			status = http.StatusForbidden
+		}
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "failed in activating product",
+			Success: false,
+		}
+		context.JSON(status, response)
+		return
+	}
+	response := models.Reply{
+		Data:    product,
+		Message: "succesfully activated the product",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
 
-	// check if product exist
-	productExist, err := FindSingleProduct(id)
+// feature an ad in one of the featured slots of the seller's package
+func FeatureProduct(context *gin.Context) {
+	user, err := users.CurrentUser(context)
 	if err != nil {
 		response := models.Reply{
 			Error:   err.Error(),
-			Message: "error finding product",
+			Message: "error authorizing user",
 			Success: false,
 		}
-		context.JSON(http.StatusBadRequest, response)
+		context.JSON(http.StatusUnauthorized, response)
 		return
+	}
 
-	} else if productExist.ProductName == "" {
+	product, err := FeatureOwnedProduct(user.UserID, strings.ReplaceAll(context.Query("id"), "'", ""))
+	if err != nil {
+		status := http.StatusBadRequest
+		if packages.IsQuotaError(err) {
+			status = http.StatusForbidden
+		}
 		response := models.Reply{
-			Error:   errors.New("product does not exist").Error(),
-			Message: "the product does not exist",
+			Error:   err.Error(),
+			Message: "failed in featuring the product",
 			Success: false,
 		}
-		context.JSON(http.StatusOK, response)
+		context.JSON(status, response)
 		return
+	}
+	response := models.Reply{
+		Data:    product,
+		Message: "succesfully featured the product",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
 
-	} else if productExist.IsDeleted {
+// free the featured slot of an ad
+func UnfeatureProduct(context *gin.Context) {
+	user, err := users.CurrentUser(context)
+	if err != nil {
 		response := models.Reply{
-			Error:   errors.New("product is deleted").Error(),
-			Message: "cannot activate a deleted product!!Please restore product first",
+			Error:   err.Error(),
+			Message: "error authorizing user",
 			Success: false,
 		}
-		context.JSON(http.StatusBadRequest, response)
+		context.JSON(http.StatusUnauthorized, response)
 		return
+	}
 
-	} else if productExist.IsActive {
+	product, err := UnfeatureOwnedProduct(user.UserID, strings.ReplaceAll(context.Query("id"), "'", ""))
+	if err != nil {
+		status := http.StatusBadRequest
+		if packages.IsQuotaError(err) {
+			status = http.StatusForbidden
+		}
 		response := models.Reply{
 			Error:   err.Error(),
-			Message: "product is already active",
+			Message: "failed in unfeaturing the product",
 			Success: false,
 		}
-		context.JSON(http.StatusBadRequest, response)
+		context.JSON(status, response)
 		return
+	}
+	response := models.Reply{
+		Data:    product,
+		Message: "succesfully unfeatured the product",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
 
-	} else {
-		success, err := ActivateProductUtil(query)
-		if err != nil {
-			response := models.Reply{
-				Error:   err.Error(),
-				Message: "product is already active",
-				Success: false,
-			}
-			context.JSON(http.StatusBadRequest, response)
-			return
-		} else if !success {
-			response := models.Reply{
-				Error:   errors.New("could not activate product").Error(),
-				Message: "failed in activating product",
-				Success: false,
-			}
-			context.JSON(http.StatusBadRequest, response)
-			return
-		} else {
-			response := models.Reply{
-				Data:    productExist,
-				Message: "succesfully activated the product",
-				Success: true,
-			}
-			context.JSON(http.StatusOK, response)
-			return
+// bump an ad with one of the free bumps of the seller's package
+func BumpProduct(context *gin.Context) {
+	user, err := users.CurrentUser(context)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "error authorizing user",
+			Success: false,
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return
+	}
 
+	product, err := BumpOwnedProduct(user.UserID, strings.ReplaceAll(context.Query("id"), "'", ""))
+	if err != nil {
+		status := http.StatusBadRequest
+		if packages.IsQuotaError(err) {
+			status = http.StatusForbidden
 		}
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "failed in bumping the product",
+			Success: false,
+		}
+		context.JSON(status, response)
+		return
+	}
+	response := models.Reply{
+		Data:    product,
+		Message: "succesfully bumped the product",
+		Success: true,
 	}
+	context.JSON(http.StatusOK, response)
 }
 func DeactivateProduct(context *gin.Context) {
 	fmt.Println("deativating")
@@ -1010,7 +1023,18 @@ func FetchSingleUserProducts(context *gin.Context) {
 func FetchSingleUserAds(context *gin.Context) {
 	id := context.Query("id")
 
-	products, err := FetchSingleUserAdsUtil(strings.ReplaceAll(id, "'", ""))
+	request, err := pagination.ParseRequest(context, "date", true)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "invalid pagination parameters",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	_, page, err := FetchSingleUserAdsPage(strings.ReplaceAll(id, "'", ""), request)
 	if err != nil {
 		response := models.Reply{
 			Error:   err.Error(),
@@ -1022,7 +1046,7 @@ func FetchSingleUserAds(context *gin.Context) {
 
 	} else {
 		response := models.Reply{
-			Data:    products,
+			Data:    page,
 			Message: "single user products fetched",
 			Success: true,
 		}
@@ -1031,3 +1055,14 @@ func FetchSingleUserAds(context *gin.Context) {
 
 	}
 }
+
+// the reasons a product can be rejected for, so sellers can read the code
+// on their products
+func GetRejectionReasons(context *gin.Context) {
+	response := models.Reply{
+		Message: "rejection reasons fetched",
+		Success: true,
+		Data:    RejectionReasons,
+	}
+	context.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,89 @@
+package product
+
+import (
+	"time"
+
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/images"
+	"eleliafrika.com/backend/users"
+	"gorm.io/gorm"
+)
+
+// upload the images of a new product, then save the product, its images
+// and the seller's product count in one transaction. Files uploaded for a
+// product that could not be saved are deleted again, images uploaded in
+// advance stay pending so they can be used on the next attempt
+func CreateProduct(product Product, input AddProductInput) (Product, error) {
+	var uploaded []images.UploadedImage
+
+	// the main image counts towards the images allowed per ad
+	imageCount := 1 + len(input.ImageIDs) + len(input.ProductImages)
+	if err := checkImageQuota(database.Database, product.UserID, imageCount); err != nil {
+		return Product{}, err
+	}
+
+	uploadIDs := input.ImageIDs
+	if input.MainImageID != "" {
+		uploadIDs = append([]string{input.MainImageID}, uploadIDs...)
+	}
+	pending, err := images.FindPendingUploads(database.Database, product.UserID, uploadIDs)
+	if err != nil {
+		return Product{}, err
+	}
+
+	var mainImage images.UploadedImage
+	if input.MainImageID != "" {
+		mainImage = images.UploadedImageFrom(pending[0])
+	} else {
+		mainImage, err = images.UploadBase64Image(product.ProductName, input.MainImage)
+		if err != nil {
+			return Product{}, err
+		}
+		uploaded = append(uploaded, mainImage)
+	}
+	for _, imageString := range input.ProductImages {
+		image, err := images.UploadBase64Image(product.ProductName, imageString)
+		if err != nil {
+			deleteUploads(uploaded)
+			return Product{}, err
+		}
+		uploaded = append(uploaded, image)
+	}
+	product.MainImage = mainImage.ImageUrl
+	product.MainThumbnail = mainImage.ThumbnailUrl
+	product.MainCard = mainImage.CardUrl
+
+	err = database.Database.Transaction(func(tx *gorm.DB) error {
+		entitlements, err := reserveActiveAd(tx, product.UserID)
+		if err != nil {
+			return err
+		}
+		product.IsActive = true
+		product.ActiveUntil = entitlements.AdExpiry(time.Now())
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		for n, image := range uploaded {
+			productImage := images.NewProductImage(image, product.UserID, product.ProductID)
+			// the main image is kept with the other images so its files
+			// can be found again
+			productImage.IsMain = input.MainImageID == "" && n == 0
+			productImage.Position = n
+			if err := tx.Create(&productImage).Error; err != nil {
+				return err
+			}
+		}
+		err = images.AttachUploads(tx, product.UserID, product.ProductID, input.MainImageID, input.ImageIDs, len(uploaded))
+		if err != nil {
+			return err
+		}
+		return tx.Model(&users.User{}).
+			Where("user_id=?", product.UserID).
+			UpdateColumn("total_products", gorm.Expr("total_products + 1")).Error
+	})
+	if err != nil {
+		deleteUploads(uploaded)
+		return Product{}, err
+	}
+	return product, nil
+}
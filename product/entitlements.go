@@ -0,0 +1,83 @@
+package product
+
+import (
+	"errors"
+	"time"
+
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/packages"
+	"eleliafrika.com/backend/users"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// the seller is locked so their ads are counted and changed one at a time
+func lockSeller(tx *gorm.DB, userId string) error {
+	var seller users.User
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id=?", userId).Find(&seller).Error
+}
+
+// lock the seller and check their package allows another active ad
+func reserveActiveAd(tx *gorm.DB, userId string) (packages.Entitlements, error) {
+	if err := lockSeller(tx, userId); err != nil {
+		return packages.Entitlements{}, err
+	}
+	entitlements, packageName, err := packages.UserEntitlements(tx, userId)
+	if err != nil {
+		return packages.Entitlements{}, err
+	}
+	var active int64
+	err = tx.Model(&Product{}).Where("user_id=? AND is_active=? AND is_deleted=?", userId, true, false).Count(&active).Error
+	if err != nil {
+		return packages.Entitlements{}, err
+	}
+	return entitlements, entitlements.CheckActiveAds(packageName, active)
+}
+
+// check an ad may have this many images, the main image included
+func checkImageQuota(tx *gorm.DB, userId string, count int) error {
+	entitlements, packageName, err := packages.UserEntitlements(tx, userId)
+	if err != nil {
+		return err
+	}
+	return entitlements.CheckImages(packageName, count)
+}
+
+// activate one of the user's ads for as long as their package allows
+func ActivateOwnedProduct(userId string, productId string) (Product, error) {
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		var product Product
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("product_id=?", productId).Find(&product).Error
+		if err != nil {
+			return err
+		} else if product.ProductID == "" {
+			return errors.New("product does not exist")
+		} else if _, err := ValidateUserOwnsProduct(userId, product.UserID); err != nil {
+			return err
+		} else if product.IsDeleted {
+			return errors.New("cannot activate a deleted product, restore it first")
+		} else if product.IsActive {
+			return errors.New("product is already active")
+		}
+
+		entitlements, err := reserveActiveAd(tx, userId)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&Product{}).Where("product_id=?", productId).Updates(map[string]interface{}{
+			"is_active":    true,
+			"active_until": entitlements.AdExpiry(time.Now()),
+		}).Error
+	})
+	if err != nil {
+		return Product{}, err
+	}
+	return FindSingleProduct(productId)
+}
+
+// deactivate ads whose time allowed by the package is over
+func ExpireAds() error {
+	return database.Database.Model(&Product{}).
+		Where("is_active=? AND active_until <> '' AND active_until <= ?", true, time.Now().Format("2006-01-02 15:04:05")).
+		Update("is_active", false).Error
+}
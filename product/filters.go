@@ -0,0 +1,172 @@
+package product
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/users"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// structured filters for the ads listing. List fields match any of their
+// values and prices are whole amounts in the filter currency
+type AdFilter struct {
+	Categories    []string
+	SubCategories []string
+	Brands        []string
+	ProductTypes  []string
+	Locations     []string
+	Currency      string
+	MinPrice      *int64
+	MaxPrice      *int64
+	Featured      bool
+}
+
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// number of ads per value of each filterable field in the current result
+type Facets struct {
+	Categories    []FacetBucket `json:"categories"`
+	SubCategories []FacetBucket `json:"subcategories"`
+	Brands        []FacetBucket `json:"brands"`
+	ProductTypes  []FacetBucket `json:"producttypes"`
+	Locations     []FacetBucket `json:"locations"`
+}
+
+// read the filters from the query string, list filters are comma separated
+// e.g ?brand=samsung,apple&minprice=1000&featured=true
+func ParseAdFilter(context *gin.Context) (AdFilter, error) {
+	filter := AdFilter{
+		Categories:    splitFilterValues(context.Query("category")),
+		SubCategories: splitFilterValues(context.Query("subcategory")),
+		Brands:        splitFilterValues(context.Query("brand")),
+		ProductTypes:  splitFilterValues(context.Query("producttype")),
+		Locations:     splitFilterValues(context.Query("location")),
+	}
+
+	var err error
+	if featured := strings.TrimSpace(strings.ReplaceAll(context.Query("featured"), "'", "")); featured != "" {
+		filter.Featured, err = strconv.ParseBool(featured)
+		if err != nil {
+			return AdFilter{}, errors.New("featured should either be true or false")
+		}
+	}
+	filter.MinPrice, err = parseFilterPrice(context.Query("minprice"))
+	if err != nil {
+		return AdFilter{}, errors.New("minimum price should be a positive whole number")
+	}
+	filter.MaxPrice, err = parseFilterPrice(context.Query("maxprice"))
+	if err != nil {
+		return AdFilter{}, errors.New("maximum price should be a positive whole number")
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return AdFilter{}, errors.New("minimum price cannot be more than the maximum price")
+	}
+
+	if filter.MinPrice != nil || filter.MaxPrice != nil {
+		filter.Currency = strings.ToUpper(strings.TrimSpace(strings.ReplaceAll(context.Query("currency"), "'", "")))
+		if filter.Currency == "" {
+			filter.Currency = DefaultCurrency
+		} else if _, ok := currencyExponents[filter.Currency]; !ok {
+			return AdFilter{}, errors.New("currency " + filter.Currency + " is not supported")
+		}
+	}
+	return filter, nil
+}
+
+func splitFilterValues(raw string) []string {
+	var values []string
+	for _, value := range strings.Split(strings.ReplaceAll(raw, "'", ""), ",") {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func parseFilterPrice(raw string) (*int64, error) {
+	raw = strings.TrimSpace(strings.ReplaceAll(raw, "'", ""))
+	if raw == "" {
+		return nil, nil
+	}
+	price, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || price < 0 {
+		return nil, errors.New("invalid price")
+	}
+	return &price, nil
+}
+
+// limit a query to the ads matching the filter
+func (filter AdFilter) Scope(db *gorm.DB) *gorm.DB {
+	if len(filter.Categories) > 0 {
+		db = db.Where("lower(category) IN ?", filter.Categories)
+	}
+	if len(filter.SubCategories) > 0 {
+		db = db.Where("lower(subcategory) IN ?", filter.SubCategories)
+	}
+	if len(filter.Brands) > 0 {
+		db = db.Where("lower(brand) IN ?", filter.Brands)
+	}
+	if len(filter.ProductTypes) > 0 {
+		db = db.Where("lower(product_type) IN ?", filter.ProductTypes)
+	}
+	if filter.Featured {
+		db = db.Where("is_featured=?", true)
+	}
+	if len(filter.Locations) > 0 {
+		sellers := database.Database.Model(&users.User{}).Select("user_id").Where("lower(location) IN ?", filter.Locations)
+		db = db.Where("user_id IN (?)", sellers)
+	}
+	if filter.MinPrice != nil || filter.MaxPrice != nil {
+		db = db.Where("currency=?", filter.Currency).Where("price_on_request=?", false)
+	}
+	if filter.MinPrice != nil {
+		db = db.Where("price_minor >= ?", wholeAmountToMinor(*filter.MinPrice, filter.Currency))
+	}
+	if filter.MaxPrice != nil {
+		db = db.Where("price_minor <= ?", wholeAmountToMinor(*filter.MaxPrice, filter.Currency))
+	}
+	return db
+}
+
+// count the ads in the query by each filterable field. Values are grouped
+// lowercased, the same way the filters match them
+func FetchFacets(query *gorm.DB) (Facets, error) {
+	var facets Facets
+	fields := []struct {
+		column  string
+		buckets *[]FacetBucket
+	}{
+		{"category", &facets.Categories},
+		{"subcategory", &facets.SubCategories},
+		{"brand", &facets.Brands},
+		{"product_type", &facets.ProductTypes},
+	}
+
+	for _, field := range fields {
+		err := database.Database.Table("(?) AS facet_source", query).
+			Select("lower(" + field.column + ") AS value, count(*) AS count").
+			Group("lower(" + field.column + ")").Order("count DESC, value").
+			Scan(field.buckets).Error
+		if err != nil {
+			return Facets{}, err
+		}
+	}
+
+	err := database.Database.Table("(?) AS facet_source", query).
+		Joins("JOIN users ON users.user_id = facet_source.user_id").
+		Select("lower(users.location) AS value, count(*) AS count").
+		Group("lower(users.location)").Order("count DESC, value").
+		Scan(&facets.Locations).Error
+	if err != nil {
+		return Facets{}, err
+	}
+	return facets, nil
+}
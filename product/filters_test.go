@@ -0,0 +1,51 @@
+package product
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseAdFilter(t *testing.T) {
+	type testCase struct {
+		name    string
+		query   string
+		want    AdFilter
+		wantErr bool
+	}
+
+	thousand := int64(1000)
+	fiveThousand := int64(5000)
+
+	cases := []testCase{
+		{"no filters", "", AdFilter{}, false},
+		{"list filters are split and lowercased", "?brand=Samsung,%20apple,&location='Nairobi'", AdFilter{Brands: []string{"samsung", "apple"}, Locations: []string{"nairobi"}}, false},
+		{"price range", "?minprice=1000&maxprice=5000", AdFilter{Currency: "KES", MinPrice: &thousand, MaxPrice: &fiveThousand}, false},
+		{"price range in another currency", "?maxprice=5000&currency=usd", AdFilter{Currency: "USD", MaxPrice: &fiveThousand}, false},
+		{"unknown currency", "?maxprice=5000&currency=xyz", AdFilter{}, true},
+		{"negative price", "?minprice=-1", AdFilter{}, true},
+		{"text price", "?maxprice=cheap", AdFilter{}, true},
+		{"minimum above maximum", "?minprice=5000&maxprice=1000", AdFilter{}, true},
+		{"featured ads", "?featured=true", AdFilter{Featured: true}, false},
+		{"not only featured ads", "?featured=false", AdFilter{}, false},
+		{"featured is not a flag", "?featured=maybe", AdFilter{}, true},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, item := range cases {
+		context, _ := gin.CreateTestContext(httptest.NewRecorder())
+		context.Request = httptest.NewRequest("GET", "/"+item.query, nil)
+
+		result, err := ParseAdFilter(context)
+		if item.wantErr && err == nil {
+			t.Errorf("%s: expected an error", item.name)
+		} else if !item.wantErr && err != nil {
+			t.Errorf("%s: unexpected error %v", item.name, err)
+		} else if !reflect.DeepEqual(result, item.want) {
+			t.Errorf("%s: expected %+v but found %+v", item.name, item.want, result)
+		}
+	}
+	t.Logf("test passed")
+}
@@ -0,0 +1,198 @@
+package product
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/packages"
+	"eleliafrika.com/backend/users"
+	"github.com/gin-gonic/gin"
+)
+
+// current user and the product in the id query parameter, replies with an
+// error and returns false when the user does not own the product
+func ownedProductFromRequest(context *gin.Context) (Product, bool) {
+	user, err := users.CurrentUser(context)
+	if err != nil {
+		response := models.Reply{
+			Message: "error authorizing user",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return Product{}, false
+	} else if user.UserID == "" {
+		response := models.Reply{
+			Message: "user does not exist",
+			Error:   errors.New("user does not exist").Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return Product{}, false
+	}
+
+	product, err := FindOwnedProduct(user.UserID, strings.ReplaceAll(context.Query("id"), "'", ""))
+	if err != nil {
+		response := models.Reply{
+			Message: "product not found",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusNotFound, response)
+		return Product{}, false
+	}
+	return product, true
+}
+
+func GetProductImages(context *gin.Context) {
+	product, ok := ownedProductFromRequest(context)
+	if !ok {
+		return
+	}
+	productImages, err := FetchProductImages(product.ProductID)
+	if err != nil {
+		response := models.Reply{
+			Message: "could not get the images",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "images fetched succesfully",
+		Data:    productImages,
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+func AddImages(context *gin.Context) {
+	var input ImagesInput
+	if err := context.ShouldBindJSON(&input); err != nil {
+		response := models.Reply{
+			Message: "could not bind the images",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	product, ok := ownedProductFromRequest(context)
+	if !ok {
+		return
+	}
+
+	productImages, err := AddProductImages(product, input)
+	if err != nil {
+		status := http.StatusBadRequest
+		if packages.IsQuotaError(err) {
+			status = http.StatusForbidden
+		}
+		response := models.Reply{
+			Message: "could not add the images",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(status, response)
+		return
+	}
+	response := models.Reply{
+		Message: "images added",
+		Data:    productImages,
+		Success: true,
+	}
+	context.JSON(http.StatusCreated, response)
+}
+
+func DeleteImage(context *gin.Context) {
+	product, ok := ownedProductFromRequest(context)
+	if !ok {
+		return
+	}
+
+	err := DeleteProductImage(product, strings.ReplaceAll(context.Query("image"), "'", ""))
+	if err != nil {
+		response := models.Reply{
+			Message: "could not delete the image",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "image deleted",
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+func ReorderImages(context *gin.Context) {
+	var input ReorderImagesInput
+	if err := context.ShouldBindJSON(&input); err != nil {
+		response := models.Reply{
+			Message: "could not bind the image order",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	product, ok := ownedProductFromRequest(context)
+	if !ok {
+		return
+	}
+
+	if err := ReorderProductImages(product, input.ImageIDs); err != nil {
+		response := models.Reply{
+			Message: "could not reorder the images",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	productImages, err := FetchProductImages(product.ProductID)
+	if err != nil {
+		response := models.Reply{
+			Message: "images reordered but could not be fetched",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusInternalServerError, response)
+		return
+	}
+	response := models.Reply{
+		Message: "images reordered",
+		Data:    productImages,
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+func SetMainImageHandler(context *gin.Context) {
+	product, ok := ownedProductFromRequest(context)
+	if !ok {
+		return
+	}
+
+	updated, err := SetMainImage(product, strings.ReplaceAll(context.Query("image"), "'", ""))
+	if err != nil {
+		response := models.Reply{
+			Message: "could not change the main image",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+	response := models.Reply{
+		Message: "main image changed",
+		Data:    updated,
+		Success: true,
+	}
+	context.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,319 @@
+package product
+
+import (
+	"errors"
+	"log"
+
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/images"
+	"eleliafrika.com/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// images to add to a product, either uploaded in advance or as base64
+type ImagesInput struct {
+	ImageIDs []string `json:"imageids"`
+	Images   []string `json:"images"`
+}
+
+type ReorderImagesInput struct {
+	ImageIDs []string `json:"imageids"`
+}
+
+// columns for the smaller renditions of the main image
+func MigrateImages() error {
+	migrator := database.Database.Migrator()
+	for _, field := range []string{"MainThumbnail", "MainCard"} {
+		if !migrator.HasColumn(&Product{}, field) {
+			if err := migrator.AddColumn(&Product{}, field); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// product that the user is allowed to change the images of
+func FindOwnedProduct(userId string, productId string) (Product, error) {
+	product, err := FindSingleProduct(productId)
+	if err != nil {
+		return Product{}, err
+	} else if product.ProductID == "" || product.IsDeleted {
+		return Product{}, errors.New("product does not exist")
+	}
+	if _, err := ValidateUserOwnsProduct(userId, product.UserID); err != nil {
+		return Product{}, err
+	}
+	return product, nil
+}
+
+// main image first, then the gallery in order
+func FetchProductImages(productId string) ([]models.ProductImage, error) {
+	var productImages []models.ProductImage
+	err := database.Database.Where("product_id=?", productId).
+		Order("is_main DESC, position, id").Find(&productImages).Error
+	if err != nil {
+		return []models.ProductImage{}, err
+	}
+	return productImages, nil
+}
+
+// image of the product, locked for the rest of the transaction
+func findProductImage(tx *gorm.DB, productId string, imageId string) (models.ProductImage, error) {
+	var image models.ProductImage
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id=? AND image_id=?", productId, imageId).Find(&image).Error
+	if err != nil {
+		return models.ProductImage{}, err
+	} else if image.ImageID == "" {
+		return models.ProductImage{}, errors.New("image not found")
+	}
+	return image, nil
+}
+
+func nextImagePosition(tx *gorm.DB, productId string) (int, error) {
+	var position int
+	err := tx.Model(&models.ProductImage{}).Where("product_id=?", productId).
+		Select("coalesce(max(position), -1) + 1").Scan(&position).Error
+	return position, err
+}
+
+// check the product can take this many more images
+func checkGalleryQuota(tx *gorm.DB, product Product, adding int) error {
+	var existing int64
+	err := tx.Model(&models.ProductImage{}).Where("product_id=?", product.ProductID).Count(&existing).Error
+	if err != nil {
+		return err
+	}
+	return checkImageQuota(tx, product.UserID, int(existing)+adding)
+}
+
+func deleteUploads(uploaded []images.UploadedImage) {
+	for _, image := range uploaded {
+		if err := images.DeleteRenditions(image.ObjectKey); err != nil {
+			log.Printf("could not delete the upload %s: %v", image.ObjectKey, err)
+		}
+	}
+}
+
+// add gallery images to the end of a product's gallery and send it back for
+// review. Base64 images are uploaded first and deleted again if the images
+// could not be saved
+func AddProductImages(product Product, input ImagesInput) ([]models.ProductImage, error) {
+	if len(input.ImageIDs) == 0 && len(input.Images) == 0 {
+		return nil, errors.New("no images were sent")
+	}
+	if _, err := images.FindPendingUploads(database.Database, product.UserID, input.ImageIDs); err != nil {
+		return nil, err
+	}
+	// checked again once the product is locked, this saves uploading images
+	// that would be refused anyway
+	if err := checkGalleryQuota(database.Database, product, len(input.ImageIDs)+len(input.Images)); err != nil {
+		return nil, err
+	}
+
+	var uploaded []images.UploadedImage
+	for _, imageString := range input.Images {
+		image, err := images.UploadBase64Image(product.ProductName, imageString)
+		if err != nil {
+			deleteUploads(uploaded)
+			return nil, err
+		}
+		uploaded = append(uploaded, image)
+	}
+
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		// lock the product so two requests do not take the same positions
+		var locked Product
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("product_id=?", product.ProductID).Find(&locked).Error
+		if err != nil {
+			return err
+		}
+		if err := checkGalleryQuota(tx, product, len(input.ImageIDs)+len(uploaded)); err != nil {
+			return err
+		}
+
+		position, err := nextImagePosition(tx, product.ProductID)
+		if err != nil {
+			return err
+		}
+		err = images.AttachUploads(tx, product.UserID, product.ProductID, "", input.ImageIDs, position)
+		if err != nil {
+			return err
+		}
+		position += len(input.ImageIDs)
+		for n, image := range uploaded {
+			productImage := images.NewProductImage(image, product.UserID, product.ProductID)
+			productImage.Position = position + n
+			if err := tx.Create(&productImage).Error; err != nil {
+				return err
+			}
+		}
+		return ResubmitProduct(tx, product.ProductID, product.UserID)
+	})
+	if err != nil {
+		deleteUploads(uploaded)
+		return nil, err
+	}
+	return FetchProductImages(product.ProductID)
+}
+
+// remove a gallery image and its files and send the product back for
+// review. The main image has to be replaced before it can be deleted
+func DeleteProductImage(product Product, imageId string) error {
+	var deleted models.ProductImage
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		image, err := findProductImage(tx, product.ProductID, imageId)
+		if err != nil {
+			return err
+		} else if image.IsMain {
+			return errors.New("choose another main image before deleting this one")
+		}
+		deleted = image
+		err = tx.Unscoped().Where("image_id=?", imageId).Delete(&models.ProductImage{}).Error
+		if err != nil {
+			return err
+		}
+		return ResubmitProduct(tx, product.ProductID, product.UserID)
+	})
+	if err != nil {
+		return err
+	}
+	// the row is gone, a file that could not be deleted is only logged
+	if deleted.ObjectKey != "" {
+		deleteUploads([]images.UploadedImage{images.UploadedImageFrom(deleted)})
+	}
+	return nil
+}
+
+// put the gallery in the order of ids, every gallery image has to be listed
+func ReorderProductImages(product Product, ids []string) error {
+	return database.Database.Transaction(func(tx *gorm.DB) error {
+		var gallery []models.ProductImage
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("product_id=? AND is_main=?", product.ProductID, false).Find(&gallery).Error
+		if err != nil {
+			return err
+		}
+
+		listed := map[string]bool{}
+		for _, id := range ids {
+			listed[id] = true
+		}
+		if len(listed) != len(ids) || len(ids) != len(gallery) {
+			return errors.New("every gallery image has to be listed once")
+		}
+		for _, image := range gallery {
+			if !listed[image.ImageID] {
+				return errors.New("every gallery image has to be listed once")
+			}
+		}
+
+		for position, id := range ids {
+			err := tx.Model(&models.ProductImage{}).Where("image_id=?", id).Update("position", position).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// make a gallery image the main image and send the product back for review,
+// the old main image moves to the end of the gallery
+func SetMainImage(product Product, imageId string) (Product, error) {
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		image, err := findProductImage(tx, product.ProductID, imageId)
+		if err != nil {
+			return err
+		} else if image.IsMain {
+			return nil
+		}
+
+		position, err := nextImagePosition(tx, product.ProductID)
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&models.ProductImage{}).
+			Where("product_id=? AND is_main=?", product.ProductID, true).
+			Updates(map[string]interface{}{"is_main": false, "position": position}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&models.ProductImage{}).Where("image_id=?", imageId).Update("is_main", true).Error
+		if err != nil {
+			return err
+		}
+		if err := saveMainImage(tx, product.ProductID, image); err != nil {
+			return err
+		}
+		return ResubmitProduct(tx, product.ProductID, product.UserID)
+	})
+	if err != nil {
+		return Product{}, err
+	}
+	return FindSingleProduct(product.ProductID)
+}
+
+// copy the urls of the main image onto the product
+func saveMainImage(tx *gorm.DB, productId string, image models.ProductImage) error {
+	return tx.Model(&Product{}).Where("product_id=?", productId).
+		Updates(map[string]interface{}{
+			"main_image":     image.ImageUrl,
+			"main_thumbnail": image.ThumbnailUrl,
+			"main_card":      image.CardUrl,
+		}).Error
+}
+
+// replace the main image of a product with an upload, either one made
+// through /images/upload or one uploaded with the edit. The old main image
+// row is removed and returned so its files can be deleted once the
+// transaction is done
+func replaceMainImage(tx *gorm.DB, product Product, uploaded *images.UploadedImage, uploadId string) (models.ProductImage, error) {
+	var locked Product
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id=?", product.ProductID).Find(&locked).Error
+	if err != nil {
+		return models.ProductImage{}, err
+	}
+	var replaced models.ProductImage
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id=? AND is_main=?", product.ProductID, true).Find(&replaced).Error
+	if err != nil {
+		return models.ProductImage{}, err
+	}
+	// products from before the gallery have no row for their main image
+	adding := 1
+	if replaced.ImageID != "" {
+		adding = 0
+	}
+	if err := checkGalleryQuota(tx, product, adding); err != nil {
+		return models.ProductImage{}, err
+	}
+	if replaced.ImageID != "" {
+		err := tx.Unscoped().Where("image_id=?", replaced.ImageID).Delete(&models.ProductImage{}).Error
+		if err != nil {
+			return models.ProductImage{}, err
+		}
+	}
+
+	var image models.ProductImage
+	if uploadId != "" {
+		err := images.AttachUploads(tx, product.UserID, product.ProductID, uploadId, nil, 0)
+		if err != nil {
+			return models.ProductImage{}, err
+		}
+		if image, err = findProductImage(tx, product.ProductID, uploadId); err != nil {
+			return models.ProductImage{}, err
+		}
+	} else {
+		image = images.NewProductImage(*uploaded, product.UserID, product.ProductID)
+		image.IsMain = true
+		if err := tx.Create(&image).Error; err != nil {
+			return models.ProductImage{}, err
+		}
+	}
+	return replaced, saveMainImage(tx, product.ProductID, image)
+}
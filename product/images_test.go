@@ -0,0 +1,165 @@
+package product
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"eleliafrika.com/backend/database"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// database that records the statements it is sent and answers selects with
+// the rows of the table they read from
+type recorder struct {
+	tables     map[string][]map[string]driver.Value
+	statements []string
+}
+
+func (db *recorder) Connect(context.Context) (driver.Conn, error) { return db, nil }
+func (db *recorder) Driver() driver.Driver                        { return nil }
+
+func (db *recorder) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("statements are not prepared")
+}
+func (db *recorder) Close() error              { return nil }
+func (db *recorder) Begin() (driver.Tx, error) { return db, nil }
+func (db *recorder) Commit() error {
+	db.statements = append(db.statements, "COMMIT")
+	return nil
+}
+func (db *recorder) Rollback() error {
+	db.statements = append(db.statements, "ROLLBACK")
+	return nil
+}
+
+func (db *recorder) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	db.statements = append(db.statements, query)
+	return driver.RowsAffected(1), nil
+}
+
+func (db *recorder) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	db.statements = append(db.statements, query)
+	switch {
+	case strings.Contains(query, "count("):
+		return newRows([]map[string]driver.Value{{"count": int64(1)}}), nil
+	case strings.Contains(query, "coalesce("):
+		return newRows([]map[string]driver.Value{{"position": int64(1)}}), nil
+	}
+	for table, rows := range db.tables {
+		if strings.Contains(query, `FROM "`+table+`"`) {
+			return newRows(rows), nil
+		}
+	}
+	return newRows(nil), nil
+}
+
+type rows struct {
+	columns []string
+	values  []map[string]driver.Value
+}
+
+func newRows(values []map[string]driver.Value) *rows {
+	var columns []string
+	if len(values) > 0 {
+		for column := range values[0] {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+	}
+	return &rows{columns: columns, values: values}
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	for n, column := range r.columns {
+		dest[n] = r.values[0][column]
+	}
+	r.values = r.values[1:]
+	return nil
+}
+
+func useRecorder(t *testing.T, db *recorder) {
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(db)}), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := database.Database
+	database.Database = gormDB
+	t.Cleanup(func() { database.Database = previous })
+}
+
+func TestGalleryChangesResubmit(t *testing.T) {
+	type testCase struct {
+		name   string
+		change func(product Product) error
+	}
+	cases := []testCase{
+		{
+			name: "add images",
+			change: func(product Product) error {
+				_, err := AddProductImages(product, ImagesInput{ImageIDs: []string{"image-1"}})
+				return err
+			},
+		},
+		{
+			name: "delete an image",
+			change: func(product Product) error {
+				return DeleteProductImage(product, "image-1")
+			},
+		},
+		{
+			name: "set the main image",
+			change: func(product Product) error {
+				_, err := SetMainImage(product, "image-1")
+				return err
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			db := &recorder{tables: map[string][]map[string]driver.Value{
+				"products": {{
+					"product_id":        "product-1",
+					"user_id":           "user-1",
+					"moderation_status": ModerationApproved,
+				}},
+				"product_images": {{
+					"image_id":   "image-1",
+					"owner_id":   "user-1",
+					"product_id": "",
+					"is_main":    false,
+				}},
+			}}
+			useRecorder(t, db)
+
+			product := Product{ProductID: "product-1", UserID: "user-1"}
+			if err := c.change(product); err != nil {
+				t.Fatal(err)
+			}
+
+			resubmitted, committed := false, false
+			for _, statement := range db.statements {
+				if strings.HasPrefix(statement, `UPDATE "products"`) && strings.Contains(statement, `"moderation_status"`) {
+					resubmitted = true
+				} else if statement == "COMMIT" {
+					committed = resubmitted
+				}
+			}
+			if !committed {
+				t.Errorf("expected the approved product to go back to review with the change, statements: %v", db.statements)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package product
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// where a product is in moderation. Only approved products are shown to
+// buyers, IsApproved is kept in step for the queries that read it
+const (
+	ModerationPending          = "pending"
+	ModerationApproved         = "approved"
+	ModerationRejected         = "rejected"
+	ModerationChangesRequested = "changes_requested"
+
+	ActionApprove      = "approve"
+	ActionReject       = "reject"
+	ActionRequestEdits = "request_edits"
+	ActionResubmit     = "resubmit"
+
+	ActorAdmin  = "admin"
+	ActorSeller = "seller"
+
+	maxModerationNote = 1000
+)
+
+// a reason a product can be rejected for, the code is stored and the label
+// shown to the seller
+type RejectionReason struct {
+	Code  string `json:"code"`
+	Label string `json:"label"`
+}
+
+var RejectionReasons = []RejectionReason{
+	{"prohibited", "The item is not allowed on the site"},
+	{"misleading", "The title, description or price is misleading"},
+	{"images", "The images are missing, unclear or not of the item"},
+	{"category", "The ad is in the wrong category"},
+	{"duplicate", "The ad repeats another ad"},
+	{"contact", "The ad has contact details or outside links in it"},
+	{"other", "Another reason, see the note"},
+}
+
+// one step in the moderation of a product
+type ProductModeration struct {
+	gorm.Model
+	ModerationID string `gorm:"column:moderation_id;not null;unique" json:"moderationid"`
+	ProductID    string `gorm:"column:product_id;not null;index" json:"productid"`
+	ActorID      string `gorm:"column:actor_id;not null" json:"actorid"`
+	ActorType    string `gorm:"column:actor_type;not null" json:"actortype"`
+	Action       string `gorm:"column:action;not null" json:"action"`
+	FromStatus   string `gorm:"column:from_status;not null" json:"fromstatus"`
+	ToStatus     string `gorm:"column:to_status;not null" json:"tostatus"`
+	ReasonCode   string `gorm:"column:reason_code;not null;default:''" json:"reasoncode"`
+	Note         string `gorm:"column:note;not null;default:''" json:"note"`
+}
+
+type ModerationInput struct {
+	Action     string `json:"action"`
+	ReasonCode string `json:"reasoncode"`
+	Note       string `json:"note"`
+}
+
+// the queue is sorted by how long products have waited. Products that were
+// never sent back have waited since they were created
+var queueSorts = map[string]pagination.Sort[Product]{
+	"date": {
+		Column: "coalesce(pending_since, created_at)",
+		Value: func(product Product) interface{} {
+			if product.PendingSince != nil {
+				return *product.PendingSince
+			}
+			return product.CreatedAt
+		},
+	},
+	"price": productSorts["price"],
+	"likes": productSorts["likes"],
+}
+
+// add the moderation columns, products approved before them are marked
+// approved and the rest wait in the queue
+func MigrateModeration() error {
+	migrator := database.Database.Migrator()
+	addingStatus := !migrator.HasColumn(&Product{}, "ModerationStatus")
+	for _, field := range []string{"ModerationStatus", "ModerationReason", "ModerationNote", "PendingSince"} {
+		if !migrator.HasColumn(&Product{}, field) {
+			if err := migrator.AddColumn(&Product{}, field); err != nil {
+				return err
+			}
+		}
+	}
+	if addingStatus {
+		err := database.Database.Exec("UPDATE products SET moderation_status = CASE WHEN is_approved THEN ? ELSE ? END",
+			ModerationApproved, ModerationPending).Error
+		if err != nil {
+			return err
+		}
+	}
+	return database.Database.AutoMigrate(&ProductModeration{})
+}
+
+func isRejectionReason(code string) bool {
+	for _, reason := range RejectionReasons {
+		if reason.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// the status a moderation action moves a product to. Rejections need a
+// known reason and a note when the reason is other, edits need a note
+// saying what to change
+func moderationTarget(input *ModerationInput) (string, error) {
+	input.Action = strings.TrimSpace(strings.ToLower(input.Action))
+	input.ReasonCode = strings.TrimSpace(strings.ToLower(input.ReasonCode))
+	input.Note = strings.TrimSpace(input.Note)
+	if utf8.RuneCountInString(input.Note) > maxModerationNote {
+		return "", fmt.Errorf("the note should be at most %d characters long", maxModerationNote)
+	}
+
+	switch input.Action {
+	case ActionApprove:
+		input.ReasonCode = ""
+		return ModerationApproved, nil
+	case ActionReject:
+		if !isRejectionReason(input.ReasonCode) {
+			return "", errors.New("give one of the rejection reasons")
+		} else if input.ReasonCode == "other" && input.Note == "" {
+			return "", errors.New("add a note explaining the rejection")
+		}
+		return ModerationRejected, nil
+	case ActionRequestEdits:
+		if input.Note == "" {
+			return "", errors.New("add a note saying what should be changed")
+		}
+		input.ReasonCode = ""
+		return ModerationChangesRequested, nil
+	}
+	return "", errors.New("action should be approve, reject or request_edits")
+}
+
+func lockProduct(tx *gorm.DB, productId string) (Product, error) {
+	var product Product
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("product_id=?", productId).Find(&product).Error
+	if err != nil {
+		return Product{}, err
+	} else if product.ProductID == "" {
+		return Product{}, errors.New("product does not exist")
+	}
+	return product, nil
+}
+
+// move a product to a status and add the step to its history
+func setModeration(tx *gorm.DB, product *Product, step ProductModeration) error {
+	step.ProductID = product.ProductID
+	step.FromStatus = product.ModerationStatus
+	changes := map[string]interface{}{
+		"moderation_status": step.ToStatus,
+		"moderation_reason": step.ReasonCode,
+		"moderation_note":   step.Note,
+		"is_approved":       step.ToStatus == ModerationApproved,
+	}
+	// products that go back to the queue wait from now, not from when they
+	// were created
+	if step.ToStatus == ModerationPending {
+		now := time.Now()
+		changes["pending_since"] = now
+		product.PendingSince = &now
+	}
+	err := tx.Model(&Product{}).Where("product_id=?", product.ProductID).Updates(changes).Error
+	if err != nil {
+		return err
+	}
+	product.ModerationStatus = step.ToStatus
+	product.ModerationReason = step.ReasonCode
+	product.ModerationNote = step.Note
+	product.IsApproved = step.ToStatus == ModerationApproved
+	return recordModeration(tx, step)
+}
+
+// add a step to the history of a product
+func recordModeration(tx *gorm.DB, step ProductModeration) error {
+	step.ModerationID = uuid.New().String()
+	return tx.Create(&step).Error
+}
+
+// approve, reject or ask for edits on a product
+func ModerateProduct(productId string, adminId string, input ModerationInput) (Product, error) {
+	target, err := moderationTarget(&input)
+	if err != nil {
+		return Product{}, err
+	}
+	var product Product
+	err = database.Database.Transaction(func(tx *gorm.DB) error {
+		product, err = lockProduct(tx, productId)
+		if err != nil {
+			return err
+		} else if product.IsDeleted {
+			return errors.New("cannot moderate a deleted product, restore it first")
+		} else if product.ModerationStatus == target {
+			return errors.New("product is already " + strings.ReplaceAll(target, "_", " "))
+		}
+		return setModeration(tx, &product, ProductModeration{
+			ActorID:    adminId,
+			ActorType:  ActorAdmin,
+			Action:     input.Action,
+			ToStatus:   target,
+			ReasonCode: input.ReasonCode,
+			Note:       input.Note,
+		})
+	})
+	if err != nil {
+		return Product{}, err
+	}
+	return product, nil
+}
+
+// whether a seller's edit sends a product in the status back to the queue.
+// Approved products are checked again so an edit cannot slip past review
+func resubmits(status string) bool {
+	return status == ModerationApproved || status == ModerationRejected || status == ModerationChangesRequested
+}
+
+// put a product back in the queue once the seller changes it, inside the
+// transaction that saves the change
+func ResubmitProduct(tx *gorm.DB, productId string, userId string) error {
+	product, err := lockProduct(tx, productId)
+	if err != nil {
+		return err
+	} else if !resubmits(product.ModerationStatus) {
+		return nil
+	}
+	return setModeration(tx, &product, ProductModeration{
+		ActorID:   userId,
+		ActorType: ActorSeller,
+		Action:    ActionResubmit,
+		ToStatus:  ModerationPending,
+	})
+}
+
+// products waiting for a moderator, the ones waiting longest first by
+// default
+func FetchModerationQueuePage(request pagination.Request) ([]Product, pagination.Page, error) {
+	query := database.Database.Model(&Product{}).Where("moderation_status=? AND is_deleted=?", ModerationPending, false)
+	return pagination.Fetch(query, request, queueSorts, productID)
+}
+
+func FetchModerationHistory(productId string) ([]ProductModeration, error) {
+	var history []ProductModeration
+	err := database.Database.Where("product_id=?", productId).Order("created_at, id").Find(&history).Error
+	if err != nil {
+		return []ProductModeration{}, err
+	}
+	return history, nil
+}
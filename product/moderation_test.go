@@ -0,0 +1,90 @@
+package product
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestModerationTarget(t *testing.T) {
+	type testCase struct {
+		name       string
+		input      ModerationInput
+		wantStatus string
+		wantReason string
+		wantErr    bool
+	}
+	cases := []testCase{
+		{"approve", ModerationInput{Action: "approve"}, ModerationApproved, "", false},
+		{"approve drops the reason", ModerationInput{Action: "Approve", ReasonCode: "images"}, ModerationApproved, "", false},
+		{"reject with a reason", ModerationInput{Action: "reject", ReasonCode: " Images "}, ModerationRejected, "images", false},
+		{"reject without a reason", ModerationInput{Action: "reject"}, "", "", true},
+		{"reject with an unknown reason", ModerationInput{Action: "reject", ReasonCode: "ugly"}, "", "", true},
+		{"other needs a note", ModerationInput{Action: "reject", ReasonCode: "other"}, "", "", true},
+		{"other with a note", ModerationInput{Action: "reject", ReasonCode: "other", Note: "sold elsewhere"}, ModerationRejected, "other", false},
+		{"edits need a note", ModerationInput{Action: "request_edits", Note: "  "}, "", "", true},
+		{"edits with a note", ModerationInput{Action: "request_edits", Note: "add the size"}, ModerationChangesRequested, "", false},
+		{"note too long", ModerationInput{Action: "request_edits", Note: strings.Repeat("a", maxModerationNote+1)}, "", "", true},
+		{"unknown action", ModerationInput{Action: "delete"}, "", "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			input := c.input
+			status, err := moderationTarget(&input)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error=%v got %v", c.wantErr, err)
+			} else if err != nil {
+				return
+			}
+			if status != c.wantStatus {
+				t.Errorf("expected status %s got %s", c.wantStatus, status)
+			}
+			if input.ReasonCode != c.wantReason {
+				t.Errorf("expected reason %q got %q", c.wantReason, input.ReasonCode)
+			}
+		})
+	}
+}
+
+func TestResubmits(t *testing.T) {
+	type testCase struct {
+		status string
+		want   bool
+	}
+	cases := []testCase{
+		{ModerationPending, false},
+		{ModerationApproved, true},
+		{ModerationRejected, true},
+		{ModerationChangesRequested, true},
+	}
+
+	for _, c := range cases {
+		if got := resubmits(c.status); got != c.want {
+			t.Errorf("%s: expected %v got %v", c.status, c.want, got)
+		}
+	}
+}
+
+func TestQueueWaitingSince(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	resubmitted := created.Add(48 * time.Hour)
+
+	type testCase struct {
+		name    string
+		product Product
+		want    time.Time
+	}
+	cases := []testCase{
+		{"never sent back", Product{Model: gorm.Model{CreatedAt: created}}, created},
+		{"sent back", Product{Model: gorm.Model{CreatedAt: created}, PendingSince: &resubmitted}, resubmitted},
+	}
+
+	for _, c := range cases {
+		if got := queueSorts["date"].Value(c.product); got != c.want {
+			t.Errorf("%s: expected %v got %v", c.name, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package product
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"eleliafrika.com/backend/database"
+)
+
+const DefaultCurrency = "KES"
+
+// number of decimal places each accepted currency is priced in
+var currencyExponents = map[string]int{
+	"KES": 2,
+	"TZS": 2,
+	"UGX": 0,
+	"RWF": 0,
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+}
+
+// price as sent by the seller with amounts in major units e.g "1500.50"
+type PriceInput struct {
+	Amount          string `json:"amount"`
+	Currency        string `json:"currency"`
+	IsNegotiable    bool   `json:"isnegotiable"`
+	PriceOnRequest  bool   `json:"priceonrequest"`
+	CompareAtAmount string `json:"compareatamount"`
+}
+
+// price with amounts in minor units of the currency e.g cents
+type Price struct {
+	Minor          int64
+	Currency       string
+	IsNegotiable   bool
+	PriceOnRequest bool
+	CompareAtMinor int64
+}
+
+var amountPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// convert an amount in major units into minor units of the currency
+func ParseAmount(amount string, currency string) (int64, error) {
+	exponent, ok := currencyExponents[currency]
+	if !ok {
+		return 0, errors.New("currency " + currency + " is not supported")
+	}
+	amount = strings.TrimSpace(amount)
+	if !amountPattern.MatchString(amount) {
+		return 0, errors.New("price can only contain numbers")
+	}
+
+	whole, fraction, _ := strings.Cut(amount, ".")
+	if len(fraction) > exponent {
+		return 0, errors.New("price has too many decimal places for " + currency)
+	}
+	digits := whole + fraction + strings.Repeat("0", exponent-len(fraction))
+	minor, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, errors.New("price is too large")
+	}
+	return minor, nil
+}
+
+// whether no part of the price was sent
+func (input PriceInput) IsEmpty() bool {
+	return strings.TrimSpace(input.Amount) == "" && strings.TrimSpace(input.Currency) == "" &&
+		!input.IsNegotiable && !input.PriceOnRequest && strings.TrimSpace(input.CompareAtAmount) == ""
+}
+
+func ParsePriceInput(input PriceInput) (Price, error) {
+	price := Price{
+		Currency:       strings.ToUpper(strings.TrimSpace(input.Currency)),
+		IsNegotiable:   input.IsNegotiable,
+		PriceOnRequest: input.PriceOnRequest,
+	}
+	if price.Currency == "" {
+		price.Currency = DefaultCurrency
+	}
+
+	var err error
+	if strings.TrimSpace(input.Amount) != "" {
+		price.Minor, err = ParseAmount(input.Amount, price.Currency)
+		if err != nil {
+			return Price{}, err
+		}
+	}
+	if price.Minor <= 0 && !price.PriceOnRequest {
+		return Price{}, errors.New("price invalid")
+	}
+
+	if strings.TrimSpace(input.CompareAtAmount) != "" {
+		price.CompareAtMinor, err = ParseAmount(input.CompareAtAmount, price.Currency)
+		if err != nil {
+			return Price{}, err
+		} else if price.CompareAtMinor <= price.Minor {
+			return Price{}, errors.New("old price should be more than the current price")
+		}
+	}
+	return price, nil
+}
+
+// convert a whole amount in major units of the currency into minor units
+func wholeAmountToMinor(amount int64, currency string) int64 {
+	for i := 0; i < currencyExponents[currency]; i++ {
+		amount *= 10
+	}
+	return amount
+}
+
+// add the numeric price columns and carry over the old text prices
+func MigratePrices() error {
+	migrator := database.Database.Migrator()
+	for _, field := range []string{"PriceMinor", "Currency", "IsNegotiable", "PriceOnRequest", "CompareAtPriceMinor"} {
+		if !migrator.HasColumn(&Product{}, field) {
+			if err := migrator.AddColumn(&Product{}, field); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !migrator.HasColumn(&Product{}, "product_price") {
+		return nil
+	}
+	err := database.Database.Exec(`UPDATE products SET price_minor = round(CAST(product_price AS numeric) * 100)
+		WHERE price_minor = 0 AND product_price ~ '^[0-9]+(\.[0-9]+)?$'`).Error
+	if err != nil {
+		return err
+	}
+	return database.Database.Exec("ALTER TABLE products ALTER COLUMN product_price DROP NOT NULL").Error
+}
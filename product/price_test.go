@@ -0,0 +1,93 @@
+package product
+
+import "testing"
+
+func TestParseAmount(t *testing.T) {
+	type testCase struct {
+		name     string
+		amount   string
+		currency string
+		want     int64
+		wantErr  bool
+	}
+
+	cases := []testCase{
+		{"whole shillings", "1500", "KES", 150000, false},
+		{"shillings and cents", "1500.5", "KES", 150050, false},
+		{"currency without minor units", "20000", "UGX", 20000, false},
+		{"decimals on currency without minor units", "20000.50", "UGX", 0, true},
+		{"too many decimals", "10.505", "KES", 0, true},
+		{"letters", "12k", "KES", 0, true},
+		{"negative", "-10", "KES", 0, true},
+		{"unknown currency", "10", "XYZ", 0, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ParseAmount(c.amount, c.currency)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v got %v", c.wantErr, err)
+			}
+			if got != c.want {
+				t.Errorf("expected %d got %d", c.want, got)
+			}
+		})
+	}
+}
+
+func TestParsePriceInput(t *testing.T) {
+	type testCase struct {
+		name    string
+		input   PriceInput
+		want    Price
+		wantErr bool
+	}
+
+	cases := []testCase{
+		{"defaults to shillings", PriceInput{Amount: "200"}, Price{Minor: 20000, Currency: "KES"}, false},
+		{"currency is uppercased", PriceInput{Amount: "5", Currency: "usd"}, Price{Minor: 500, Currency: "USD"}, false},
+		{"negotiable with old price", PriceInput{Amount: "200", IsNegotiable: true, CompareAtAmount: "250"}, Price{Minor: 20000, Currency: "KES", IsNegotiable: true, CompareAtMinor: 25000}, false},
+		{"price on request without amount", PriceInput{PriceOnRequest: true}, Price{Currency: "KES", PriceOnRequest: true}, false},
+		{"missing amount", PriceInput{}, Price{}, true},
+		{"zero amount", PriceInput{Amount: "0"}, Price{}, true},
+		{"old price below price", PriceInput{Amount: "200", CompareAtAmount: "150"}, Price{}, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ParsePriceInput(c.input)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v got %v", c.wantErr, err)
+			}
+			if got != c.want {
+				t.Errorf("expected %+v got %+v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestPriceInputIsEmpty(t *testing.T) {
+	type testCase struct {
+		name  string
+		input PriceInput
+		want  bool
+	}
+
+	cases := []testCase{
+		{"nothing sent", PriceInput{}, true},
+		{"blank amount", PriceInput{Amount: "  "}, true},
+		{"amount", PriceInput{Amount: "200"}, false},
+		{"currency only", PriceInput{Currency: "USD"}, false},
+		{"price on request", PriceInput{PriceOnRequest: true}, false},
+		{"negotiable", PriceInput{IsNegotiable: true}, false},
+		{"old price only", PriceInput{CompareAtAmount: "300"}, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.input.IsEmpty(); got != c.want {
+				t.Errorf("expected %v got %v", c.want, got)
+			}
+		})
+	}
+}
@@ -1,48 +1,72 @@
 package product
 
 import (
+	"time"
+
 	"eleliafrika.com/backend/database"
 	"gorm.io/gorm"
 )
 
 type Product struct {
 	gorm.Model
-	ProductID          string `gorm:"column:product_id;not null;primary key;unique;" json:"producttid"`
-	ProductName        string `gorm:"column:product_name;not null" json:"productname"`
-	ProductPrice       string `gorm:"column:product_price;not null" json:"productprice"`
-	ProductDescription string `gorm:"column:product_description;" json:"productdescription"`
-	UserID             string `gorm:"size:255;not null;" json:"userid"`
-	MainImage          string `gorm:"type:text;size:65535;" json:"mainimage"`
-	IsSuspended        bool   `gorm:"column:is_suspended;default:false;not null;" json:"issuspended"`
-	IsApproved         bool   `gorm:"column:is_approved;default:false;not null;" json:"isapproved"`
-	Quantity           int    `gorm:"default:0" json:"quantity"`
-	IsActive           bool   `gorm:"column:is_active;default:true" json:"isactive"`
-	IsDeleted          bool   `gorm:"column:is_deleted;default:false" json:"isdeleted"`
-	ActiveUntil        string `gorm:"column:active_until" json:"activeuntil"`
-	ProductType        string `gorm:"column:product_type;" json:"producttype"`
-	TotalLikes         int    `gorm:"default:0" json:"totallikes"`
-	TotalComments      int    `gorm:"default:0" json:"totalcomments"`
-	DateAdded          string `gorm:"" json:"dateadded"`
-	LastUpdated        string `gorm:"size:255;not null" json:"lastupdated"`
-	LatestInteractions string `gorm:"size:255;not null" json:"latestinteractions"`
-	TotalInteractions  int    `gorm:"size:255;not null" json:"totalinteractions"`
-	TotalBookmarks     int    `gorm:"size:255;not null" json:"totalbookmarks"`
-	Brand              string `gorm:"column:brand" json:"brand"`
-	Category           string `gorm:"category" json:"category"`
-	SubCategory        string `gorm:"column:subcategory" json:"subcategory"`
+	ProductID           string     `gorm:"column:product_id;not null;primary key;unique;" json:"producttid"`
+	ProductName         string     `gorm:"column:product_name;not null" json:"productname"`
+	PriceMinor          int64      `gorm:"column:price_minor;not null;default:0" json:"priceminor"`
+	Currency            string     `gorm:"column:currency;size:3;not null;default:'KES'" json:"currency"`
+	IsNegotiable        bool       `gorm:"column:is_negotiable;not null;default:false" json:"isnegotiable"`
+	PriceOnRequest      bool       `gorm:"column:price_on_request;not null;default:false" json:"priceonrequest"`
+	CompareAtPriceMinor int64      `gorm:"column:compare_at_price_minor;not null;default:0" json:"compareatpriceminor"`
+	ProductDescription  string     `gorm:"column:product_description;" json:"productdescription"`
+	UserID              string     `gorm:"size:255;not null;" json:"userid"`
+	MainImage           string     `gorm:"type:text;size:65535;" json:"mainimage"`
+	MainThumbnail       string     `gorm:"column:main_thumbnail;type:text" json:"mainthumbnail"`
+	MainCard            string     `gorm:"column:main_card;type:text" json:"maincard"`
+	IsSuspended         bool       `gorm:"column:is_suspended;default:false;not null;" json:"issuspended"`
+	SuspensionReason    string     `gorm:"column:suspension_reason;not null;default:''" json:"suspensionreason"`
+	SuspendedAt         *time.Time `gorm:"column:suspended_at" json:"suspendedat"`
+	SuspendedUntil      *time.Time `gorm:"column:suspended_until;index" json:"suspendeduntil"`
+	SuspendedBy         string     `gorm:"column:suspended_by;not null;default:''" json:"suspendedby"`
+	IsApproved          bool       `gorm:"column:is_approved;default:false;not null;" json:"isapproved"`
+	ModerationStatus    string     `gorm:"column:moderation_status;not null;default:'pending';index" json:"moderationstatus"`
+	ModerationReason    string     `gorm:"column:moderation_reason;not null;default:''" json:"moderationreason"`
+	ModerationNote      string     `gorm:"column:moderation_note;not null;default:''" json:"moderationnote"`
+	PendingSince        *time.Time `gorm:"column:pending_since" json:"pendingsince"`
+	Quantity            int        `gorm:"default:0" json:"quantity"`
+	IsActive            bool       `gorm:"column:is_active;default:true" json:"isactive"`
+	IsDeleted           bool       `gorm:"column:is_deleted;default:false" json:"isdeleted"`
+	IsFeatured          bool       `gorm:"column:is_featured;not null;default:false;index" json:"isfeatured"`
+	FeaturedAt          *time.Time `gorm:"column:featured_at" json:"featuredat"`
+	BumpedAt            *time.Time `gorm:"column:bumped_at" json:"bumpedat"`
+	ActiveUntil         string     `gorm:"column:active_until" json:"activeuntil"`
+	ProductType         string     `gorm:"column:product_type;" json:"producttype"`
+	TotalLikes          int        `gorm:"default:0" json:"totallikes"`
+	TotalComments       int        `gorm:"default:0" json:"totalcomments"`
+	DateAdded           string     `gorm:"" json:"dateadded"`
+	LastUpdated         string     `gorm:"size:255;not null" json:"lastupdated"`
+	LatestInteractions  string     `gorm:"size:255;not null" json:"latestinteractions"`
+	TotalInteractions   int        `gorm:"size:255;not null" json:"totalinteractions"`
+	TotalBookmarks      int        `gorm:"size:255;not null" json:"totalbookmarks"`
+	Brand               string     `gorm:"column:brand" json:"brand"`
+	Category            string     `gorm:"category" json:"category"`
+	SubCategory         string     `gorm:"column:subcategory" json:"subcategory"`
+	SearchRank          float64    `gorm:"->;-:migration;column:search_rank" json:"-"`
 }
 
 type AddProductInput struct {
-	ProductName        string   `gorm:"column:product_name;unique;not null" json:"productname"`
-	ProductPrice       string   `gorm:"column:product_price;not null" json:"productprice"`
-	ProductDescription string   `gorm:"column:product_description;" json:"productdescription"`
-	MainImage          string   `gorm:"not null;" json:"mainimage"`
-	ProductImages      []string `gorm:"type:text[]" json:"productimages"`
-	Quantity           int      `gorm:"default:0" json:"quantity"`
-	ProductType        string   `gorm:"column:product_type;" json:"producttype"`
-	Brand              string   `gorm:"column:brand" json:"brand"`
-	Category           string   `gorm:"category" json:"category"`
-	SubCategory        string   `gorm:"column:subcategory" json:"subcategory"`
+	ProductName        string     `gorm:"column:product_name;unique;not null" json:"productname"`
+	Price              PriceInput `json:"price"`
+	ProductDescription string     `gorm:"column:product_description;" json:"productdescription"`
+	MainImage          string     `gorm:"not null;" json:"mainimage"`
+	ProductImages      []string   `gorm:"type:text[]" json:"productimages"`
+	Quantity           int        `gorm:"default:0" json:"quantity"`
+	ProductType        string     `gorm:"column:product_type;" json:"producttype"`
+	Brand              string     `gorm:"column:brand" json:"brand"`
+	Category           string     `gorm:"category" json:"category"`
+	SubCategory        string     `gorm:"column:subcategory" json:"subcategory"`
+	// ids of images uploaded through /images/upload, used instead of the
+	// base64 images
+	MainImageID string   `json:"mainimageid"`
+	ImageIDs    []string `json:"imageids"`
 }
 
 func (product *Product) Save() (*Product, error) {
@@ -0,0 +1,214 @@
+package product
+
+import (
+	"errors"
+	"time"
+
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/packages"
+	"eleliafrika.com/backend/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// a bump of an ad back to the top of the listings, kept to count the free
+// bumps a seller has used this month
+type AdBump struct {
+	gorm.Model
+	BumpID    string `gorm:"column:bump_id;not null;unique" json:"bumpid"`
+	ProductID string `gorm:"column:product_id;not null;index" json:"productid"`
+	UserID    string `gorm:"column:user_id;not null;index" json:"userid"`
+}
+
+// ads listings show bumped ads with the newest ads
+var adSorts = map[string]pagination.Sort[Product]{
+	"date": {
+		Column: "coalesce(bumped_at, created_at)",
+		Value: func(product Product) interface{} {
+			if product.BumpedAt != nil {
+				return *product.BumpedAt
+			}
+			return product.CreatedAt
+		},
+	},
+	"price": productSorts["price"],
+	"likes": productSorts["likes"],
+}
+
+// add the featured and bump columns and the bumps table
+func MigratePromotions() error {
+	migrator := database.Database.Migrator()
+	for _, field := range []string{"IsFeatured", "FeaturedAt", "BumpedAt"} {
+		if !migrator.HasColumn(&Product{}, field) {
+			if err := migrator.AddColumn(&Product{}, field); err != nil {
+				return err
+			}
+		}
+	}
+	return database.Database.AutoMigrate(&AdBump{})
+}
+
+// free bumps are counted from the start of the month
+func monthStart(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+}
+
+// product of the user, locked for the rest of the transaction
+func lockOwnedProduct(tx *gorm.DB, userId string, productId string) (Product, error) {
+	var product Product
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("product_id=?", productId).Find(&product).Error
+	if err != nil {
+		return Product{}, err
+	} else if product.ProductID == "" {
+		return Product{}, errors.New("product does not exist")
+	} else if _, err := ValidateUserOwnsProduct(userId, product.UserID); err != nil {
+		return Product{}, err
+	}
+	return product, nil
+}
+
+// feature one of the user's ads if their package has a featured slot left
+func FeatureOwnedProduct(userId string, productId string) (Product, error) {
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		product, err := lockOwnedProduct(tx, userId, productId)
+		if err != nil {
+			return err
+		} else if product.IsDeleted {
+			return errors.New("cannot feature a deleted product, restore it first")
+		} else if product.IsFeatured {
+			return errors.New("product is already featured")
+		}
+
+		if err := lockSeller(tx, userId); err != nil {
+			return err
+		}
+		entitlements, packageName, err := packages.UserEntitlements(tx, userId)
+		if err != nil {
+			return err
+		}
+		var featured int64
+		err = tx.Model(&Product{}).Where("user_id=? AND is_featured=?", userId, true).Count(&featured).Error
+		if err != nil {
+			return err
+		} else if err := entitlements.CheckFeatured(packageName, featured); err != nil {
+			return err
+		}
+		return tx.Model(&Product{}).Where("product_id=?", productId).Updates(map[string]interface{}{
+			"is_featured": true,
+			"featured_at": time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return Product{}, err
+	}
+	return FindSingleProduct(productId)
+}
+
+// give the featured slot of an ad back
+func UnfeatureOwnedProduct(userId string, productId string) (Product, error) {
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		product, err := lockOwnedProduct(tx, userId, productId)
+		if err != nil {
+			return err
+		} else if !product.IsFeatured {
+			return errors.New("product is not featured")
+		}
+		return tx.Model(&Product{}).Where("product_id=?", productId).Updates(map[string]interface{}{
+			"is_featured": false,
+			"featured_at": nil,
+		}).Error
+	})
+	if err != nil {
+		return Product{}, err
+	}
+	return FindSingleProduct(productId)
+}
+
+// move a showing ad back to the top of the listings with one of the free
+// bumps of the month
+func BumpOwnedProduct(userId string, productId string) (Product, error) {
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		product, err := lockOwnedProduct(tx, userId, productId)
+		if err != nil {
+			return err
+		} else if product.IsDeleted || !product.IsActive || !product.IsApproved || product.IsSuspended {
+			return errors.New("only ads that are showing can be bumped")
+		}
+
+		if err := lockSeller(tx, userId); err != nil {
+			return err
+		}
+		entitlements, packageName, err := packages.UserEntitlements(tx, userId)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		var used int64
+		err = tx.Model(&AdBump{}).Where("user_id=? AND created_at >= ?", userId, monthStart(now)).Count(&used).Error
+		if err != nil {
+			return err
+		} else if err := entitlements.CheckBumps(packageName, used); err != nil {
+			return err
+		}
+		err = tx.Model(&Product{}).Where("product_id=?", productId).Update("bumped_at", now).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&AdBump{BumpID: uuid.New().String(), ProductID: productId, UserID: userId}).Error
+	})
+	if err != nil {
+		return Product{}, err
+	}
+	return FindSingleProduct(productId)
+}
+
+// the ads featured past the slots of a package, the ones featured last
+// lose their slot first
+func overFeatured(featured []Product, slots int) []string {
+	var ids []string
+	for n, product := range featured {
+		if n >= slots {
+			ids = append(ids, product.ProductID)
+		}
+	}
+	return ids
+}
+
+// unfeature the ads of sellers whose package no longer has the slots for
+// them, after a subscription ends or a package is changed
+func TrimFeaturedAds() error {
+	var sellers []string
+	err := database.Database.Model(&Product{}).Where("is_featured=?", true).Distinct().Pluck("user_id", &sellers).Error
+	if err != nil {
+		return err
+	}
+	for _, userId := range sellers {
+		err := database.Database.Transaction(func(tx *gorm.DB) error {
+			if err := lockSeller(tx, userId); err != nil {
+				return err
+			}
+			entitlements, _, err := packages.UserEntitlements(tx, userId)
+			if err != nil {
+				return err
+			}
+			var featured []Product
+			err = tx.Where("user_id=? AND is_featured=?", userId, true).Order("featured_at, id").Find(&featured).Error
+			if err != nil {
+				return err
+			}
+			ids := overFeatured(featured, entitlements.FeaturedSlots)
+			if len(ids) == 0 {
+				return nil
+			}
+			return tx.Model(&Product{}).Where("product_id IN ?", ids).Updates(map[string]interface{}{
+				"is_featured": false,
+				"featured_at": nil,
+			}).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
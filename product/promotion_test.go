@@ -0,0 +1,89 @@
+package product
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+	"time"
+
+	"eleliafrika.com/backend/packages"
+	"gorm.io/gorm"
+)
+
+func TestMonthStart(t *testing.T) {
+	now := time.Date(2024, 3, 17, 15, 4, 5, 0, time.UTC)
+	if got := monthStart(now); !got.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected month start %v", got)
+	}
+}
+
+func TestOverFeatured(t *testing.T) {
+	featured := []Product{{ProductID: "first"}, {ProductID: "second"}, {ProductID: "third"}}
+
+	type testCase struct {
+		name  string
+		slots int
+		want  []string
+	}
+	cases := []testCase{
+		{"room for all", 3, nil},
+		{"one slot lost", 2, []string{"third"}},
+		{"no slots", 0, []string{"first", "second", "third"}},
+	}
+
+	for _, c := range cases {
+		if got := overFeatured(featured, c.slots); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: expected %v got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestAdsBumpedDate(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bumped := created.Add(72 * time.Hour)
+
+	type testCase struct {
+		name    string
+		product Product
+		want    time.Time
+	}
+	cases := []testCase{
+		{"never bumped", Product{Model: gorm.Model{CreatedAt: created}}, created},
+		{"bumped", Product{Model: gorm.Model{CreatedAt: created}, BumpedAt: &bumped}, bumped},
+	}
+
+	for _, c := range cases {
+		if got := adSorts["date"].Value(c.product); got != c.want {
+			t.Errorf("%s: expected %v got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestBasicPackageCannotPromote(t *testing.T) {
+	type testCase struct {
+		name    string
+		promote func(userId string, productId string) (Product, error)
+	}
+	cases := []testCase{
+		{"feature", FeatureOwnedProduct},
+		{"bump", BumpOwnedProduct},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// no subscription is found so the seller is on the basic package
+			useRecorder(t, &recorder{tables: map[string][]map[string]driver.Value{
+				"products": {{
+					"product_id":  "product-1",
+					"user_id":     "user-1",
+					"is_active":   true,
+					"is_approved": true,
+				}},
+			}})
+
+			if _, err := c.promote("user-1", "product-1"); !packages.IsQuotaError(err) {
+				t.Errorf("expected a quota error got %v", err)
+			}
+		})
+	}
+}
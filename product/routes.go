@@ -21,5 +21,18 @@ func ProductRoutes(router *gin.Engine) {
 		productRoutes.POST("/restore", users.JWTAuthMiddleWare(), RestoreProduct)
 		productRoutes.POST("/activate", users.JWTAuthMiddleWare(), ActivateProduct)
 		productRoutes.POST("/deactivate", users.JWTAuthMiddleWare(), DeactivateProduct)
+		productRoutes.POST("/feature", users.JWTAuthMiddleWare(), FeatureProduct)
+		productRoutes.POST("/unfeature", users.JWTAuthMiddleWare(), UnfeatureProduct)
+		productRoutes.POST("/bump", users.JWTAuthMiddleWare(), BumpProduct)
+		productRoutes.GET("/rejectionreasons", GetRejectionReasons)
+
+		// images of the user's own products, id is the product id and image
+		// the image id
+		imageRoutes := productRoutes.Group("/images", users.JWTAuthMiddleWare())
+		imageRoutes.GET("", GetProductImages)
+		imageRoutes.POST("/add", AddImages)
+		imageRoutes.POST("/delete", DeleteImage)
+		imageRoutes.POST("/reorder", ReorderImages)
+		imageRoutes.POST("/setmain", SetMainImageHandler)
 	}
 }
@@ -0,0 +1,83 @@
+package product
+
+import (
+	"strings"
+	"unicode"
+
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/pagination"
+	"eleliafrika.com/backend/users"
+	"gorm.io/gorm"
+)
+
+// weighted search document over the ad fields buyers search on; name ranks
+// highest, then brand and categories, then the description
+const searchVectorSQL = `ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector
+	GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', coalesce(product_name, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(brand, '')), 'B') ||
+		setweight(to_tsvector('english', coalesce(category, '')), 'B') ||
+		setweight(to_tsvector('english', coalesce(subcategory, '')), 'B') ||
+		setweight(to_tsvector('english', coalesce(product_description, '')), 'C')
+	) STORED`
+
+const searchIndexSQL = `CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN (search_vector)`
+
+// add the search column and its index if they are missing
+func MigrateSearchIndex() error {
+	err := database.Database.Exec(searchVectorSQL).Error
+	if err != nil {
+		return err
+	}
+	return database.Database.Exec(searchIndexSQL).Error
+}
+
+// turn raw user input into a to_tsquery expression where every word is
+// prefix matched and all words must match e.g "Sams phone" -> "sams:* & phone:*"
+func BuildSearchQuery(raw string) string {
+	words := strings.FieldsFunc(strings.ToLower(raw), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	var terms []string
+	for _, word := range words {
+		if word == "and" || word == "or" {
+			continue
+		}
+		terms = append(terms, word+":*")
+	}
+	return strings.Join(terms, " & ")
+}
+
+// sellers are searched by name without stemming
+const sellerNameVectorSQL = `to_tsvector('simple', firstname || ' ' || middlename || ' ' || lastname)`
+
+// limit a query to ads matching the search, or posted by a seller whose
+// name matches it, and load each ad's relevance
+func SearchScope(raw string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		tsQuery := BuildSearchQuery(raw)
+		if tsQuery == "" {
+			return db
+		}
+		sellers := database.Database.Model(&users.User{}).Select("user_id").
+			Where(sellerNameVectorSQL+" @@ to_tsquery('simple', ?)", tsQuery)
+		return db.Select("products.*, ts_rank(search_vector, to_tsquery('english', ?)) AS search_rank", tsQuery).
+			Where("search_vector @@ to_tsquery('english', ?) OR products.user_id IN (?)", tsQuery, sellers)
+	}
+}
+
+// product sorts plus ordering by relevance to the search
+func SearchSorts(raw string) map[string]pagination.Sort[Product] {
+	sorts := map[string]pagination.Sort[Product]{
+		"relevance": {
+			Column: "ts_rank(search_vector, to_tsquery('english', ?))",
+			Vars:   []interface{}{BuildSearchQuery(raw)},
+			Value:  func(product Product) interface{} { return product.SearchRank },
+		},
+	}
+	for name, sort := range adSorts {
+		sorts[name] = sort
+	}
+	return sorts
+}
@@ -0,0 +1,28 @@
+package product
+
+import "testing"
+
+func TestBuildSearchQuery(t *testing.T) {
+	type testCase struct {
+		name  string
+		input string
+		want  string
+	}
+
+	cases := []testCase{
+		{"empty search", "", ""},
+		{"blank search", "   ", ""},
+		{"single word", "Samsung", "samsung:*"},
+		{"several words", "samsung  galaxy phone", "samsung:* & galaxy:* & phone:*"},
+		{"connector words are dropped", "phones and laptops", "phones:* & laptops:*"},
+		{"query syntax is stripped", "tv's & (radio) | !fridge:*", "tv:* & s:* & radio:* & fridge:*"},
+	}
+
+	for _, item := range cases {
+		result := BuildSearchQuery(item.input)
+		if result != item.want {
+			t.Errorf("%s: expected %q but found %q", item.name, item.want, result)
+		}
+	}
+	t.Logf("test passed")
+}
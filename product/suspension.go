@@ -0,0 +1,274 @@
+package product
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/notifications"
+	"eleliafrika.com/backend/users"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ActionSuspend   = "suspend"
+	ActionUnsuspend = "unsuspend"
+
+	// unsuspensions done by the job once a suspension ends
+	ActorSystem = "system"
+
+	// reason given to the ads of a seller whose account is revoked
+	SellerRevokedReason = "the seller account was revoked"
+)
+
+// why a product is suspended and, optionally, when it comes back
+type SuspendInput struct {
+	Reason string     `json:"reason"`
+	Until  *time.Time `json:"until"`
+}
+
+type UnsuspendInput struct {
+	Note string `json:"note"`
+}
+
+// add the suspension columns
+func MigrateSuspensions() error {
+	migrator := database.Database.Migrator()
+	for _, field := range []string{"SuspensionReason", "SuspendedAt", "SuspendedUntil", "SuspendedBy"} {
+		if !migrator.HasColumn(&Product{}, field) {
+			if err := migrator.AddColumn(&Product{}, field); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// a suspension needs a reason and can only end in the future
+func validateSuspension(input *SuspendInput, now time.Time) error {
+	input.Reason = strings.TrimSpace(input.Reason)
+	if input.Reason == "" {
+		return errors.New("give a reason for the suspension")
+	} else if utf8.RuneCountInString(input.Reason) > maxModerationNote {
+		return fmt.Errorf("the reason should be at most %d characters long", maxModerationNote)
+	} else if input.Until != nil && !input.Until.After(now) {
+		return errors.New("the suspension should end in the future")
+	}
+	return nil
+}
+
+func suspensionMessage(product Product, reason string, until *time.Time) string {
+	message := fmt.Sprintf("%q was suspended: %s.", product.ProductName, reason)
+	if until != nil {
+		return message + " It will be back on " + until.Format("2 Jan 2006 15:04") + "."
+	}
+	return message + " Contact support to have it reviewed."
+}
+
+// take a locked product down and add the step to its history
+func suspend(tx *gorm.DB, product *Product, adminId string, input SuspendInput, now time.Time) error {
+	err := tx.Model(&Product{}).Where("product_id=?", product.ProductID).Updates(map[string]interface{}{
+		"is_suspended":      true,
+		"suspension_reason": input.Reason,
+		"suspended_at":      now,
+		"suspended_until":   input.Until,
+		"suspended_by":      adminId,
+	}).Error
+	if err != nil {
+		return err
+	}
+	product.IsSuspended = true
+	product.SuspensionReason = input.Reason
+	product.SuspendedAt = &now
+	product.SuspendedUntil = input.Until
+	product.SuspendedBy = adminId
+	return recordModeration(tx, ProductModeration{
+		ProductID:  product.ProductID,
+		ActorID:    adminId,
+		ActorType:  ActorAdmin,
+		Action:     ActionSuspend,
+		FromStatus: product.ModerationStatus,
+		ToStatus:   product.ModerationStatus,
+		Note:       input.Reason,
+	})
+}
+
+func unsuspend(tx *gorm.DB, product *Product, actorId string, actorType string, note string) error {
+	err := tx.Model(&Product{}).Where("product_id=?", product.ProductID).Updates(map[string]interface{}{
+		"is_suspended":      false,
+		"suspension_reason": "",
+		"suspended_at":      nil,
+		"suspended_until":   nil,
+		"suspended_by":      "",
+	}).Error
+	if err != nil {
+		return err
+	}
+	product.IsSuspended = false
+	product.SuspensionReason = ""
+	product.SuspendedAt = nil
+	product.SuspendedUntil = nil
+	product.SuspendedBy = ""
+	err = recordModeration(tx, ProductModeration{
+		ProductID:  product.ProductID,
+		ActorID:    actorId,
+		ActorType:  actorType,
+		Action:     ActionUnsuspend,
+		FromStatus: product.ModerationStatus,
+		ToStatus:   product.ModerationStatus,
+		Note:       note,
+	})
+	if err != nil {
+		return err
+	}
+	return notifications.Notify(tx, notifications.Notification{
+		UserID: product.UserID,
+		Kind:   notifications.KindProductUnsuspended,
+		Title:  "Your ad is no longer suspended",
+		Body:   fmt.Sprintf("%q is no longer suspended.", product.ProductName),
+		ItemID: product.ProductID,
+	})
+}
+
+// suspend a product and let the seller know why
+func SuspendProduct(productId string, adminId string, input SuspendInput) (Product, error) {
+	now := time.Now()
+	if err := validateSuspension(&input, now); err != nil {
+		return Product{}, err
+	}
+	var product Product
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		var err error
+		product, err = lockProduct(tx, productId)
+		if err != nil {
+			return err
+		} else if product.IsDeleted {
+			return errors.New("cannot suspend a deleted product")
+		} else if product.IsSuspended {
+			return errors.New("product is already suspended")
+		}
+		if err := suspend(tx, &product, adminId, input, now); err != nil {
+			return err
+		}
+		return notifications.Notify(tx, notifications.Notification{
+			UserID: product.UserID,
+			Kind:   notifications.KindProductSuspended,
+			Title:  "Your ad was suspended",
+			Body:   suspensionMessage(product, input.Reason, input.Until),
+			ItemID: product.ProductID,
+		})
+	})
+	if err != nil {
+		return Product{}, err
+	}
+	return product, nil
+}
+
+func UnsuspendProduct(productId string, adminId string, input UnsuspendInput) (Product, error) {
+	note := strings.TrimSpace(input.Note)
+	if utf8.RuneCountInString(note) > maxModerationNote {
+		return Product{}, fmt.Errorf("the note should be at most %d characters long", maxModerationNote)
+	}
+	var product Product
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		var err error
+		product, err = lockProduct(tx, productId)
+		if err != nil {
+			return err
+		} else if !product.IsSuspended {
+			return errors.New("product is not suspended")
+		}
+		return unsuspend(tx, &product, adminId, ActorAdmin, note)
+	})
+	if err != nil {
+		return Product{}, err
+	}
+	return product, nil
+}
+
+// bring back products whose suspension has ended
+func UnsuspendExpired() error {
+	now := time.Now()
+	var ended []string
+	err := database.Database.Model(&Product{}).
+		Where("is_suspended=? AND suspended_until IS NOT NULL AND suspended_until <= ?", true, now).
+		Pluck("product_id", &ended).Error
+	if err != nil {
+		return err
+	}
+	for _, productId := range ended {
+		err := database.Database.Transaction(func(tx *gorm.DB) error {
+			product, err := lockProduct(tx, productId)
+			if err != nil {
+				return err
+			} else if !product.IsSuspended || product.SuspendedUntil == nil || product.SuspendedUntil.After(now) {
+				// unsuspended or suspended again since it was picked
+				return nil
+			}
+			return unsuspend(tx, &product, "", ActorSystem, "the suspension ended")
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// suspend every ad of a seller that is not deleted, active or not, so none
+// can be put back up, telling the seller once. Returns how many ads were
+// suspended
+func SuspendSellerAds(tx *gorm.DB, sellerId string, adminId string, reason string) (int, error) {
+	now := time.Now()
+	input := SuspendInput{Reason: reason}
+	if err := validateSuspension(&input, now); err != nil {
+		return 0, err
+	}
+	var ads []Product
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id=? AND is_deleted=? AND is_suspended=?", sellerId, false, false).
+		Order("id").Find(&ads).Error
+	if err != nil {
+		return 0, err
+	}
+	for i := range ads {
+		if err := suspend(tx, &ads[i], adminId, input, now); err != nil {
+			return 0, err
+		}
+	}
+	if len(ads) == 0 {
+		return 0, nil
+	}
+	err = notifications.Notify(tx, notifications.Notification{
+		UserID: sellerId,
+		Kind:   notifications.KindProductSuspended,
+		Title:  "Your ads were suspended",
+		Body:   fmt.Sprintf("%d of your ads were suspended: %s.", len(ads), input.Reason),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(ads), nil
+}
+
+// revoke a seller and suspend their ads together. Revoking an account that
+// is already revoked suspends any ads still left up
+func RevokeSeller(sellerId string, adminId string) (int, error) {
+	suspended := 0
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		// a column update so the password hook does not run
+		err := tx.Model(&users.User{}).Where("user_id=?", sellerId).UpdateColumn("is_approved", false).Error
+		if err != nil {
+			return err
+		}
+		suspended, err = SuspendSellerAds(tx, sellerId, adminId, SellerRevokedReason)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return suspended, nil
+}
@@ -0,0 +1,63 @@
+package product
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateSuspension(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tomorrow := now.AddDate(0, 0, 1)
+	past := now.Add(-time.Minute)
+
+	type testCase struct {
+		name    string
+		input   SuspendInput
+		wantErr bool
+	}
+	cases := []testCase{
+		{"reason only", SuspendInput{Reason: "reported as a scam"}, false},
+		{"with an end", SuspendInput{Reason: "reported as a scam", Until: &tomorrow}, false},
+		{"no reason", SuspendInput{Reason: "  "}, true},
+		{"reason too long", SuspendInput{Reason: strings.Repeat("a", maxModerationNote+1)}, true},
+		{"ends in the past", SuspendInput{Reason: "reported as a scam", Until: &past}, true},
+		{"ends now", SuspendInput{Reason: "reported as a scam", Until: &now}, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			input := c.input
+			err := validateSuspension(&input, now)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error=%v got %v", c.wantErr, err)
+			}
+			if err == nil && input.Reason != strings.TrimSpace(input.Reason) {
+				t.Errorf("expected the reason to be trimmed got %q", input.Reason)
+			}
+		})
+	}
+}
+
+func TestSuspensionMessage(t *testing.T) {
+	product := Product{ProductName: "Red bicycle"}
+	until := time.Date(2024, 3, 8, 9, 30, 0, 0, time.UTC)
+
+	type testCase struct {
+		name  string
+		until *time.Time
+		want  string
+	}
+	cases := []testCase{
+		{"until a date", &until, `"Red bicycle" was suspended: reported. It will be back on 8 Mar 2024 09:30.`},
+		{"until reviewed", nil, `"Red bicycle" was suspended: reported. Contact support to have it reviewed.`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := suspensionMessage(product, "reported", c.until); got != c.want {
+				t.Errorf("expected %s got %s", c.want, got)
+			}
+		})
+	}
+}
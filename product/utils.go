@@ -1,15 +1,38 @@
 package product
 
 import (
-	"encoding/base64"
 	"errors"
 	"regexp"
 	"strings"
-	"unicode"
 
 	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/images"
+	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/pagination"
+	"eleliafrika.com/backend/users"
+	"gorm.io/gorm"
 )
 
+// fields product lists can be sorted on
+var productSorts = map[string]pagination.Sort[Product]{
+	"date": {
+		Column: "created_at",
+		Value:  func(product Product) interface{} { return product.CreatedAt },
+	},
+	"price": {
+		Column: "price_minor",
+		Value:  func(product Product) interface{} { return product.PriceMinor },
+	},
+	"likes": {
+		Column: "total_likes",
+		Value:  func(product Product) interface{} { return product.TotalLikes },
+	},
+}
+
+func productID(product Product) uint {
+	return product.ID
+}
+
 func FindSingleProduct(query string) (Product, error) {
 	var product Product
 	err := database.Database.Where("product_id=?", query).Find(&product).Error
@@ -28,14 +51,27 @@ func FindSingleAd(query string) (Product, error) {
 	}
 	return product, nil
 }
-func Fetchproducts() ([]Product, error) {
-	var productList []Product
 
-	err := database.Database.Find(&productList).Error
-	if err != nil {
-		return []Product{}, err
-	}
-	return productList, nil
+// ads that buyers are allowed to see
+func AdsQuery() *gorm.DB {
+	return database.Database.Model(&Product{}).Where("is_deleted=?", false).Where("is_approved=?", true).Where("is_active=?", true).Where("is_suspended=?", false)
+}
+
+// ads posted by sellers on a paid package
+func TopSellersScope(db *gorm.DB) *gorm.DB {
+	return db.Where("user_id IN (?)", database.Database.Model(&users.User{}).Select("user_id").Where("lower(package_type) <> ?", "basic"))
+}
+
+func FetchProductsPage(request pagination.Request) ([]Product, pagination.Page, error) {
+	return pagination.Fetch(database.Database.Model(&Product{}), request, productSorts, productID)
+}
+
+func FetchAdsPage(query *gorm.DB, request pagination.Request, sorts map[string]pagination.Sort[Product]) ([]Product, pagination.Page, error) {
+	return pagination.Fetch(query, request, sorts, productID)
+}
+
+func FetchSingleUserAdsPage(userid string, request pagination.Request) ([]Product, pagination.Page, error) {
+	return pagination.Fetch(AdsQuery().Where("user_id=?", userid), request, adSorts, productID)
 }
 
 func FetchSingleUserProductsUtil(userid string) ([]Product, error) {
@@ -51,7 +87,7 @@ func FetchSingleUserProductsUtil(userid string) ([]Product, error) {
 func FetchAds() ([]Product, error) {
 	var productList []Product
 
-	err := database.Database.Where("is_deleted=?", false).Where("is_approved=?", true).Where("is_active=?", true).Where("is_suspended=?", false).Find(&productList).Error
+	err := AdsQuery().Find(&productList).Error
 	if err != nil {
 		return []Product{}, err
 	}
@@ -67,17 +103,23 @@ func FetchSimilarProducts(category string) ([]Product, error) {
 	}
 	return productList, nil
 }
-func FetchSingleUserAdsUtil(userid string) ([]Product, error) {
-	var productList []Product
+func ValidateProductInput(product *AddProductInput) (bool, error) {
+	return validateProduct(product, true)
+}
 
-	err := database.Database.Where("user_id=?", userid).Where("is_deleted=?", false).Where("is_approved=?", true).Where("is_active=?", true).Where("is_suspended=?", false).Find(&productList).Error
-	if err != nil {
-		return []Product{}, err
+// an edit can leave the main image out to keep the current one. Gallery
+// images are changed through /products/images
+func ValidateProductUpdate(product *AddProductInput) (bool, error) {
+	if len(product.ProductImages) > 0 || len(product.ImageIDs) > 0 {
+		return false, errors.New("gallery images are changed through /products/images")
+	} else if product.MainImage != "" && product.MainImageID != "" {
+		return false, errors.New("send either a main image or a main image id")
 	}
-	return productList, nil
+	return validateProduct(product, false)
 }
-func ValidateProductInput(product *AddProductInput) (bool, error) {
-	productDetails := []string{product.ProductName, product.ProductPrice, product.ProductDescription, product.ProductType, product.Brand, product.Category, product.SubCategory}
+
+func validateProduct(product *AddProductInput, requireImage bool) (bool, error) {
+	productDetails := []string{product.ProductName, product.ProductDescription, product.ProductType, product.Brand, product.Category, product.SubCategory}
 	charPattern := "[!@#$%^&*()\\=\\[\\]{};\\\\|<>?]"
 	for _, value := range productDetails {
 		if value == product.ProductName {
@@ -87,16 +129,6 @@ func ValidateProductInput(product *AddProductInput) (bool, error) {
 			} else if regexp.MustCompile(charPattern).MatchString(product.ProductName) {
 				return false, errors.New("product name should not contain special character")
 			}
-		} else if value == product.ProductPrice {
-			if len(value) < 2 {
-				return false, errors.New("price invalid")
-			} else {
-				for _, char := range product.ProductPrice {
-					if !unicode.IsNumber(char) {
-						return false, errors.New("price can only contain numbers")
-					}
-				}
-			}
 		} else if value == product.ProductDescription {
 			charPattern := "[@#$%^&\\=\\[\\]{};:\\\\|<>]"
 			value = strings.TrimSpace(value)
@@ -105,17 +137,6 @@ func ValidateProductInput(product *AddProductInput) (bool, error) {
 			} else if regexp.MustCompile(charPattern).MatchString(product.ProductDescription) {
 				return false, errors.New("product description should not contain special character")
 			}
-		} else if value == product.MainImage {
-			value = strings.TrimSpace(value)
-			if value == "" {
-				return false, errors.New("product image cannot be empty")
-			}
-			// check if the base 64 is valid
-			_, err := base64.StdEncoding.DecodeString(product.MainImage)
-			if err != nil {
-				return false, errors.New("invalid base64 string")
-			}
-
 		} else if value == product.ProductType {
 			value = strings.TrimSpace(value)
 			if len(value) < 3 {
@@ -146,17 +167,106 @@ func ValidateProductInput(product *AddProductInput) (bool, error) {
 			}
 		}
 	}
+	if product.MainImageID == "" && (requireImage || product.MainImage != "") {
+		if strings.TrimSpace(product.MainImage) == "" {
+			return false, errors.New("product image cannot be empty")
+		}
+		// check if the base 64 is valid
+		if _, err := images.DecodeImageString(product.MainImage); err != nil {
+			return false, errors.New("invalid base64 string")
+		}
+	}
+	seen := map[string]bool{}
+	for _, id := range append([]string{product.MainImageID}, product.ImageIDs...) {
+		if id == "" {
+			continue
+		} else if seen[id] {
+			return false, errors.New("the same image can only be added once")
+		}
+		seen[id] = true
+	}
+	// updates can leave the price out to keep the one stored
+	if !product.Price.IsEmpty() {
+		if _, err := ParsePriceInput(product.Price); err != nil {
+			return false, err
+		}
+	}
 	return true, nil
 }
-func UpdateProductUtil(query string, update Product) (Product, error) {
-	var updatedProduct Product
+func UpdateProductUtil(tx *gorm.DB, id string, update Product) error {
+	result := tx.Model(&Product{}).Where("product_id=?", id).Updates(update)
+	if result.Error != nil {
+		return result.Error
+	} else if result.RowsAffected == 0 {
+		return errors.New("could not update the product right now")
+	}
+	return nil
+}
 
-	result := database.Database.Model(&updatedProduct).Where(query).Updates(update)
+// price flags are written with a map so that turning them off is saved too
+func UpdateProductPriceUtil(tx *gorm.DB, id string, price Price) error {
+	result := tx.Model(&Product{}).Where("product_id=?", id).Updates(map[string]interface{}{
+		"price_minor":            price.Minor,
+		"currency":               price.Currency,
+		"is_negotiable":          price.IsNegotiable,
+		"price_on_request":       price.PriceOnRequest,
+		"compare_at_price_minor": price.CompareAtMinor,
+	})
+	if result.Error != nil {
+		return result.Error
+	} else if result.RowsAffected == 0 {
+		return errors.New("could not update the product price right now")
+	}
+	return nil
+}
 
-	if result.RowsAffected == 0 {
-		return Product{}, errors.New("could not update the product right now")
+// an edit of a product. A new main image is either an upload made with the
+// edit or the id of one made through /images/upload
+type ProductEdit struct {
+	Fields      Product
+	Price       *Price
+	MainUpload  *images.UploadedImage
+	MainImageID string
+}
+
+// save an edit of a product in one go, keeping the stored price when no
+// price was sent, send it back for review and read the product back. An
+// upload sent with the edit is deleted again if the edit could not be saved
+func UpdateProductDetails(id string, userId string, edit ProductEdit) (Product, error) {
+	var updated Product
+	var replaced models.ProductImage
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		if err := UpdateProductUtil(tx, id, edit.Fields); err != nil {
+			return err
+		}
+		if edit.Price != nil {
+			if err := UpdateProductPriceUtil(tx, id, *edit.Price); err != nil {
+				return err
+			}
+		}
+		if edit.MainUpload != nil || edit.MainImageID != "" {
+			var err error
+			product := Product{ProductID: id, UserID: userId}
+			replaced, err = replaceMainImage(tx, product, edit.MainUpload, edit.MainImageID)
+			if err != nil {
+				return err
+			}
+		}
+		if err := ResubmitProduct(tx, id, userId); err != nil {
+			return err
+		}
+		return tx.Where("product_id=?", id).Find(&updated).Error
+	})
+	if err != nil {
+		if edit.MainUpload != nil {
+			deleteUploads([]images.UploadedImage{*edit.MainUpload})
+		}
+		return Product{}, err
+	}
+	if replaced.ObjectKey != "" {
+		deleteUploads([]images.UploadedImage{images.UploadedImageFrom(replaced)})
 	}
-	return updatedProduct, nil
+	return updated, nil
 }
 
 func ActivateProductUtil(query string) (bool, error) {
@@ -1,15 +1,15 @@
 package subcategory
 
 import (
-	"eleliafrika.com/backend/users"
+	"eleliafrika.com/backend/auth"
 	"github.com/gin-gonic/gin"
 )
 
 func SubCategoryRoutes(router *gin.Engine) {
 	categoryRoutes := router.Group("/subcategories")
 	{
-		categoryRoutes.POST("/addsubcategory", users.JWTAuthMiddleWare(), CreateSubCategory)
+		categoryRoutes.POST("/addsubcategory", auth.RequireAdmin(auth.PermissionManageCatalog), CreateSubCategory)
 		categoryRoutes.GET("/getsubcategories/:name", GetSubCategories)
-		categoryRoutes.POST("/delete/:name", users.JWTAuthMiddleWare(), DeleteSubCategory)
+		categoryRoutes.POST("/delete/:name", auth.RequireAdmin(auth.PermissionManageCatalog), DeleteSubCategory)
 	}
 }
@@ -8,8 +8,10 @@ import (
 
 	"time"
 
+	"eleliafrika.com/backend/auth"
 	"eleliafrika.com/backend/images"
 	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/pagination"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -140,23 +142,23 @@ func Register(context *gin.Context) {
 			context.JSON(http.StatusBadRequest, response)
 			return
 		}
-		// generate token directly on succesfuly register
-		token, err := GenerateJWT(user)
+		// sign the user in directly on succesfuly register
+		tokens, err := auth.IssueSession(user.UserID, auth.SubjectUser, "", context)
 
 		if err != nil {
 			response := models.Reply{
 				Message: "could not generate token for the user",
 				Success: false,
-				Data:    token,
 				Error:   err.Error(),
 			}
 			context.JSON(http.StatusBadRequest, response)
+			return
 		}
 
 		response := models.Reply{
 			Message: "User has been created succesfully",
 			Success: true,
-			Data:    token,
+			Data:    tokens,
 		}
 		context.JSON(http.StatusCreated, response)
 	}
@@ -225,8 +227,8 @@ func Login(context *gin.Context) {
 			context.JSON(http.StatusBadRequest, response)
 			return
 		}
-		// generate jwt if error does not exists
-		token, err := GenerateJWT(user)
+		// start a session for this device if error does not exists
+		tokens, err := auth.IssueSession(user.UserID, auth.SubjectUser, "", context)
 
 		if err != nil {
 			response := models.Reply{
@@ -239,23 +241,9 @@ func Login(context *gin.Context) {
 
 		}
 
-		_, err = UpdateUserUtil(user.UserID, User{
-			Token: token,
-		})
-
-		if err != nil {
-			response := models.Reply{
-				Error:   err.Error(),
-				Message: "error while generating token",
-				Success: false,
-			}
-			context.JSON(http.StatusBadRequest, response)
-			return
-		}
-
 		response := models.Reply{
 			Message: "Authentication successful",
-			Data:    token,
+			Data:    tokens,
 			Success: true,
 		}
 		context.JSON(http.StatusOK, response)
@@ -294,7 +282,8 @@ func GetSingleUser(context *gin.Context) {
 				UserID:     user.UserID,
 				IsApproved: user.IsApproved,
 				Phone:      user.Phone,
-				Token:      user.Token,
+				// unread messages across all conversations
+				Chats: user.Chats,
 			}
 
 			response := models.Reply{
@@ -425,10 +414,20 @@ func UpdateUser(context *gin.Context) {
 	}
 }
 func FetchSellers(context *gin.Context) {
-	users, err := FetchAllSellersUtil()
-
 	query := context.Query("top")
 
+	request, err := pagination.ParseRequest(context, "date", true)
+	if err != nil {
+		response := models.Reply{
+			Error:   err.Error(),
+			Message: "invalid pagination parameters",
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	_, page, err := FetchSellersPage(strings.ReplaceAll(query, "'", "") == "top", request)
 	if err != nil {
 		response := models.Reply{
 			Error:   err.Error(),
@@ -438,19 +437,8 @@ func FetchSellers(context *gin.Context) {
 		context.JSON(http.StatusBadRequest, response)
 		return
 	} else {
-		var topSellers []User
-		if strings.ReplaceAll(query, "'", "") == "top" {
-			for _, seller := range users {
-				if strings.ToLower(seller.PackageType) != "basic" {
-					topSellers = append(topSellers, seller)
-				}
-			}
-		} else {
-			topSellers = users
-		}
-
 		response := models.Reply{
-			Data:    topSellers,
+			Data:    page,
 			Message: "succesfully fetched all users",
 			Success: true,
 		}
@@ -459,6 +447,16 @@ func FetchSellers(context *gin.Context) {
 	}
 }
 func Logoutuser(context *gin.Context) {
+	claims, err := auth.ValidateToken(context)
+	if err != nil {
+		response := models.Reply{
+			Message: "error fetching current user",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusBadRequest, response)
+		return
+	}
 	user, err := CurrentUser(context)
 	if err != nil {
 		response := models.Reply{
@@ -478,18 +476,8 @@ func Logoutuser(context *gin.Context) {
 			}
 			context.JSON(http.StatusBadRequest, response)
 			return
-		} else if user.Token == "none" {
-			response := models.Reply{
-				Message: "error fetching token",
-				Error:   errors.New("error token does not exist").Error(),
-				Success: false,
-			}
-			context.JSON(http.StatusBadRequest, response)
-			return
 		} else {
-			_, err := UpdateUserUtil(user.UserID, User{
-				Token: "none",
-			})
+			err := auth.RevokeSession(claims.SessionID, user.UserID)
 			if err != nil {
 				response := models.Reply{
 					Message: "error login out user",
@@ -1,6 +1,9 @@
 package users
 
-import "github.com/gin-gonic/gin"
+import (
+	"eleliafrika.com/backend/auth"
+	"github.com/gin-gonic/gin"
+)
 
 func UserRoutes(router *gin.Engine) {
 	authRoutes := router.Group("/user/auth")
@@ -8,6 +11,9 @@ func UserRoutes(router *gin.Engine) {
 		authRoutes.POST("/signup", Register)
 		authRoutes.POST("/signin", Login)
 		authRoutes.POST("/logout", JWTAuthMiddleWare(), Logoutuser)
+		authRoutes.POST("/refresh", auth.Refresh(auth.SubjectUser))
+		authRoutes.GET("/sessions", JWTAuthMiddleWare(), auth.ListSessions(auth.SubjectUser))
+		authRoutes.POST("/sessions/revoke", JWTAuthMiddleWare(), auth.RevokeDevice)
 		authRoutes.GET("/getuser", JWTAuthMiddleWare(), GetSingleUser)
 		authRoutes.GET("/fetchuser", FetchSingleUser)
 		authRoutes.POST("/updateuser", JWTAuthMiddleWare(), UpdateUser)
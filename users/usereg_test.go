@@ -316,7 +316,7 @@ func TestLoginData(t *testing.T) {
 		{
 			name: "test passed",
 			data: validData,
-			want: false,
+			want: true,
 		},
 	}
 
@@ -2,88 +2,62 @@ package users
 
 import (
 	"errors"
-	"fmt"
 	"net/http"
-	"os"
 	"regexp"
-	"strconv"
 	"strings"
-	"time"
 	"unicode"
 
+	"eleliafrika.com/backend/auth"
 	"eleliafrika.com/backend/database"
+	"eleliafrika.com/backend/models"
+	"eleliafrika.com/backend/pagination"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var privateKey = []byte(os.Getenv("JWT_PRIVATE_KEY"))
-
-func GenerateJWT(user User) (string, error) {
-	tokenTTL, _ := strconv.Atoi(os.Getenv("TOKEN_TTL"))
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"email": user.Email,
-		"iat":   time.Now().Unix(),
-		"eat":   time.Now().Add(time.Second * time.Duration(tokenTTL)).Unix(),
-	})
-	return token.SignedString(privateKey)
-}
-
-func ValidateJWT(context *gin.Context) error {
-	token, err := GetToken(context)
-
-	if err != nil {
-		return err
-	}
-
-	_, ok := token.Claims.(jwt.MapClaims)
-	if ok && token.Valid {
-		return nil
-	}
-	return errors.New("invalid token provided")
-}
-
-func GetToken(context *gin.Context) (*jwt.Token, error) {
-	tokenFromUser := context.Request.Header.Get("x-access-token")
-
-	token, err := jwt.Parse(tokenFromUser, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return privateKey, nil
-	})
-	return token, err
-}
-
+// routes for signed in users, admin tokens are turned away
 func JWTAuthMiddleWare() gin.HandlerFunc {
-	fmt.Println("authenticating")
-	return func(context *gin.Context) {
-		err := ValidateJWT(context)
-		if err != nil {
-			context.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
-			context.Abort()
-			return
-		}
-		context.Next()
-	}
+	return auth.RequireUser()
 }
 
 func CurrentUser(context *gin.Context) (User, error) {
-	err := ValidateJWT(context)
+	claims, err := auth.ValidateToken(context)
 	if err != nil {
 		return User{}, err
+	} else if claims.SubjectType != auth.SubjectUser {
+		return User{}, errors.New("token does not belong to a user")
 	}
-	token, _ := GetToken(context)
-	claims, _ := token.Claims.(jwt.MapClaims)
-	useremail := string(claims["email"].(string))
 
-	user, err := FindUserByEmail(useremail)
+	user, err := FindUserById(claims.Subject)
 	if err != nil {
 		return User{}, err
 	}
 	return user, nil
 }
 
+// the signed in user, or false once a 401 has been sent
+func CurrentUserOrReply(context *gin.Context) (User, bool) {
+	user, err := CurrentUser(context)
+	if err != nil {
+		response := models.Reply{
+			Message: "error authorizing user",
+			Error:   err.Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return User{}, false
+	} else if user.UserID == "" {
+		response := models.Reply{
+			Message: "user does not exist",
+			Error:   errors.New("user does not exist").Error(),
+			Success: false,
+		}
+		context.JSON(http.StatusUnauthorized, response)
+		return User{}, false
+	}
+	return user, true
+}
+
 func ValidateRegisterInput(user *RegisterInput) (bool, error) {
 	userDetails := []string{user.Email, user.Firstname, user.Lastname, user.UserLocation, user.Phone, user.Password}
 	charPattern := "[!@#$%^&*()_+\\-=\\[\\]{};':\"\\\\|,.<>?]"
@@ -113,8 +87,8 @@ func ValidateRegisterInput(user *RegisterInput) (bool, error) {
 				}
 			}
 		} else if value == user.Password {
-			if len(value) < 3 {
-				return false, errors.New("password is too short")
+			if err := auth.ValidatePassword(user.Password); err != nil {
+				return false, err
 			}
 		} else if value == user.UserLocation {
 			user.UserLocation = strings.ToLower(user.UserLocation)
@@ -123,7 +97,7 @@ func ValidateRegisterInput(user *RegisterInput) (bool, error) {
 			} else if regexp.MustCompile(numPattern).MatchString(user.UserLocation) {
 				return false, errors.New("location must not contain a numerical digit")
 			}
-		} else if len(value) <= 3 {
+		} else if len(value) < 3 {
 			return false, errors.New("invalid input length for field")
 		} else {
 			if regexp.MustCompile(numPattern).MatchString(user.Firstname) {
@@ -183,6 +157,30 @@ func UpdateUserSpecificField(userId string, field string, value any) (User, erro
 	return updatedUser, nil
 }
 
+var sellerSorts = map[string]pagination.Sort[User]{
+	"date": {
+		Column: "created_at",
+		Value:  func(user User) interface{} { return user.CreatedAt },
+	},
+	"likes": {
+		Column: "total_likes",
+		Value:  func(user User) interface{} { return user.TotalLikes },
+	},
+	"products": {
+		Column: "total_products",
+		Value:  func(user User) interface{} { return user.NoOfProducts },
+	},
+}
+
+// page through sellers, only those on a paid package when top is set
+func FetchSellersPage(top bool, request pagination.Request) ([]User, pagination.Page, error) {
+	query := database.Database.Model(&User{})
+	if top {
+		query = query.Where("lower(package_type) <> ?", "basic")
+	}
+	return pagination.Fetch(query, request, sellerSorts, func(user User) uint { return user.ID })
+}
+
 func FetchAllSellersUtil() ([]User, error) {
 	var AllUsers []User
 